@@ -5,7 +5,7 @@ import { Input } from '@/components/ui/input'
 import { Toaster } from '@/components/ui/toaster'
 import { StatusIndicator } from '@/components/ui/status-indicator'
 import { useToast } from '@/hooks/use-toast'
-import { useSessionStore } from '@/stores'
+import { useSessionStore, type AutoAttachOptions } from '@/stores'
 import { EventsPanel } from '@/components/events'
 import { NetworkPanel } from '@/components/network/NetworkPanel'
 import { TargetsPanel } from '@/components/targets/TargetsPanel'
@@ -51,6 +51,8 @@ function App() {
     resetSession,
     refreshTargets,
     toggleTarget,
+    autoAttach,
+    setAutoAttach,
     language,
     setLanguage
   } = useSessionStore()
@@ -175,6 +177,14 @@ function App() {
     }
   }
 
+  // 设置自动附着新页面
+  const handleAutoAttachChange = async (opts: AutoAttachOptions) => {
+    const result = await setAutoAttach(opts)
+    if (!result.success) {
+      toast({ variant: 'destructive', title: t('errors.title'), description: getErrorMessage(result, t) })
+    }
+  }
+
   // 切换全量流量捕获
   const handleToggleTrafficCapture = async (enabled: boolean, silent = false) => {
     if (!sessionId) return
@@ -304,6 +314,8 @@ function App() {
                 onToggle={handleToggleTarget}
                 isConnected={isConnected}
                 onRefresh={() => refreshTargets()}
+                autoAttach={autoAttach}
+                onAutoAttachChange={handleAutoAttachChange}
               />
             </div>
           </TabsContent>
@@ -14,6 +14,10 @@ export const api = {
     disableInterception: App.DisableInterception,
     loadRules: App.LoadRules,
     getRuleStats: App.GetRuleStats,
+    resetRuleStats: App.ResetRuleStats,
+    getSessionState: App.GetSessionState,
+    resetSessionState: App.ResetSessionState,
+    explainRules: App.ExplainRules,
     enableTrafficCapture: App.EnableTrafficCapture,
     loadActiveConfig: App.LoadActiveConfigToSession,
   },
@@ -26,6 +30,7 @@ export const api = {
     listTargets: App.ListTargets,
     attachTarget: App.AttachTarget,
     detachTarget: App.DetachTarget,
+    setAutoAttach: App.SetAutoAttach,
   },
   
   // 配置管理
@@ -316,7 +316,7 @@ function EventDetailView({ event }: { event: MatchedEventWithId }) {
                       Object.entries(response.headers).map(([k, v]) => (
                         <div key={k} className="flex gap-2 py-0.5 border-b border-muted/30 last:border-0">
                           <span className="text-primary font-bold shrink-0 selectable">{k}:</span>
-                          <span className="break-all selectable">{v}</span>
+                          <span className="break-all whitespace-pre-wrap selectable">{v}</span>
                         </div>
                       ))
                     ) : (
@@ -340,7 +340,7 @@ function EventDetailView({ event }: { event: MatchedEventWithId }) {
                       Object.entries(request.headers).map(([k, v]) => (
                         <div key={k} className="flex gap-2 py-0.5 border-b border-muted/30 last:border-0">
                           <span className="text-primary font-bold shrink-0 selectable">{k}:</span>
-                          <span className="break-all selectable">{v}</span>
+                          <span className="break-all whitespace-pre-wrap selectable">{v}</span>
                         </div>
                       ))
                     ) : (
@@ -326,7 +326,7 @@ function TrafficDetailView({ request, response }: { request: TrafficRequest, res
                       Object.entries(response.headers).map(([k, v]) => (
                         <div key={k} className="flex gap-2 py-0.5 border-b border-muted/30 last:border-0">
                           <span className="text-primary font-bold shrink-0 selectable">{k}:</span>
-                          <span className="break-all selectable">{v}</span>
+                          <span className="break-all whitespace-pre-wrap selectable">{v}</span>
                         </div>
                       ))
                     ) : (
@@ -350,7 +350,7 @@ function TrafficDetailView({ request, response }: { request: TrafficRequest, res
                       Object.entries(request.headers).map(([k, v]) => (
                         <div key={k} className="flex gap-2 py-0.5 border-b border-muted/30 last:border-0">
                           <span className="text-primary font-bold shrink-0 selectable">{k}:</span>
-                          <span className="break-all selectable">{v}</span>
+                          <span className="break-all whitespace-pre-wrap selectable">{v}</span>
                         </div>
                       ))
                     ) : (
@@ -413,7 +413,7 @@ function TrafficDetailView({ request, response }: { request: TrafficRequest, res
               </>
             ) : (
               <div className="flex flex-col items-center justify-center py-12 text-muted-foreground">
-                <div className="text-xs italic">{t('events.response.noData')}</div>
+                <div className="text-xs italic">{t(response?.bodyOmitted ? 'events.response.omitted' : 'events.response.noData')}</div>
               </div>
             )}
           </div>
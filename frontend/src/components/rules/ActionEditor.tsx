@@ -5,8 +5,9 @@ import { Textarea } from '@/components/ui/textarea'
 import { Badge } from '@/components/ui/badge'
 import { X, Plus, Trash2, GripVertical, AlertCircle } from 'lucide-react'
 import { useTranslation } from 'react-i18next'
-import type { Action, ActionType, Stage, JSONPatchOp, BodyEncoding } from '@/types/rules'
+import type { Action, ActionType, Stage, JSONPatchOp, BodyEncoding, ErrorReason } from '@/types/rules'
 import {
+  ERROR_REASONS,
   createEmptyAction,
   isTerminalAction,
   getActionsForStage,
@@ -29,6 +30,13 @@ function getActionTypeOptions(stage: Stage): { value: ActionType; label: string
   }))
 }
 
+// 支持模板表达式的行为类型
+const TEMPLATE_ACTIONS: ActionType[] = [
+  'setUrl', 'setHeader', 'setQueryParam', 'setCookie', 'setBody',
+  'setFormField', 'setFormFile', 'replaceBodyText', 'mergeBodyJson', 'block',
+  'setVariable', 'setScenarioState',
+]
+
 export function ActionEditor({ action, onChange, onRemove, stage }: ActionEditorProps) {
   const { t } = useTranslation()
   const handleTypeChange = (newType: ActionType) => {
@@ -57,6 +65,17 @@ export function ActionEditor({ action, onChange, onRemove, stage }: ActionEditor
                 {t('rules.terminalAction')}
               </Badge>
             )}
+            {TEMPLATE_ACTIONS.includes(action.type) && (
+              <label className="flex items-center gap-2 text-sm cursor-pointer ml-auto" title={t('rules.templateHint')}>
+                <input
+                  type="checkbox"
+                  checked={action.template || false}
+                  onChange={(e) => onChange({ ...action, template: e.target.checked })}
+                  className="rounded"
+                />
+                {t('rules.template')}
+              </label>
+            )}
           </div>
 
           {/* 根据行为类型渲染字段 */}
@@ -111,6 +130,7 @@ function renderActionFields(action: Action, onChange: (action: Action) => void)
     case 'setQueryParam':
     case 'setCookie':
     case 'setFormField':
+    case 'setVariable':
       return (
         <div className="flex items-center gap-2">
           <Input
@@ -128,6 +148,42 @@ function renderActionFields(action: Action, onChange: (action: Action) => void)
         </div>
       )
 
+    case 'extractVariable':
+      return (
+        <div className="flex items-center gap-2">
+          <Input
+            value={action.name || ''}
+            onChange={(e) => updateField('name', e.target.value)}
+            placeholder={getNamePlaceholder(action.type)}
+            className="flex-1"
+          />
+          <Input
+            value={action.path || ''}
+            onChange={(e) => updateField('path', e.target.value)}
+            placeholder="$.data.token"
+            className="flex-1"
+          />
+        </div>
+      )
+
+    case 'setScenarioState':
+      return (
+        <div className="flex items-center gap-2">
+          <Input
+            value={action.name || ''}
+            onChange={(e) => updateField('name', e.target.value)}
+            placeholder={getNamePlaceholder(action.type)}
+            className="flex-1"
+          />
+          <Input
+            value={(action.value as string) || ''}
+            onChange={(e) => updateField('value', e.target.value)}
+            placeholder={t('rules.scenarioTargetState')}
+            className="flex-1"
+          />
+        </div>
+      )
+
     case 'removeHeader':
     case 'removeQueryParam':
     case 'removeCookie':
@@ -170,6 +226,48 @@ function renderActionFields(action: Action, onChange: (action: Action) => void)
         </div>
       )
 
+    case 'setFormFile':
+      return (
+        <div className="space-y-2">
+          <div className="flex items-center gap-2">
+            <Input
+              value={action.name || ''}
+              onChange={(e) => updateField('name', e.target.value)}
+              placeholder={getNamePlaceholder(action.type)}
+              className="flex-1"
+            />
+            <Input
+              value={action.fileName || ''}
+              onChange={(e) => updateField('fileName', e.target.value)}
+              placeholder={t('rules.fileName')}
+              className="flex-1"
+            />
+            <Input
+              value={action.contentType || ''}
+              onChange={(e) => updateField('contentType', e.target.value)}
+              placeholder="Content-Type"
+              className="flex-1"
+            />
+            <Select
+              value={action.encoding || 'text'}
+              onChange={(e) => updateField('encoding', e.target.value as BodyEncoding)}
+              options={[
+                { value: 'text', label: t('rules.textEncoding') },
+                { value: 'base64', label: t('rules.base64Encoding') },
+              ]}
+              className="w-28"
+            />
+          </div>
+          <Textarea
+            value={(action.value as string) || ''}
+            onChange={(e) => updateField('value', e.target.value)}
+            placeholder={t('rules.fileContentKeep')}
+            rows={4}
+            className="font-mono text-sm"
+          />
+        </div>
+      )
+
     case 'replaceBodyText':
       return (
         <div className="space-y-2">
@@ -207,6 +305,174 @@ function renderActionFields(action: Action, onChange: (action: Action) => void)
         />
       )
 
+    case 'mergeBodyJson':
+      return (
+        <Textarea
+          value={(action.value as string) || ''}
+          onChange={(e) => updateField('value', e.target.value)}
+          placeholder={t('rules.mergePatchPlaceholder')}
+          rows={4}
+          className="font-mono text-sm"
+        />
+      )
+
+    case 'patchGraphqlVariables':
+      return (
+        <div className="space-y-2">
+          <Input
+            value={action.operationName || ''}
+            onChange={(e) => updateField('operationName', e.target.value)}
+            placeholder={t('rules.operationNameAll')}
+            className="w-60"
+          />
+          <JSONPatchEditor
+            patches={action.patches || []}
+            onChange={(patches) => updateField('patches', patches)}
+          />
+        </div>
+      )
+
+    case 'mockGraphql':
+      return (
+        <div className="space-y-2">
+          <Input
+            value={action.operationName || ''}
+            onChange={(e) => updateField('operationName', e.target.value)}
+            placeholder={t('rules.operationNameAll')}
+            className="w-60"
+          />
+          <Textarea
+            value={formatJSONValue(action.data)}
+            onChange={(e) => updateField('data', parseJSONValue(e.target.value))}
+            placeholder={t('rules.graphqlData')}
+            rows={4}
+            className="font-mono text-sm"
+          />
+          <Textarea
+            value={formatJSONValue(action.errors)}
+            onChange={(e) => updateField('errors', parseJSONValue(e.target.value))}
+            placeholder={t('rules.graphqlErrors')}
+            rows={2}
+            className="font-mono text-sm"
+          />
+        </div>
+      )
+
+    case 'delay':
+      return (
+        <div className="flex items-center gap-2">
+          <Input
+            type="number"
+            value={action.delayMs ?? ''}
+            onChange={(e) => updateField('delayMs', parseInt(e.target.value) || 0)}
+            placeholder={t('rules.delayMs')}
+            min={0}
+            className="w-32"
+          />
+          <span className="text-muted-foreground">~</span>
+          <Input
+            type="number"
+            value={action.delayMaxMs ?? ''}
+            onChange={(e) => updateField('delayMaxMs', parseInt(e.target.value) || undefined)}
+            placeholder={t('rules.delayMaxMs')}
+            min={0}
+            className="w-32"
+          />
+          <span className="text-xs text-muted-foreground">ms</span>
+        </div>
+      )
+
+    case 'throttle':
+      return (
+        <div className="flex items-center gap-2">
+          <Input
+            type="number"
+            value={action.bytesPerSecond ?? ''}
+            onChange={(e) => updateField('bytesPerSecond', parseInt(e.target.value) || undefined)}
+            placeholder={t('rules.bytesPerSecond')}
+            min={1}
+            className="w-40"
+          />
+          <span className="text-xs text-muted-foreground">B/s</span>
+        </div>
+      )
+
+    case 'mapRemote':
+      return (
+        <div className="space-y-2">
+          <div className="flex items-center gap-2">
+            <Input
+              value={action.from || ''}
+              onChange={(e) => updateField('from', e.target.value)}
+              placeholder={t('rules.mapRemoteFrom')}
+              className="flex-1 font-mono text-xs"
+            />
+            <span className="text-muted-foreground">→</span>
+            <Input
+              value={action.to || ''}
+              onChange={(e) => updateField('to', e.target.value)}
+              placeholder="http://localhost:8080"
+              className="flex-1 font-mono text-xs"
+            />
+          </div>
+          <div className="flex items-center gap-4">
+            <label className="flex items-center gap-2 text-sm cursor-pointer">
+              <input
+                type="checkbox"
+                checked={action.preserveHost || false}
+                onChange={(e) => updateField('preserveHost', e.target.checked)}
+                className="rounded"
+              />
+              {t('rules.preserveHost')}
+            </label>
+            <label className="flex items-center gap-2 text-sm cursor-pointer">
+              <input
+                type="checkbox"
+                checked={action.rewriteOrigin || false}
+                onChange={(e) => updateField('rewriteOrigin', e.target.checked)}
+                className="rounded"
+              />
+              {t('rules.rewriteOrigin')}
+            </label>
+          </div>
+        </div>
+      )
+
+    case 'mapLocal':
+      return (
+        <div className="space-y-3">
+          <div className="flex items-center gap-2">
+            <Input
+              value={action.localPath || ''}
+              onChange={(e) => updateField('localPath', e.target.value)}
+              placeholder={t('rules.localPath')}
+              className="flex-1 font-mono text-xs"
+            />
+            <Input
+              value={action.urlPrefix || ''}
+              onChange={(e) => updateField('urlPrefix', e.target.value)}
+              placeholder={t('rules.urlPrefixOptional')}
+              className="flex-1 font-mono text-xs"
+            />
+          </div>
+          <KeyValueEditor
+            title={t('rules.responseHeaders')}
+            data={action.headers || {}}
+            onChange={(headers) => onChange({ ...action, headers })}
+          />
+        </div>
+      )
+
+    case 'fail':
+      return (
+        <Select
+          value={action.errorReason || 'Failed'}
+          onChange={(e) => updateField('errorReason', e.target.value as ErrorReason)}
+          options={ERROR_REASONS.map(r => ({ value: r, label: r }))}
+          className="w-56"
+        />
+      )
+
     case 'setStatus':
       return (
         <Input
@@ -263,6 +529,18 @@ function renderActionFields(action: Action, onChange: (action: Action) => void)
   }
 }
 
+// 将 JSON 值格式化为编辑框文本，字符串原样显示
+function formatJSONValue(value: any): string {
+  if (value === undefined || value === null) return ''
+  return typeof value === 'string' ? value : JSON.stringify(value, null, 2)
+}
+
+// 解析编辑框文本，非法 JSON 按字符串保存，空文本视为未设置
+function parseJSONValue(text: string): any {
+  if (text.trim() === '') return undefined
+  try { return JSON.parse(text) } catch { return text }
+}
+
 // 获取 name 字段占位符
 function getNamePlaceholder(type: ActionType): string {
   switch (type) {
@@ -277,7 +555,13 @@ function getNamePlaceholder(type: ActionType): string {
       return 'Cookie 名'
     case 'setFormField':
     case 'removeFormField':
+    case 'setFormFile':
       return '字段名'
+    case 'setVariable':
+    case 'extractVariable':
+      return '变量名'
+    case 'setScenarioState':
+      return '场景名'
     default:
       return '名称'
   }
@@ -368,6 +652,7 @@ function JSONPatchEditor({ patches, onChange }: JSONPatchEditorProps) {
     { value: 'replace', label: t('rules.patchOpReplace') },
     { value: 'move', label: t('rules.patchOpMove') },
     { value: 'copy', label: t('rules.patchOpCopy') },
+    { value: 'test', label: t('rules.patchOpTest') },
   ]
 
   const addPatch = () => {
@@ -422,7 +707,7 @@ function JSONPatchEditor({ patches, onChange }: JSONPatchEditorProps) {
                   className="w-32"
                 />
               )}
-              {(patch.op === 'add' || patch.op === 'replace') && (
+              {(patch.op === 'add' || patch.op === 'replace' || patch.op === 'test') && (
                 <Input
                   value={typeof patch.value === 'string' ? patch.value : JSON.stringify(patch.value)}
                   onChange={(e) => {
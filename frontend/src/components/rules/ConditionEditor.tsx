@@ -3,10 +3,11 @@ import { Select } from '@/components/ui/select'
 import { Button } from '@/components/ui/button'
 import { Badge } from '@/components/ui/badge'
 import { X, Plus } from 'lucide-react'
-import type { Condition, ConditionType } from '@/types/rules'
+import type { Condition, ConditionType, JsonPathOperator } from '@/types/rules'
 import {
   CONDITION_GROUPS,
   HTTP_METHODS,
+  JSON_PATH_OPERATORS,
   RESOURCE_TYPES,
   createEmptyCondition,
   getConditionFields,
@@ -38,6 +39,12 @@ export function ConditionEditor({ condition, onChange, onRemove }: ConditionEdit
     ...CONDITION_GROUPS.cookie.map(t => ({ value: t as ConditionType, label: getConditionTypeShortLabel(t) })),
     // Body
     ...CONDITION_GROUPS.body.map(t => ({ value: t as ConditionType, label: getConditionTypeShortLabel(t) })),
+    // 表单
+    ...CONDITION_GROUPS.form.map(t => ({ value: t as ConditionType, label: getConditionTypeShortLabel(t) })),
+    // GraphQL
+    ...CONDITION_GROUPS.graphql.map(t => ({ value: t as ConditionType, label: getConditionTypeShortLabel(t) })),
+    // 会话状态
+    ...CONDITION_GROUPS.state.map(t => ({ value: t as ConditionType, label: getConditionTypeShortLabel(t) })),
   ]
   
   const handleTypeChange = (newType: ConditionType) => {
@@ -54,6 +61,9 @@ export function ConditionEditor({ condition, onChange, onRemove }: ConditionEdit
     if (type.startsWith('header')) return t('rules.headerName')
     if (type.startsWith('query')) return t('rules.queryName')
     if (type.startsWith('cookie')) return t('rules.cookieName')
+    if (type.startsWith('formField')) return t('rules.fieldName')
+    if (type.startsWith('variable')) return t('rules.variableName')
+    if (type === 'scenarioState') return t('rules.scenarioName')
     return 'Name'
   }
 
@@ -96,8 +106,28 @@ export function ConditionEditor({ condition, onChange, onRemove }: ConditionEdit
           />
         )}
 
-        {/* value 字段 */}
-        {fields.includes('value') && (
+        {/* operator 字段 (bodyJsonPath) */}
+        {fields.includes('operator') && (
+          <Select
+            value={condition.operator || 'eq'}
+            onChange={(e) => updateField('operator', e.target.value as JsonPathOperator)}
+            options={JSON_PATH_OPERATORS.map(op => ({ value: op, label: op }))}
+            className="w-28"
+          />
+        )}
+
+        {/* in 运算符的候选值列表（逗号分隔） */}
+        {fields.includes('operator') && condition.operator === 'in' && (
+          <Input
+            value={(condition.values || []).join(',')}
+            onChange={(e) => updateField('values', e.target.value.split(',').map(v => v.trim()).filter(Boolean))}
+            placeholder="a,b,c"
+            className="flex-1 min-w-[150px]"
+          />
+        )}
+
+        {/* value 字段（exists/notExists/in 无需单个期望值） */}
+        {fields.includes('value') && condition.operator !== 'exists' && condition.operator !== 'notExists' && condition.operator !== 'in' && (
           <Input
             value={condition.value || ''}
             onChange={(e) => updateField('value', e.target.value)}
@@ -116,6 +146,16 @@ export function ConditionEditor({ condition, onChange, onRemove }: ConditionEdit
           />
         )}
 
+        {/* 场景状态列表（逗号分隔） */}
+        {condition.type === 'scenarioState' && (
+          <Input
+            value={(condition.values || []).join(',')}
+            onChange={(e) => updateField('values', e.target.value.split(',').map(v => v.trim()).filter(Boolean))}
+            placeholder={t('rules.scenarioStates')}
+            className="flex-1 min-w-[150px]"
+          />
+        )}
+
         {/* Method 多选 */}
         {condition.type === 'method' && (
           <MultiValueSelector
@@ -1,5 +1,9 @@
+import { useEffect, useState } from 'react'
 import { Button } from '@/components/ui/button'
+import { Input } from '@/components/ui/input'
+import { Switch } from '@/components/ui/switch'
 import { domain } from '@/../wailsjs/go/models'
+import type { AutoAttachOptions } from '@/stores'
 import { useTranslation } from 'react-i18next'
 import { RefreshCw } from 'lucide-react'
 
@@ -9,6 +13,52 @@ interface TargetsPanelProps {
   onToggle: (id: string) => void
   isConnected: boolean
   onRefresh: () => void
+  autoAttach: AutoAttachOptions
+  onAutoAttachChange: (opts: AutoAttachOptions) => void
+}
+
+// AutoAttachBar 自动附着新打开的标签页、弹窗与窗口
+function AutoAttachBar({ value, onChange }: { value: AutoAttachOptions; onChange: (opts: AutoAttachOptions) => void }) {
+  const { t } = useTranslation()
+  const [pattern, setPattern] = useState(value.urlPattern)
+
+  useEffect(() => {
+    setPattern(value.urlPattern)
+  }, [value.urlPattern])
+
+  // URL 模式在失焦或回车时提交，避免每次输入都重建浏览器级连接
+  const commitPattern = () => {
+    if (pattern.trim() !== value.urlPattern) {
+      onChange({ ...value, urlPattern: pattern.trim() })
+    }
+  }
+
+  return (
+    <div className="flex flex-wrap items-center gap-3 p-3 rounded-lg border">
+      <label className="flex items-center gap-2 text-sm font-medium" title={t('targets.autoAttachDesc')}>
+        <Switch
+          checked={value.enabled}
+          onCheckedChange={(enabled) => onChange({ ...value, enabled, urlPattern: pattern.trim() })}
+        />
+        {t('targets.autoAttach')}
+      </label>
+      <Input
+        className="h-8 flex-1 min-w-[200px] text-xs"
+        placeholder={t('targets.autoAttachPattern')}
+        value={pattern}
+        onChange={(e) => setPattern(e.target.value)}
+        onBlur={commitPattern}
+        onKeyDown={(e) => e.key === 'Enter' && commitPattern()}
+      />
+      <label className="flex items-center gap-2 text-sm text-muted-foreground">
+        <Switch
+          checked={value.openerOnly}
+          onCheckedChange={(openerOnly) => onChange({ ...value, openerOnly, urlPattern: pattern.trim() })}
+        />
+        {t('targets.autoAttachOpenerOnly')}
+      </label>
+    </div>
+  )
 }
 
 export function TargetsPanel({ 
@@ -16,7 +66,9 @@ export function TargetsPanel({
   attachedTargetId, 
   onToggle,
   isConnected,
-  onRefresh
+  onRefresh,
+  autoAttach,
+  onAutoAttachChange
 }: TargetsPanelProps) {
   const { t } = useTranslation()
 
@@ -47,12 +99,15 @@ export function TargetsPanel({
 
   if (targets.length === 0) {
     return (
-      <div className="flex flex-col items-center justify-center h-full gap-4">
-        <div className="text-muted-foreground">{t('targets.noTargets')}</div>
-        <Button variant="outline" onClick={onRefresh}>
-          <RefreshCw className="w-4 h-4 mr-2" />
-          {t('toolbar.refreshTargets')}
-        </Button>
+      <div className="flex flex-col h-full gap-4">
+        <AutoAttachBar value={autoAttach} onChange={onAutoAttachChange} />
+        <div className="flex flex-1 flex-col items-center justify-center gap-4">
+          <div className="text-muted-foreground">{t('targets.noTargets')}</div>
+          <Button variant="outline" onClick={onRefresh}>
+            <RefreshCw className="w-4 h-4 mr-2" />
+            {t('toolbar.refreshTargets')}
+          </Button>
+        </div>
       </div>
     )
   }
@@ -65,6 +120,7 @@ export function TargetsPanel({
           {t('toolbar.refreshTargets')}
         </Button>
       </div>
+      <AutoAttachBar value={autoAttach} onChange={onAutoAttachChange} />
       <div className="space-y-2">
         {targets.map((target) => {
           const urlObj = new URL(target.url)
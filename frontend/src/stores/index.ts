@@ -16,6 +16,7 @@ interface SessionState {
   activeConfigId: number | null
   targets: domain.TargetInfo[]
   attachedTargetId: string | null
+  autoAttach: AutoAttachOptions         // 自动附着新页面（标签页、弹窗与窗口）的选项
   matchedEvents: MatchedEventWithId[]    // 匹配的事件（会存入数据库）
   isTrafficCapturing: boolean           // 是否正在捕获全量流量
   trafficEvents: NetworkEvent[]         // 全量流量列表（仅内存，最近100条）
@@ -36,6 +37,7 @@ interface SessionState {
   // 复杂业务 Actions
   refreshTargets: () => Promise<void>
   toggleTarget: (targetId: string) => Promise<{ success: boolean; message?: string }>
+  setAutoAttach: (opts: AutoAttachOptions) => Promise<{ success: boolean; message?: string }>
   
   // 事件操作
   addInterceptEvent: (event: NetworkEvent) => void
@@ -45,6 +47,15 @@ interface SessionState {
   clearAllEvents: () => void
 }
 
+// 自动附着选项，urlPattern 为空表示不限 URL，openerOnly 表示仅附着由已附着页面打开的页面
+export interface AutoAttachOptions {
+  enabled: boolean
+  urlPattern: string
+  openerOnly: boolean
+}
+
+const defaultAutoAttach: AutoAttachOptions = { enabled: false, urlPattern: '', openerOnly: false }
+
 // 生成事件 ID
 function generateEventId(timestamp: number): string {
   return `${timestamp}_${Math.random().toString(36).slice(2, 10)}`
@@ -58,6 +69,7 @@ export const useSessionStore = create<SessionState>((set, get) => ({
   activeConfigId: null,
   targets: [],
   attachedTargetId: null,
+  autoAttach: defaultAutoAttach,
   matchedEvents: [],
   isTrafficCapturing: false,
   trafficEvents: [],
@@ -78,6 +90,7 @@ export const useSessionStore = create<SessionState>((set, get) => ({
 
   resetSession: () => set({
     attachedTargetId: null,
+    autoAttach: defaultAutoAttach,
     activeConfigId: null,
     isIntercepting: false,
     isTrafficCapturing: false,
@@ -153,6 +166,23 @@ export const useSessionStore = create<SessionState>((set, get) => ({
       return { success: false, message: String(e) }
     }
   },
+
+  // 设置自动附着选项
+  setAutoAttach: async (opts: AutoAttachOptions) => {
+    const { currentSessionId: sessionId } = get()
+    if (!sessionId) return { success: false, message: '会话未启动' }
+
+    try {
+      const result = await api.browser.setAutoAttach(sessionId, opts.enabled, opts.urlPattern, opts.openerOnly)
+      if (result?.success) {
+        set({ autoAttach: opts })
+        return { success: true }
+      }
+      return { success: false, message: result?.message }
+    } catch (e) {
+      return { success: false, message: String(e) }
+    }
+  },
   
   // 添加事件
   addInterceptEvent: (event) => set((state) => {
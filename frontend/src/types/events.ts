@@ -14,6 +14,7 @@ export interface Response {
   statusCode: number
   headers: Record<string, string>
   body: string
+  bodyOmitted?: boolean  // 未读取响应体（无规则需要或体积过大）
   timing?: {
     startTime: number  // 开始时间
     endTime: number    // 结束时间
@@ -36,7 +37,7 @@ export interface NetworkEvent {
   isMatched: boolean
   request: Request
   response?: Response
-  finalResult?: 'blocked' | 'modified' | 'passed'
+  finalResult?: 'blocked' | 'failed' | 'modified' | 'passed'
   matchedRules?: RuleMatch[]
 }
 
@@ -51,11 +52,12 @@ export interface MatchedEventWithId extends MatchedEvent {
 }
 
 // 结果类型标签和颜色
-export type FinalResultType = 'blocked' | 'modified' | 'passed'
+export type FinalResultType = 'blocked' | 'failed' | 'modified' | 'passed'
 
 // 结果类型标签
 export const FINAL_RESULT_LABELS: Record<FinalResultType, string> = {
   blocked: '阻断',
+  failed: '网络错误',
   modified: '修改',
   passed: '放行',
 }
@@ -63,6 +65,7 @@ export const FINAL_RESULT_LABELS: Record<FinalResultType, string> = {
 // 结果类型颜色
 export const FINAL_RESULT_COLORS: Record<FinalResultType, { bg: string; text: string }> = {
   blocked: { bg: 'bg-red-500/20', text: 'text-red-500' },
+  failed: { bg: 'bg-orange-500/20', text: 'text-orange-500' },
   modified: { bg: 'bg-yellow-500/20', text: 'text-yellow-500' },
   passed: { bg: 'bg-green-500/20', text: 'text-green-500' },
 }
@@ -41,6 +41,55 @@ export type ConditionType =
   | 'bodyContains'
   | 'bodyRegex'
   | 'bodyJsonPath'
+  // 响应条件（仅响应阶段）
+  | 'statusCode'
+  | 'statusCodeRange'
+  | 'responseHeaderExists'
+  | 'responseHeaderNotExists'
+  | 'responseHeaderEquals'
+  | 'responseHeaderContains'
+  | 'responseHeaderRegex'
+  | 'responseBodyContains'
+  | 'responseBodyRegex'
+  | 'responseBodyJsonPath'
+  | 'mimeType'
+  // 结构化 URL 条件
+  | 'urlHost'
+  | 'urlPath'
+  | 'urlScheme'
+  | 'urlPort'
+  | 'urlNoFragment'
+  | 'urlPattern'
+  // 页面与目标条件
+  | 'targetId'
+  | 'pageUrlContains'
+  | 'pageUrlPattern'
+  | 'pageUrlRegex'
+  | 'pageTitleContains'
+  | 'pageTitleRegex'
+  | 'frameUrlContains'
+  | 'frameUrlRegex'
+  // 表单条件（urlencoded / multipart）
+  | 'formFieldExists'
+  | 'formFieldEquals'
+  | 'formFieldRegex'
+  // GraphQL 条件
+  | 'graphqlOperationName'
+  | 'graphqlOperationType'
+  | 'graphqlVariable'
+  // 会话状态条件
+  | 'scenarioState'
+  | 'variableExists'
+  | 'variableEquals'
+
+// JSON Path 条件比较运算符
+export type JsonPathOperator =
+  | 'eq' | 'ne' | 'gt' | 'gte' | 'lt' | 'lte'
+  | 'exists' | 'notExists' | 'regex' | 'in' | 'contains'
+
+export const JSON_PATH_OPERATORS: JsonPathOperator[] = [
+  'eq', 'ne', 'gt', 'gte', 'lt', 'lte', 'exists', 'notExists', 'regex', 'in', 'contains'
+]
 
 // 条件定义
 export interface Condition {
@@ -50,11 +99,18 @@ export interface Condition {
   pattern?: string       // urlRegex, *Regex
   name?: string          // header*, query*, cookie*
   path?: string          // bodyJsonPath
+  operator?: JsonPathOperator // bodyJsonPath 比较运算符，默认 eq
+  negate?: boolean       // 结果取反
+  // 嵌套条件组（任一非空时忽略 type）
+  allOf?: Condition[]
+  anyOf?: Condition[]
+  noneOf?: Condition[]
 }
 
 export interface Match {
   allOf?: Condition[]    // AND 逻辑
   anyOf?: Condition[]    // OR 逻辑
+  noneOf?: Condition[]   // NOR 逻辑
 }
 
 // V2 细粒度行为类型（15种）
@@ -68,7 +124,11 @@ export type ActionType =
   | 'removeCookie'
   | 'setFormField'
   | 'removeFormField'
+  | 'setFormFile'
+  | 'patchGraphqlVariables'
   | 'block'
+  | 'mapLocal'
+  | 'mapRemote'
   // 响应阶段专用
   | 'setStatus'
   // 通用
@@ -78,10 +138,34 @@ export type ActionType =
   | 'appendBody'
   | 'replaceBodyText'
   | 'patchBodyJson'
+  | 'mergeBodyJson'
+  | 'mockGraphql'
+  // 会话状态（两阶段通用）
+  | 'setVariable'
+  | 'extractVariable'
+  | 'setScenarioState'
+  // 网络模拟（两阶段通用）
+  | 'delay'
+  | 'throttle'
+  | 'fail'
 
 // Body 编码方式
 export type BodyEncoding = 'text' | 'base64'
 
+// 网络错误原因（与 CDP Network.ErrorReason 一致）
+export type ErrorReason =
+  | 'Failed' | 'Aborted' | 'TimedOut' | 'AccessDenied'
+  | 'ConnectionClosed' | 'ConnectionReset' | 'ConnectionRefused' | 'ConnectionAborted' | 'ConnectionFailed'
+  | 'NameNotResolved' | 'InternetDisconnected' | 'AddressUnreachable'
+  | 'BlockedByClient' | 'BlockedByResponse'
+
+export const ERROR_REASONS: ErrorReason[] = [
+  'Failed', 'Aborted', 'TimedOut', 'AccessDenied',
+  'ConnectionClosed', 'ConnectionReset', 'ConnectionRefused', 'ConnectionAborted', 'ConnectionFailed',
+  'NameNotResolved', 'InternetDisconnected', 'AddressUnreachable',
+  'BlockedByClient', 'BlockedByResponse'
+]
+
 // JSON Patch 操作
 export interface JSONPatchOp {
   op: 'add' | 'remove' | 'replace' | 'move' | 'copy' | 'test'
@@ -93,17 +177,34 @@ export interface JSONPatchOp {
 // 行为定义
 export interface Action {
   type: ActionType
-  value?: string | number       // setUrl, setMethod, setStatus, setBody, setHeader, setQueryParam, setCookie, setFormField
-  name?: string                 // setHeader, removeHeader, setQueryParam, removeQueryParam, setCookie, removeCookie, setFormField, removeFormField
-  encoding?: BodyEncoding       // setBody
+  value?: string | number       // setUrl, setMethod, setStatus, setBody, setHeader, setQueryParam, setCookie, setFormField, setFormFile, setVariable, setScenarioState, mergeBodyJson
+  name?: string                 // setHeader, removeHeader, setQueryParam, removeQueryParam, setCookie, removeCookie, setFormField, removeFormField, setFormFile, *Variable, setScenarioState
+  path?: string                 // extractVariable
+  encoding?: BodyEncoding       // setBody, setFormFile
+  fileName?: string             // setFormFile
+  contentType?: string          // setFormFile
   search?: string               // replaceBodyText
   replace?: string              // replaceBodyText
   replaceAll?: boolean          // replaceBodyText
-  patches?: JSONPatchOp[]       // patchBodyJson
-  statusCode?: number           // block
-  headers?: Record<string, string>  // block
+  patches?: JSONPatchOp[]       // patchBodyJson, patchGraphqlVariables
+  operationName?: string        // patchGraphqlVariables, mockGraphql，为空表示全部操作
+  data?: any                    // mockGraphql
+  errors?: any                  // mockGraphql，字符串视为单条错误信息
+  statusCode?: number           // block, mapLocal
+  headers?: Record<string, string>  // block, mapLocal
   body?: string                 // block
   bodyEncoding?: BodyEncoding   // block
+  localPath?: string            // mapLocal，本地文件或目录
+  urlPrefix?: string            // mapLocal，映射到目录的 URL 前缀
+  from?: string                 // mapRemote，为空时替换协议、主机与端口
+  to?: string                   // mapRemote
+  preserveHost?: boolean        // mapRemote
+  rewriteOrigin?: boolean       // mapRemote
+  delayMs?: number              // delay
+  delayMaxMs?: number           // delay，大于 delayMs 时在区间内随机
+  bytesPerSecond?: number       // throttle
+  errorReason?: ErrorReason     // fail，默认 Failed
+  template?: boolean            // 将 value、replace 与 block 的 body/headers 作为模板渲染
 }
 
 export interface Rule {
@@ -114,6 +215,27 @@ export interface Rule {
   stage: Stage
   match: Match
   actions: Action[]
+  group?: string                // 所属分组名称
+  stopAfterMatch?: boolean      // 匹配后不再执行同阶段的后续规则
+  limit?: RuleLimit             // 命中限制
+}
+
+// 规则命中限制
+export interface RuleLimit {
+  expiresAt?: number            // 过期时间（Unix 毫秒）
+  everyNth?: number             // 每第 k 次匹配生效一次
+  maxHits?: number              // 最多生效 N 次
+  probability?: number          // 生效概率 (0, 1)
+  seed?: number                 // 概率随机数种子
+}
+
+// 规则分组执行模式
+export type GroupMode = 'all' | 'first'
+
+// 规则分组定义
+export interface RuleGroup {
+  name: string
+  mode: GroupMode
 }
 
 // 配置版本常量
@@ -126,6 +248,7 @@ export interface Config {
   version: string                 // 配置格式版本
   description: string             // 配置描述
   settings: Record<string, any>   // 预留设置项
+  groups?: RuleGroup[]            // 规则分组定义
   rules: Rule[]                   // 规则列表
 }
 
@@ -164,7 +287,17 @@ export const CONDITION_GROUPS = {
   header: ['headerExists', 'headerNotExists', 'headerEquals', 'headerContains', 'headerRegex'],
   query: ['queryExists', 'queryNotExists', 'queryEquals', 'queryContains', 'queryRegex'],
   cookie: ['cookieExists', 'cookieNotExists', 'cookieEquals', 'cookieContains', 'cookieRegex'],
-  body: ['bodyContains', 'bodyRegex', 'bodyJsonPath']
+  body: ['bodyContains', 'bodyRegex', 'bodyJsonPath'],
+  response: [
+    'statusCode', 'statusCodeRange', 'mimeType',
+    'responseHeaderExists', 'responseHeaderNotExists', 'responseHeaderEquals', 'responseHeaderContains', 'responseHeaderRegex',
+    'responseBodyContains', 'responseBodyRegex', 'responseBodyJsonPath'
+  ],
+  urlParts: ['urlHost', 'urlPath', 'urlScheme', 'urlPort', 'urlNoFragment', 'urlPattern'],
+  page: ['targetId', 'pageUrlContains', 'pageUrlPattern', 'pageUrlRegex', 'pageTitleContains', 'pageTitleRegex', 'frameUrlContains', 'frameUrlRegex'],
+  form: ['formFieldExists', 'formFieldEquals', 'formFieldRegex'],
+  graphql: ['graphqlOperationName', 'graphqlOperationType', 'graphqlVariable'],
+  state: ['scenarioState', 'variableExists', 'variableEquals']
 } as const
 
 // 条件类型标签
@@ -203,7 +336,41 @@ export const CONDITION_TYPE_LABELS: Record<ConditionType, string> = {
   cookieRegex: 'Cookie 正则匹配',
   bodyContains: 'Body 包含',
   bodyRegex: 'Body 正则匹配',
-  bodyJsonPath: 'JSON Path 匹配'
+  bodyJsonPath: 'JSON Path 匹配',
+  statusCode: '状态码',
+  statusCodeRange: '状态码范围',
+  responseHeaderExists: '响应 Header 存在',
+  responseHeaderNotExists: '响应 Header 不存在',
+  responseHeaderEquals: '响应 Header 精确匹配',
+  responseHeaderContains: '响应 Header 包含',
+  responseHeaderRegex: '响应 Header 正则匹配',
+  responseBodyContains: '响应 Body 包含',
+  responseBodyRegex: '响应 Body 正则匹配',
+  responseBodyJsonPath: '响应 JSON Path 匹配',
+  mimeType: '响应 MIME 类型',
+  urlHost: 'URL 主机名匹配',
+  urlPath: 'URL 路径 Glob 匹配',
+  urlScheme: 'URL 协议匹配',
+  urlPort: 'URL 端口匹配',
+  urlNoFragment: 'URL 去片段精确匹配',
+  urlPattern: 'URL 匹配模式',
+  targetId: '目标 ID',
+  pageUrlContains: '页面 URL 包含',
+  pageUrlPattern: '页面 URL 模式',
+  pageUrlRegex: '页面 URL 正则',
+  pageTitleContains: '页面标题包含',
+  pageTitleRegex: '页面标题正则',
+  frameUrlContains: 'Frame URL 包含',
+  frameUrlRegex: 'Frame URL 正则',
+  formFieldExists: '表单字段存在',
+  formFieldEquals: '表单字段等于',
+  formFieldRegex: '表单字段正则',
+  graphqlOperationName: 'GraphQL 操作名',
+  graphqlOperationType: 'GraphQL 操作类型',
+  graphqlVariable: 'GraphQL 变量',
+  scenarioState: '场景状态',
+  variableExists: '变量存在',
+  variableEquals: '变量等于'
 }
 
 // 保留原常量供兼容
@@ -232,21 +399,60 @@ export const CONDITION_TYPE_SHORT_LABELS: Record<ConditionType, string> = {
   cookieRegex: 'Cookie 正则',
   bodyContains: 'Body 含',
   bodyRegex: 'Body 正则',
-  bodyJsonPath: 'JSON Path'
+  bodyJsonPath: 'JSON Path',
+  statusCode: '状态码',
+  statusCodeRange: '状态码范围',
+  responseHeaderExists: '响应头存在',
+  responseHeaderNotExists: '响应头不存在',
+  responseHeaderEquals: '响应头 =',
+  responseHeaderContains: '响应头含',
+  responseHeaderRegex: '响应头正则',
+  responseBodyContains: '响应体含',
+  responseBodyRegex: '响应体正则',
+  responseBodyJsonPath: '响应 JSON Path',
+  mimeType: 'MIME',
+  urlHost: '主机',
+  urlPath: '路径',
+  urlScheme: '协议',
+  urlPort: '端口',
+  urlNoFragment: 'URL 去#',
+  urlPattern: 'URL 模式',
+  targetId: '目标',
+  pageUrlContains: '页面URL包含',
+  pageUrlPattern: '页面URL模式',
+  pageUrlRegex: '页面URL正则',
+  pageTitleContains: '标题包含',
+  pageTitleRegex: '标题正则',
+  frameUrlContains: 'FrameURL包含',
+  frameUrlRegex: 'FrameURL正则',
+  formFieldExists: '字段存在',
+  formFieldEquals: '字段等于',
+  formFieldRegex: '字段正则',
+  graphqlOperationName: '操作名',
+  graphqlOperationType: '操作类型',
+  graphqlVariable: '变量',
+  scenarioState: '场景状态',
+  variableExists: '变量存在',
+  variableEquals: '变量等于'
 }
 
 // 请求阶段可用行为
 export const REQUEST_ACTIONS: ActionType[] = [
-  'setUrl', 'setMethod', 'setHeader', 'removeHeader',
+  'setUrl', 'mapRemote', 'setMethod', 'setHeader', 'removeHeader',
   'setQueryParam', 'removeQueryParam', 'setCookie', 'removeCookie',
-  'setBody', 'appendBody', 'replaceBodyText', 'patchBodyJson',
-  'setFormField', 'removeFormField', 'block'
+  'setBody', 'appendBody', 'replaceBodyText', 'patchBodyJson', 'mergeBodyJson',
+  'setFormField', 'removeFormField', 'setFormFile',
+  'patchGraphqlVariables', 'mockGraphql',
+  'setVariable', 'extractVariable', 'setScenarioState',
+  'delay', 'throttle', 'block', 'mapLocal', 'fail'
 ]
 
 // 响应阶段可用行为
 export const RESPONSE_ACTIONS: ActionType[] = [
   'setStatus', 'setHeader', 'removeHeader',
-  'setBody', 'appendBody', 'replaceBodyText', 'patchBodyJson'
+  'setBody', 'appendBody', 'replaceBodyText', 'patchBodyJson', 'mergeBodyJson', 'mockGraphql',
+  'setVariable', 'extractVariable', 'setScenarioState',
+  'delay', 'throttle', 'fail'
 ]
 
 // 行为类型标签
@@ -257,6 +463,7 @@ export function getActionTypeLabel(type: ActionType): string {
 // 保留原常量供兼容
 export const ACTION_TYPE_LABELS: Record<ActionType, string> = {
   setUrl: '设置 URL',
+  mapRemote: '映射远程地址',
   setMethod: '设置 Method',
   setHeader: '设置 Header',
   removeHeader: '移除 Header',
@@ -268,14 +475,25 @@ export const ACTION_TYPE_LABELS: Record<ActionType, string> = {
   appendBody: '追加 Body',
   replaceBodyText: '文本替换 Body',
   patchBodyJson: 'JSON Patch',
+  mergeBodyJson: 'JSON Merge Patch',
   setFormField: '设置表单字段',
   removeFormField: '移除表单字段',
+  setFormFile: '修改表单文件',
+  patchGraphqlVariables: '修改 GraphQL 变量',
+  mockGraphql: 'Mock GraphQL 结果',
+  setVariable: '设置变量',
+  extractVariable: '提取变量',
+  setScenarioState: '切换场景状态',
+  delay: '延迟',
+  throttle: '限速',
+  fail: '网络错误',
   setStatus: '设置状态码',
-  block: '拦截请求'
+  block: '拦截请求',
+  mapLocal: '映射本地文件'
 }
 
 // 终结性行为
-export const TERMINAL_ACTIONS: ActionType[] = ['block']
+export const TERMINAL_ACTIONS: ActionType[] = ['block', 'mapLocal', 'fail']
 
 // 创建空条件
 export function createEmptyCondition(type: ConditionType = 'urlPrefix'): Condition {
@@ -288,10 +506,41 @@ export function createEmptyCondition(type: ConditionType = 'urlPrefix'): Conditi
   if (type === 'resourceType') {
     return { ...base, values: ['xhr', 'fetch'] }
   }
+  if (type === 'statusCode') {
+    return { ...base, values: ['500'] }
+  }
+  if (type === 'urlHost') {
+    return { ...base, values: ['*.example.com'] }
+  }
+  if (type === 'urlScheme') {
+    return { ...base, values: ['https'] }
+  }
+  if (type === 'urlPort') {
+    return { ...base, values: ['443'] }
+  }
+  if (type === 'targetId' || type === 'graphqlOperationName') {
+    return { ...base, values: [] }
+  }
+  if (type === 'graphqlOperationType') {
+    return { ...base, values: ['query'] }
+  }
+  if (type === 'graphqlVariable') {
+    return { ...base, path: '', value: '' }
+  }
+  if (type === 'scenarioState') {
+    return { ...base, name: '', values: ['Started'] }
+  }
+  if (type === 'statusCodeRange') {
+    return { ...base, value: '500-599' }
+  }
+  if (type === 'mimeType') {
+    return { ...base, values: ['application/json'] }
+  }
   if (type.endsWith('Regex')) {
     return { ...base, pattern: '' }
   }
-  if (type.startsWith('header') || type.startsWith('query') || type.startsWith('cookie')) {
+  if (type.startsWith('header') || type.startsWith('query') || type.startsWith('cookie') || type.startsWith('responseHeader') ||
+    type.startsWith('formField') || type.startsWith('variable')) {
     if (type.endsWith('Exists') || type.endsWith('NotExists')) {
       return { ...base, name: '' }
     }
@@ -300,7 +549,7 @@ export function createEmptyCondition(type: ConditionType = 'urlPrefix'): Conditi
     }
     return { ...base, name: '', value: '' }
   }
-  if (type === 'bodyJsonPath') {
+  if (type === 'bodyJsonPath' || type === 'responseBodyJsonPath') {
     return { ...base, path: '', value: '' }
   }
 
@@ -326,10 +575,33 @@ export function createEmptyAction(type: ActionType = 'setHeader', _stage: Stage
     case 'setBody':
     case 'appendBody':
       return { type, value: '', encoding: 'text' }
+    case 'setFormFile':
+      return { type, name: '', fileName: '', contentType: '', value: '', encoding: 'text' }
     case 'replaceBodyText':
       return { type, search: '', replace: '', replaceAll: false }
     case 'patchBodyJson':
       return { type, patches: [] }
+    case 'mergeBodyJson':
+      return { type, value: '{}' }
+    case 'patchGraphqlVariables':
+      return { type, operationName: '', patches: [] }
+    case 'mockGraphql':
+      return { type, operationName: '', data: {} }
+    case 'setVariable':
+    case 'setScenarioState':
+      return { type, name: '', value: '' }
+    case 'extractVariable':
+      return { type, name: '', path: '' }
+    case 'delay':
+      return { type, delayMs: 1000 }
+    case 'throttle':
+      return { type, bytesPerSecond: 51200 }
+    case 'fail':
+      return { type, errorReason: 'ConnectionRefused' }
+    case 'mapLocal':
+      return { type, localPath: '', urlPrefix: '' }
+    case 'mapRemote':
+      return { type, from: '', to: 'http://localhost:8080' }
     case 'setStatus':
       return { type, value: 200 }
     case 'block':
@@ -373,24 +645,31 @@ export function getActionsForStage(stage: Stage): ActionType[] {
 }
 
 // 获取条件需要的字段
-export function getConditionFields(type: ConditionType): ('value' | 'values' | 'pattern' | 'name' | 'path')[] {
-  if (type === 'method' || type === 'resourceType') {
+export function getConditionFields(type: ConditionType): ('value' | 'values' | 'pattern' | 'name' | 'path' | 'operator')[] {
+  if (type === 'method' || type === 'resourceType' || type === 'statusCode' || type === 'mimeType' ||
+    type === 'urlHost' || type === 'urlScheme' || type === 'urlPort' || type === 'targetId' ||
+    type === 'graphqlOperationName' || type === 'graphqlOperationType') {
     return ['values']
   }
   if (type.endsWith('Regex')) {
-    if (type.startsWith('url') || type.startsWith('body')) {
+    if (type.startsWith('url') || type.startsWith('body') || type.startsWith('responseBody') ||
+      type.startsWith('page') || type.startsWith('frame')) {
       return ['pattern']
     }
     return ['name', 'pattern']
   }
+  if (type === 'scenarioState') {
+    return ['name', 'values']
+  }
   if (type.endsWith('Exists') || type.endsWith('NotExists')) {
     return ['name']
   }
-  if (type.startsWith('header') || type.startsWith('query') || type.startsWith('cookie')) {
+  if (type.startsWith('header') || type.startsWith('query') || type.startsWith('cookie') || type.startsWith('responseHeader') ||
+    type.startsWith('formField') || type.startsWith('variable')) {
     return ['name', 'value']
   }
-  if (type === 'bodyJsonPath') {
-    return ['path', 'value']
+  if (type === 'bodyJsonPath' || type === 'responseBodyJsonPath' || type === 'graphqlVariable') {
+    return ['path', 'operator', 'value']
   }
   return ['value']
 }
@@ -20,6 +20,8 @@ export function EnableInterception(arg1:string):Promise<api.Response_cdpnetool_p
 
 export function EnableTrafficCapture(arg1:string,arg2:boolean):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
 
+export function ExplainRules(arg1:string,arg2:string,arg3:string):Promise<api.Response_cdpnetool_internal_gui_ExplainData_>;
+
 export function ExportConfig(arg1:string,arg2:string):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
 
 export function GenerateNewRule(arg1:string,arg2:number):Promise<api.Response_cdpnetool_internal_gui_NewRuleData_>;
@@ -40,6 +42,8 @@ export function GetLogDirectory():Promise<api.Response_cdpnetool_internal_gui_Se
 
 export function GetRuleStats(arg1:string):Promise<api.Response_cdpnetool_internal_gui_StatsData_>;
 
+export function GetSessionState(arg1:string):Promise<api.Response_cdpnetool_internal_gui_SessionStateData_>;
+
 export function GetSetting(arg1:string):Promise<api.Response_cdpnetool_internal_gui_SettingData_>;
 
 export function GetSettings():Promise<api.Response_cdpnetool_internal_gui_SettingsData_>;
@@ -64,6 +68,10 @@ export function QueryMatchedEventHistory(arg1:string,arg2:string,arg3:string,arg
 
 export function RenameConfig(arg1:number,arg2:string):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
 
+export function ResetRuleStats(arg1:string):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
+
+export function ResetSessionState(arg1:string):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
+
 export function ResetSettings():Promise<api.Response_cdpnetool_internal_gui_SettingsData_>;
 
 export function SaveConfig(arg1:number,arg2:string):Promise<api.Response_cdpnetool_internal_gui_ConfigData_>;
@@ -74,6 +82,8 @@ export function SelectBrowserPath():Promise<api.Response_cdpnetool_internal_gui_
 
 export function SetActiveConfig(arg1:number):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
 
+export function SetAutoAttach(arg1:string,arg2:boolean,arg3:string,arg4:boolean):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
+
 export function SetDirty(arg1:boolean):Promise<void>;
 
 export function SetMultipleSettings(arg1:string):Promise<api.Response_cdpnetool_pkg_api_EmptyData_>;
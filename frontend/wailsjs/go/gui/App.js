@@ -38,6 +38,10 @@ export function EnableTrafficCapture(arg1, arg2) {
   return window['go']['gui']['App']['EnableTrafficCapture'](arg1, arg2);
 }
 
+export function ExplainRules(arg1, arg2, arg3) {
+  return window['go']['gui']['App']['ExplainRules'](arg1, arg2, arg3);
+}
+
 export function ExportConfig(arg1, arg2) {
   return window['go']['gui']['App']['ExportConfig'](arg1, arg2);
 }
@@ -78,6 +82,10 @@ export function GetRuleStats(arg1) {
   return window['go']['gui']['App']['GetRuleStats'](arg1);
 }
 
+export function GetSessionState(arg1) {
+  return window['go']['gui']['App']['GetSessionState'](arg1);
+}
+
 export function GetSetting(arg1) {
   return window['go']['gui']['App']['GetSetting'](arg1);
 }
@@ -126,6 +134,14 @@ export function RenameConfig(arg1, arg2) {
   return window['go']['gui']['App']['RenameConfig'](arg1, arg2);
 }
 
+export function ResetRuleStats(arg1) {
+  return window['go']['gui']['App']['ResetRuleStats'](arg1);
+}
+
+export function ResetSessionState(arg1) {
+  return window['go']['gui']['App']['ResetSessionState'](arg1);
+}
+
 export function ResetSettings() {
   return window['go']['gui']['App']['ResetSettings']();
 }
@@ -146,6 +162,10 @@ export function SetActiveConfig(arg1) {
   return window['go']['gui']['App']['SetActiveConfig'](arg1);
 }
 
+export function SetAutoAttach(arg1, arg2, arg3, arg4) {
+  return window['go']['gui']['App']['SetAutoAttach'](arg1, arg2, arg3, arg4);
+}
+
 export function SetDirty(arg1) {
   return window['go']['gui']['App']['SetDirty'](arg1);
 }
@@ -144,6 +144,42 @@ export namespace api {
 		    return a;
 		}
 	}
+	export class Response_cdpnetool_internal_gui_ExplainData_ {
+	    success: boolean;
+	    code?: string;
+	    message?: string;
+	    data?: gui.ExplainData;
+	
+	    static createFrom(source: any = {}) {
+	        return new Response_cdpnetool_internal_gui_ExplainData_(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.success = source["success"];
+	        this.code = source["code"];
+	        this.message = source["message"];
+	        this.data = this.convertValues(source["data"], gui.ExplainData);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Response_cdpnetool_internal_gui_NewConfigData_ {
 	    success: boolean;
 	    code?: string;
@@ -252,6 +288,42 @@ export namespace api {
 		    return a;
 		}
 	}
+	export class Response_cdpnetool_internal_gui_SessionStateData_ {
+	    success: boolean;
+	    code?: string;
+	    message?: string;
+	    data?: gui.SessionStateData;
+	
+	    static createFrom(source: any = {}) {
+	        return new Response_cdpnetool_internal_gui_SessionStateData_(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.success = source["success"];
+	        this.code = source["code"];
+	        this.message = source["message"];
+	        this.data = this.convertValues(source["data"], gui.SessionStateData);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Response_cdpnetool_internal_gui_SettingData_ {
 	    success: boolean;
 	    code?: string;
@@ -486,10 +558,53 @@ export namespace api {
 
 export namespace domain {
 	
+	export class ConditionExplain {
+	    group: string;
+	    type?: string;
+	    negate?: boolean;
+	    matched: boolean;
+	    reason: string;
+	    children?: ConditionExplain[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ConditionExplain(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.group = source["group"];
+	        this.type = source["type"];
+	        this.negate = source["negate"];
+	        this.matched = source["matched"];
+	        this.reason = source["reason"];
+	        this.children = this.convertValues(source["children"], ConditionExplain);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class EngineStats {
 	    total: number;
 	    matched: number;
 	    byRule: Record<string, number>;
+	    byStage: Record<string, StageStats>;
+	    rules: Record<string, RuleStats>;
+	    resetAt: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new EngineStats(source);
@@ -500,6 +615,285 @@ export namespace domain {
 	        this.total = source["total"];
 	        this.matched = source["matched"];
 	        this.byRule = source["byRule"];
+	        this.byStage = this.convertValues(source["byStage"], StageStats, true);
+	        this.rules = this.convertValues(source["rules"], RuleStats, true);
+	        this.resetAt = source["resetAt"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ExplainResult {
+	    rules: RuleExplain[];
+	    blocked: boolean;
+	    failReason?: string;
+	    request?: Request;
+	    response?: Response;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExplainResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.rules = this.convertValues(source["rules"], RuleExplain);
+	        this.blocked = source["blocked"];
+	        this.failReason = source["failReason"];
+	        this.request = this.convertValues(source["request"], Request);
+	        this.response = this.convertValues(source["response"], Response);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PageContext {
+	    targetId: string;
+	    pageUrl?: string;
+	    pageTitle?: string;
+	    frameUrl?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PageContext(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.targetId = source["targetId"];
+	        this.pageUrl = source["pageUrl"];
+	        this.pageTitle = source["pageTitle"];
+	        this.frameUrl = source["frameUrl"];
+	    }
+	}
+	export class QueryParam {
+	    key: string;
+	    value: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new QueryParam(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.value = source["value"];
+	    }
+	}
+	export class Request {
+	    id: string;
+	    url: string;
+	    method: string;
+	    headers: Record<string, string>;
+	    body: number[];
+	    resourceType?: string;
+	    query?: QueryParam[];
+	    cookies?: Record<string, string>;
+	    page?: PageContext;
+	
+	    static createFrom(source: any = {}) {
+	        return new Request(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.url = source["url"];
+	        this.method = source["method"];
+	        this.headers = source["headers"];
+	        this.body = source["body"];
+	        this.resourceType = source["resourceType"];
+	        this.query = this.convertValues(source["query"], QueryParam);
+	        this.cookies = source["cookies"];
+	        this.page = this.convertValues(source["page"], PageContext);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Response {
+	    statusCode: number;
+	    headers: Record<string, string>;
+	    body: number[];
+	    timing?: ResponseTiming;
+	
+	    static createFrom(source: any = {}) {
+	        return new Response(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.statusCode = source["statusCode"];
+	        this.headers = source["headers"];
+	        this.body = source["body"];
+	        this.timing = this.convertValues(source["timing"], ResponseTiming);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ResponseTiming {
+	    startTime: number;
+	    endTime: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ResponseTiming(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.startTime = source["startTime"];
+	        this.endTime = source["endTime"];
+	    }
+	}
+	export class RuleExplain {
+	    ruleId: string;
+	    ruleName: string;
+	    stage: string;
+	    matched: boolean;
+	    applied: boolean;
+	    reason?: string;
+	    conditions?: ConditionExplain[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RuleExplain(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ruleId = source["ruleId"];
+	        this.ruleName = source["ruleName"];
+	        this.stage = source["stage"];
+	        this.matched = source["matched"];
+	        this.applied = source["applied"];
+	        this.reason = source["reason"];
+	        this.conditions = this.convertValues(source["conditions"], ConditionExplain);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RuleStats {
+	    hits: number;
+	    lastMatchAt: number;
+	    applyTimeUs: number;
+	    actionErrors: number;
+	    lastError?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RuleStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hits = source["hits"];
+	        this.lastMatchAt = source["lastMatchAt"];
+	        this.applyTimeUs = source["applyTimeUs"];
+	        this.actionErrors = source["actionErrors"];
+	        this.lastError = source["lastError"];
+	    }
+	}
+	export class SessionState {
+	    variables: Record<string, string>;
+	    scenarios: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new SessionState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.variables = source["variables"];
+	        this.scenarios = source["scenarios"];
+	    }
+	}
+	export class StageStats {
+	    total: number;
+	    matched: number;
+	    evalTimeUs: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StageStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.total = source["total"];
+	        this.matched = source["matched"];
+	        this.evalTimeUs = source["evalTimeUs"];
 	    }
 	}
 	export class TargetInfo {
@@ -631,6 +1025,36 @@ export namespace gui {
 		    return a;
 		}
 	}
+	export class ExplainData {
+	    result?: domain.ExplainResult;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExplainData(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.result = this.convertValues(source["result"], domain.ExplainResult);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class NewConfigData {
 	    config?: model.ConfigRecord;
 	    configJson: string;
@@ -687,6 +1111,36 @@ export namespace gui {
 	        this.sessionId = source["sessionId"];
 	    }
 	}
+	export class SessionStateData {
+	    state: domain.SessionState;
+	
+	    static createFrom(source: any = {}) {
+	        return new SessionStateData(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = this.convertValues(source["state"], domain.SessionState);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class SettingData {
 	    value: string;
 	
@@ -0,0 +1,198 @@
+package cdp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"cdpnetool/pkg/domain"
+
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/devtool"
+	"github.com/mafredri/cdp/protocol/target"
+	"github.com/mafredri/cdp/rpcc"
+)
+
+// TargetEvent 浏览器级连接上报的页面目标信息
+type TargetEvent struct {
+	ID       domain.TargetID
+	URL      string
+	OpenerID domain.TargetID // 打开该页面的目标，为空表示不是由其他页面打开
+	New      bool            // 新创建且已暂停、等待附着的页面；监听开始前已存在的页面为 false
+}
+
+// TargetHandlers 浏览器级页面目标事件的处理函数，均可为空
+type TargetHandlers struct {
+	Attached  func(ev TargetEvent)     // 页面出现，New 为 true 时页面在函数返回后才恢复运行
+	Changed   func(ev TargetEvent)     // 页面信息（如 URL）变化
+	Destroyed func(id domain.TargetID) // 页面关闭
+}
+
+// WatchTargets 建立浏览器级连接监听页面目标，直到 ctx 取消
+// 通过 Target.setAutoAttach（waitForDebuggerOnStart）使新建的标签页、弹窗与窗口在发出首个请求前暂停，
+// Attached 返回后再恢复运行，保证在其中附着并开启的拦截覆盖页面的首个请求
+func (m *ClientManager) WatchTargets(ctx context.Context, h TargetHandlers) error {
+	v, err := devtool.New(m.devtoolsURL).Version(ctx)
+	if err != nil {
+		m.log.Err(err, "获取浏览器版本信息失败")
+		return err
+	}
+	if v.WebSocketDebuggerURL == "" {
+		return fmt.Errorf("cdp: browser websocket url not available")
+	}
+
+	conn, err := rpcc.DialContext(ctx, v.WebSocketDebuggerURL,
+		rpcc.WithWriteBufferSize(1<<20),
+		rpcc.WithCodec(newFlatCodec))
+	if err != nil {
+		m.log.Err(err, "浏览器级 CDP 连接建立失败", "wsURL", v.WebSocketDebuggerURL)
+		return err
+	}
+	client := cdp.NewClient(conn)
+	if err := m.subscribeTargets(ctx, conn, client, h); err != nil {
+		conn.Close()
+		m.log.Err(err, "开启目标自动附着失败")
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	m.log.Info("浏览器级目标监听已开启")
+	return nil
+}
+
+// subscribeTargets 订阅目标事件并开启自动附着，事件流在后台消费直到连接关闭
+func (m *ClientManager) subscribeTargets(ctx context.Context, conn *rpcc.Conn, client *cdp.Client, h TargetHandlers) error {
+	attached, err := client.Target.AttachedToTarget(ctx)
+	if err != nil {
+		return err
+	}
+	changed, err := client.Target.TargetInfoChanged(ctx)
+	if err != nil {
+		return err
+	}
+	destroyed, err := client.Target.TargetDestroyed(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Target.SetDiscoverTargets(ctx, target.NewSetDiscoverTargetsArgs(true)); err != nil {
+		return err
+	}
+	pageType := "page"
+	args := target.NewSetAutoAttachArgs(true, true).SetFlatten(true)
+	args.Filter = target.Filter{{Type: &pageType}}
+	if err := client.Target.SetAutoAttach(ctx, args); err != nil {
+		return err
+	}
+
+	go func() {
+		defer attached.Close()
+		for {
+			ev, err := attached.Recv()
+			if err != nil {
+				return
+			}
+			go m.handleAttached(ctx, conn, client, ev, h.Attached)
+		}
+	}()
+	go func() {
+		defer changed.Close()
+		for {
+			ev, err := changed.Recv()
+			if err != nil {
+				return
+			}
+			if h.Changed != nil && ev.TargetInfo.Type == "page" {
+				h.Changed(toTargetEvent(ev.TargetInfo, false))
+			}
+		}
+	}()
+	go func() {
+		defer destroyed.Close()
+		for {
+			ev, err := destroyed.Recv()
+			if err != nil {
+				return
+			}
+			if h.Destroyed != nil {
+				h.Destroyed(domain.TargetID(ev.TargetID))
+			}
+		}
+	}()
+	return nil
+}
+
+// handleAttached 交由处理函数附着页面，随后恢复暂停的页面并断开浏览器级的子会话
+// 页面由处理函数通过独立的 WebSocket 连接附着，子会话仅用于在附着前暂停页面
+func (m *ClientManager) handleAttached(ctx context.Context, conn *rpcc.Conn, client *cdp.Client, ev *target.AttachedToTargetReply, fn func(TargetEvent)) {
+	if fn != nil && ev.TargetInfo.Type == "page" {
+		fn(toTargetEvent(ev.TargetInfo, ev.WaitingForDebugger))
+	}
+
+	ctx2, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if ev.WaitingForDebugger {
+		args := &sessionArgs{sessionID: ev.SessionID}
+		if err := rpcc.Invoke(ctx2, "Runtime.runIfWaitingForDebugger", args, nil, conn); err != nil {
+			m.log.Err(err, "恢复新页面运行失败", "targetID", string(ev.TargetInfo.TargetID))
+		}
+	}
+	sid := ev.SessionID
+	if err := client.Target.DetachFromTarget(ctx2, &target.DetachFromTargetArgs{SessionID: &sid}); err != nil {
+		m.log.Debug("断开自动附着的子会话失败", "targetID", string(ev.TargetInfo.TargetID), "error", err.Error())
+	}
+}
+
+// toTargetEvent 将 CDP 目标信息转换为 TargetEvent
+func toTargetEvent(info target.Info, isNew bool) TargetEvent {
+	ev := TargetEvent{
+		ID:  domain.TargetID(info.TargetID),
+		URL: info.URL,
+		New: isNew,
+	}
+	if info.OpenerID != nil {
+		ev.OpenerID = domain.TargetID(*info.OpenerID)
+	}
+	return ev
+}
+
+// sessionArgs 发往 flatten 模式子会话的命令参数
+type sessionArgs struct {
+	sessionID target.SessionID
+	params    any
+}
+
+// flatCodec 在默认 JSON 编解码的基础上支持以 sessionId 向子会话发送命令（rpcc 不支持 flatten 模式的会话）
+type flatCodec struct {
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// newFlatCodec 创建支持子会话命令的编解码器
+func newFlatCodec(conn io.ReadWriter) rpcc.Codec {
+	return &flatCodec{enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}
+}
+
+// WriteRequest 编码请求，参数为 sessionArgs 时附带 sessionId
+func (c *flatCodec) WriteRequest(r *rpcc.Request) error {
+	sa, ok := r.Args.(*sessionArgs)
+	if !ok {
+		return c.enc.Encode(r)
+	}
+	return c.enc.Encode(struct {
+		ID        uint64           `json:"id"`
+		Method    string           `json:"method"`
+		Params    any              `json:"params,omitempty"`
+		SessionID target.SessionID `json:"sessionId"`
+	}{r.ID, r.Method, sa.params, sa.sessionID})
+}
+
+// ReadResponse 解码响应或事件
+func (c *flatCodec) ReadResponse(r *rpcc.Response) error {
+	return c.dec.Decode(r)
+}
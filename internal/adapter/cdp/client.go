@@ -18,6 +18,7 @@ type TargetSession struct {
 	ID     domain.TargetID
 	Client *cdp.Client
 	Conn   *rpcc.Conn
+	Page   *PageTracker       // 页面上下文跟踪器
 	Ctx    context.Context    // 会话级上下文
 	Cancel context.CancelFunc // 取消函数
 }
@@ -129,6 +130,7 @@ func (m *ClientManager) AttachTarget(ctx context.Context, id domain.TargetID) (*
 		ID:     id,
 		Client: cdp.NewClient(conn),
 		Conn:   conn,
+		Page:   NewPageTracker(id, m.log),
 		Ctx:    sessionCtx,
 		Cancel: sessionCancel,
 	}
@@ -4,14 +4,27 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
-	"strings"
 
 	"cdpnetool/internal/transformer"
 	"cdpnetool/pkg/domain"
 
 	"github.com/mafredri/cdp/protocol/fetch"
+	"github.com/mafredri/cdp/protocol/network"
 )
 
+// cdpResourceTypes 规范资源类型对应的 CDP 资源类型，other 涵盖多种 CDP 类型，无法对应
+var cdpResourceTypes = map[domain.ResourceType]network.ResourceType{
+	domain.ResourceTypeDocument:   network.ResourceTypeDocument,
+	domain.ResourceTypeStylesheet: network.ResourceTypeStylesheet,
+	domain.ResourceTypeImage:      network.ResourceTypeImage,
+	domain.ResourceTypeMedia:      network.ResourceTypeMedia,
+	domain.ResourceTypeFont:       network.ResourceTypeFont,
+	domain.ResourceTypeScript:     network.ResourceTypeScript,
+	domain.ResourceTypeXHR:        network.ResourceTypeXHR,
+	domain.ResourceTypeFetch:      network.ResourceTypeFetch,
+	domain.ResourceTypeWebSocket:  network.ResourceTypeWebSocket,
+}
+
 // ToNeutralRequest 将 CDP 事件转换为领域 Request 模型
 func ToNeutralRequest(ev *fetch.RequestPausedReply) *domain.Request {
 	req := domain.NewRequest()
@@ -22,27 +35,16 @@ func ToNeutralRequest(ev *fetch.RequestPausedReply) *domain.Request {
 	// 使用智能归类函数将 CDP 的 ResourceType 转换为我们的规范类型
 	req.ResourceType = domain.NormalizeResourceType(string(ev.ResourceType), ev.Request.URL)
 
-	// 处理 Header
-	var headers map[string]string
+	// 处理 Header：按原始顺序解析，换行分隔的同名多值拆分为多个字段
 	if len(ev.Request.Headers) > 0 {
-		if err := json.Unmarshal(ev.Request.Headers, &headers); err == nil {
-			for k, v := range headers {
-				req.Headers.Set(k, v)
-			}
+		var headers domain.Header
+		if err := json.Unmarshal(ev.Request.Headers, &headers); err == nil && headers != nil {
+			req.Headers = headers
 		}
 	}
 
 	// 解析 Query 参数
-	if idx := strings.Index(req.URL, "?"); idx != -1 {
-		queryStr := req.URL[idx+1:]
-		if queryStr != "" {
-			for _, pair := range strings.Split(queryStr, "&") {
-				if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
-					req.Query[kv[0]] = kv[1]
-				}
-			}
-		}
-	}
+	req.Query = transformer.ParseQuery(req.URL)
 
 	// 解析 Cookie
 	req.Cookies = transformer.ParseCookies(req.Headers.Get("Cookie"))
@@ -78,18 +80,42 @@ func ToNeutralResponse(ev *fetch.RequestPausedReply, body []byte) *domain.Respon
 	if ev.ResponseStatusCode != nil {
 		res.StatusCode = *ev.ResponseStatusCode
 	}
-	for _, h := range ev.ResponseHeaders {
-		res.Headers.Set(h.Name, h.Value)
-	}
+	res.Headers = FromHeaderEntries(ev.ResponseHeaders)
 	res.Body = body
 	return res
 }
 
-// ToHeaderEntries 将领域 Header 转换为 CDP Header 条目
+// FromHeaderEntries 将 CDP Header 条目转换为领域 Header，保留顺序与重复字段
+func FromHeaderEntries(entries []fetch.HeaderEntry) domain.Header {
+	h := make(domain.Header, 0, len(entries))
+	for _, e := range entries {
+		h.Add(e.Name, e.Value)
+	}
+	return h
+}
+
+// ToHeaderEntries 将领域 Header 转换为 CDP Header 条目，每个字段对应一个条目
 func ToHeaderEntries(h domain.Header) []fetch.HeaderEntry {
 	entries := make([]fetch.HeaderEntry, 0, len(h))
-	for k, v := range h {
-		entries = append(entries, fetch.HeaderEntry{Name: k, Value: v})
+	for _, f := range h {
+		entries = append(entries, fetch.HeaderEntry{Name: f.Name, Value: f.Value})
 	}
 	return entries
 }
+
+// ToRequestPatterns 将领域拦截模式转换为 CDP RequestPattern，无法对应的资源类型不作限制
+func ToRequestPatterns(patterns []domain.InterceptPattern) []fetch.RequestPattern {
+	out := make([]fetch.RequestPattern, 0, len(patterns))
+	for _, p := range patterns {
+		urlPattern := p.URLPattern
+		rp := fetch.RequestPattern{URLPattern: &urlPattern, RequestStage: fetch.RequestStageRequest}
+		if p.Response {
+			rp.RequestStage = fetch.RequestStageResponse
+		}
+		if rt, ok := cdpResourceTypes[p.ResourceType]; ok {
+			rp.ResourceType = &rt
+		}
+		out = append(out, rp)
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package cdp_test
+
+import (
+	"reflect"
+	"testing"
+
+	"cdpnetool/internal/adapter/cdp"
+	"cdpnetool/pkg/domain"
+
+	"github.com/mafredri/cdp/protocol/fetch"
+	"github.com/mafredri/cdp/protocol/network"
+)
+
+func TestToNeutralRequest_Headers(t *testing.T) {
+	ev := &fetch.RequestPausedReply{
+		RequestID: "req1",
+		Request: network.Request{
+			URL:     "https://example.com/api?a=1",
+			Method:  "GET",
+			Headers: network.Headers(`{"Accept":"*/*","Cookie":"sid=1","X-Multi":"a\nb"}`),
+		},
+	}
+
+	req := cdp.ToNeutralRequest(ev)
+	if req.Headers.Get("accept") != "*/*" {
+		t.Errorf("got Accept %q", req.Headers.Get("accept"))
+	}
+	if got := req.Headers.Values("x-multi"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got X-Multi values %v", got)
+	}
+	if req.Cookies["sid"] != "1" {
+		t.Errorf("got cookies %v", req.Cookies)
+	}
+	if names := []string{req.Headers[0].Name, req.Headers[1].Name}; !reflect.DeepEqual(names, []string{"Accept", "Cookie"}) {
+		t.Errorf("header order not preserved: %v", req.Headers)
+	}
+}
+
+func TestResponseHeaders_RoundTrip(t *testing.T) {
+	status := 200
+	entries := []fetch.HeaderEntry{
+		{Name: "Content-Type", Value: "text/html"},
+		{Name: "Set-Cookie", Value: "a=1; Path=/"},
+		{Name: "Set-Cookie", Value: "b=2; HttpOnly"},
+		{Name: "x-lower", Value: "v"},
+	}
+	ev := &fetch.RequestPausedReply{ResponseStatusCode: &status, ResponseHeaders: entries}
+
+	res := cdp.ToNeutralResponse(ev, nil)
+	if got := res.Headers.Values("set-cookie"); len(got) != 2 {
+		t.Fatalf("got Set-Cookie values %v, want 2", got)
+	}
+
+	// 响应阶段规则修改其他头部后，重复的 Set-Cookie 仍应原样回写
+	res.Headers.Set("X-Lower", "changed")
+	want := []fetch.HeaderEntry{
+		{Name: "Content-Type", Value: "text/html"},
+		{Name: "Set-Cookie", Value: "a=1; Path=/"},
+		{Name: "Set-Cookie", Value: "b=2; HttpOnly"},
+		{Name: "x-lower", Value: "changed"},
+	}
+	if got := cdp.ToHeaderEntries(res.Headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %v, want %v", got, want)
+	}
+}
+
+func TestToRequestPatterns(t *testing.T) {
+	got := cdp.ToRequestPatterns([]domain.InterceptPattern{
+		{URLPattern: "https://a.com/*", ResourceType: domain.ResourceTypeXHR},
+		{URLPattern: "*", ResourceType: domain.ResourceTypeOther, Response: true},
+	})
+	if len(got) != 2 {
+		t.Fatalf("got %d patterns, want 2", len(got))
+	}
+	if *got[0].URLPattern != "https://a.com/*" || got[0].RequestStage != fetch.RequestStageRequest ||
+		got[0].ResourceType == nil || *got[0].ResourceType != network.ResourceTypeXHR {
+		t.Errorf("unexpected request pattern %+v", got[0])
+	}
+	// other 涵盖多种 CDP 类型，不限制资源类型
+	if *got[1].URLPattern != "*" || got[1].RequestStage != fetch.RequestStageResponse || got[1].ResourceType != nil {
+		t.Errorf("unexpected response pattern %+v", got[1])
+	}
+}
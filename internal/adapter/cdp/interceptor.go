@@ -25,12 +25,15 @@ func NewInterceptor(l logger.Logger, p *pool.Pool) *Interceptor {
 	return &Interceptor{log: l, pool: p}
 }
 
-// Enable 开启指定 Client 的拦截
-func (i *Interceptor) Enable(ctx context.Context, client *cdp.Client) error {
-	p := "*"
-	patterns := []fetch.RequestPattern{
-		{URLPattern: &p, RequestStage: fetch.RequestStageRequest},
-		{URLPattern: &p, RequestStage: fetch.RequestStageResponse},
+// Enable 按拦截模式开启指定 Client 的拦截，已开启时替换为新的模式
+// patterns 为空时拦截全部请求的请求与响应阶段
+func (i *Interceptor) Enable(ctx context.Context, client *cdp.Client, patterns []fetch.RequestPattern) error {
+	if len(patterns) == 0 {
+		p := "*"
+		patterns = []fetch.RequestPattern{
+			{URLPattern: &p, RequestStage: fetch.RequestStageRequest},
+			{URLPattern: &p, RequestStage: fetch.RequestStageResponse},
+		}
 	}
 	return client.Fetch.Enable(ctx, &fetch.EnableArgs{Patterns: patterns})
 }
@@ -62,13 +65,20 @@ func (i *Interceptor) ContinueResponse(ctx context.Context, client *cdp.Client,
 	return err
 }
 
-// Consume 开启事件消费循环
-func (i *Interceptor) Consume(ctx context.Context, client *cdp.Client, handler func(ev *fetch.RequestPausedReply)) {
+// Consume 订阅拦截事件并在后台开启事件消费循环
+// 订阅完成后才返回，保证随后开启拦截的目标（如等待附着后才恢复运行的新页面）不会丢失事件
+func (i *Interceptor) Consume(ctx context.Context, client *cdp.Client, handler func(ev *fetch.RequestPausedReply)) error {
 	rp, err := client.Fetch.RequestPaused(ctx)
 	if err != nil {
 		i.log.Err(err, "订阅拦截事件流失败")
-		return
+		return err
 	}
+	go i.consume(ctx, client, rp, handler)
+	return nil
+}
+
+// consume 消费拦截事件直到 ctx 取消或连接断开
+func (i *Interceptor) consume(ctx context.Context, client *cdp.Client, rp fetch.RequestPausedClient, handler func(ev *fetch.RequestPausedReply)) {
 	defer rp.Close()
 
 	for {
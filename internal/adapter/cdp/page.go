@@ -0,0 +1,167 @@
+package cdp
+
+import (
+	"context"
+	"sync"
+
+	"cdpnetool/internal/logger"
+	"cdpnetool/pkg/domain"
+
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/mafredri/cdp/protocol/target"
+)
+
+// PageTracker 跟踪目标的顶层页面 URL、标题以及各 frame 的文档 URL
+type PageTracker struct {
+	targetID  domain.TargetID
+	log       logger.Logger
+	once      sync.Once
+	mu        sync.RWMutex
+	url       string                  // 顶层页面 URL
+	title     string                  // 顶层页面标题
+	mainFrame page.FrameID            // 顶层 frame ID
+	frames    map[page.FrameID]string // frame ID -> 文档 URL
+}
+
+// NewPageTracker 创建页面上下文跟踪器
+func NewPageTracker(id domain.TargetID, l logger.Logger) *PageTracker {
+	if l == nil {
+		l = logger.NewNop()
+	}
+	return &PageTracker{
+		targetID: id,
+		log:      l,
+		frames:   make(map[page.FrameID]string),
+	}
+}
+
+// Watch 同步当前页面信息并持续监听导航与标题变化，直到 ctx 取消或连接断开
+// 同一跟踪器重复调用时仅首次生效
+func (t *PageTracker) Watch(ctx context.Context, client *cdp.Client) {
+	t.once.Do(func() {
+		t.watch(ctx, client)
+	})
+}
+
+// watch 初始化页面信息并启动事件监听
+func (t *PageTracker) watch(ctx context.Context, client *cdp.Client) {
+	if err := client.Page.Enable(ctx); err != nil {
+		t.log.Err(err, "启用 Page 域失败", "targetID", string(t.targetID))
+		return
+	}
+	if tree, err := client.Page.GetFrameTree(ctx); err == nil {
+		t.mu.Lock()
+		t.mainFrame = tree.FrameTree.Frame.ID
+		t.url = tree.FrameTree.Frame.URL
+		t.addFrameTree(tree.FrameTree)
+		t.mu.Unlock()
+	} else {
+		t.log.Warn("获取 Frame 树失败", "targetID", string(t.targetID), "error", err.Error())
+	}
+	if info, err := client.Target.GetTargetInfo(ctx, target.NewGetTargetInfoArgs()); err == nil {
+		t.mu.Lock()
+		t.title = info.TargetInfo.Title
+		t.mu.Unlock()
+	}
+
+	navigated, err := client.Page.FrameNavigated(ctx)
+	if err != nil {
+		t.log.Err(err, "订阅 Frame 导航事件失败", "targetID", string(t.targetID))
+		return
+	}
+	go t.consumeNavigated(navigated)
+
+	if detached, err := client.Page.FrameDetached(ctx); err == nil {
+		go t.consumeDetached(detached)
+	}
+
+	// 标题变化只能通过 Target 域事件获取，失败时仅保留导航信息
+	infoChanged, err := client.Target.TargetInfoChanged(ctx)
+	if err != nil {
+		return
+	}
+	if err := client.Target.SetDiscoverTargets(ctx, target.NewSetDiscoverTargetsArgs(true)); err != nil {
+		t.log.Warn("订阅 Target 信息变化失败，页面标题将不再更新", "targetID", string(t.targetID), "error", err.Error())
+		infoChanged.Close()
+		return
+	}
+	go t.consumeInfoChanged(infoChanged)
+}
+
+// addFrameTree 递归记录 frame 的文档 URL，调用方需持有写锁
+func (t *PageTracker) addFrameTree(tree page.FrameTree) {
+	t.frames[tree.Frame.ID] = tree.Frame.URL
+	for _, child := range tree.ChildFrames {
+		t.addFrameTree(child)
+	}
+}
+
+// consumeNavigated 处理 frame 导航事件
+func (t *PageTracker) consumeNavigated(stream page.FrameNavigatedClient) {
+	defer stream.Close()
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		t.frames[ev.Frame.ID] = ev.Frame.URL
+		if ev.Frame.ParentID == nil {
+			t.mainFrame = ev.Frame.ID
+			t.url = ev.Frame.URL
+		}
+		t.mu.Unlock()
+	}
+}
+
+// consumeDetached 处理 frame 移除事件
+func (t *PageTracker) consumeDetached(stream page.FrameDetachedClient) {
+	defer stream.Close()
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		if ev.FrameID != t.mainFrame {
+			delete(t.frames, ev.FrameID)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// consumeInfoChanged 处理目标信息变化事件，仅关注当前目标
+func (t *PageTracker) consumeInfoChanged(stream target.InfoChangedClient) {
+	defer stream.Close()
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			return
+		}
+		if string(ev.TargetInfo.TargetID) != string(t.targetID) {
+			continue
+		}
+		t.mu.Lock()
+		t.title = ev.TargetInfo.Title
+		if ev.TargetInfo.URL != "" {
+			t.url = ev.TargetInfo.URL
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Context 返回由指定 frame 发起的请求所处的页面上下文
+func (t *PageTracker) Context(frameID string) *domain.PageContext {
+	pc := &domain.PageContext{TargetID: t.targetID}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	pc.PageURL = t.url
+	pc.PageTitle = t.title
+	if u, ok := t.frames[page.FrameID(frameID)]; ok {
+		pc.FrameURL = u
+	} else if frameID == "" || page.FrameID(frameID) == t.mainFrame {
+		pc.FrameURL = t.url
+	}
+	return pc
+}
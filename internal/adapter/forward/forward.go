@@ -0,0 +1,110 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"cdpnetool/internal/logger"
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+)
+
+// hopHeaders 逐跳头部，不转发给目标服务器
+var hopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade", "TE", "Trailer"}
+
+// Client 本地 HTTP 代发客户端，用于完成 CDP ContinueRequest 无法实现的改写（切换协议、修改 Host）
+type Client struct {
+	http *http.Client
+	log  logger.Logger
+}
+
+// New 创建代发客户端，重定向不自动跟随，由浏览器根据返回的 3xx 响应处理
+func New(timeout time.Duration, l logger.Logger) *Client {
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	if l == nil {
+		l = logger.NewNop()
+	}
+	return &Client{
+		http: &http.Client{
+			Transport: newTransport(),
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		log: l,
+	}
+}
+
+// Do 代发请求并读取完整响应
+// 请求中的 Host 头作为目标主机名发送；移除 Accept-Encoding，由客户端透明解压，返回的响应体与 GetResponseBody 一致为解码后的内容
+func (c *Client) Do(ctx context.Context, req *domain.Request) (*domain.Response, error) {
+	rec := &headerRecorder{}
+	ctx = context.WithValue(ctx, recorderKey{}, rec)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range req.Headers {
+		httpReq.Header.Add(f.Name, f.Value)
+	}
+	if host := httpReq.Header.Get("Host"); host != "" {
+		httpReq.Host = host
+	}
+	httpReq.Header.Del("Host")
+	httpReq.Header.Del("Accept-Encoding")
+	httpReq.Header.Del("Content-Length")
+	for _, h := range hopHeaders {
+		httpReq.Header.Del(h)
+	}
+
+	c.log.Debug("[Forward] 代发请求", "requestID", req.ID, "method", req.Method, "url", req.URL, "host", httpReq.Host)
+	httpRes, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpRes.Body.Close()
+
+	body, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 响应头保持服务器返回的顺序与大小写
+	wire, _ := rec.fields()
+	res := domain.NewResponse()
+	res.StatusCode = httpRes.StatusCode
+	res.Headers = orderedHeader(httpRes.Header, wire)
+	for _, h := range hopHeaders {
+		res.Headers.Del(h)
+	}
+	res.Body = body
+	c.log.Debug("[Forward] 代发完成", "requestID", req.ID, "statusCode", res.StatusCode, "bodyLen", len(body))
+	return res, nil
+}
+
+// ErrorReason 将代发错误映射为浏览器可识别的网络错误原因
+func ErrorReason(err error) rulespec.ErrorReason {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return rulespec.ErrorReasonNameNotResolved
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return rulespec.ErrorReasonConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return rulespec.ErrorReasonConnectionReset
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return rulespec.ErrorReasonTimedOut
+	default:
+		return rulespec.ErrorReasonFailed
+	}
+}
@@ -0,0 +1,152 @@
+package forward_test
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"cdpnetool/internal/adapter/forward"
+	"cdpnetool/internal/logger"
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+)
+
+func TestClient_Do(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Host", r.Host)
+		w.Header().Set("X-Body", string(body))
+		w.Header().Set("X-Cookie", r.Header.Get("Cookie"))
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		// 客户端请求 gzip 时压缩返回，验证透明解压
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			_, _ = gz.Write([]byte("hello"))
+			_ = gz.Close()
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	c := forward.New(5*time.Second, logger.NewNop())
+
+	req := domain.NewRequest()
+	req.ID = "r1"
+	req.Method = "POST"
+	req.URL = srv.URL + "/api"
+	req.Body = []byte("payload")
+	req.Headers.Set("Host", "staging.example.com")
+	req.Headers.Set("Accept-Encoding", "gzip, deflate, br")
+	req.Headers.Set("Cookie", "sid=1")
+
+	res, err := c.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if res.StatusCode != 200 || string(res.Body) != "hello" || res.Headers.Has("Content-Encoding") {
+		t.Errorf("got %d %q %v, want decoded body", res.StatusCode, res.Body, res.Headers)
+	}
+	if res.Headers.Get("X-Host") != "staging.example.com" || res.Headers.Get("X-Body") != "payload" || res.Headers.Get("X-Cookie") != "sid=1" {
+		t.Errorf("request not forwarded as is: %v", res.Headers)
+	}
+	if got := res.Headers.Values("Set-Cookie"); len(got) != 2 {
+		t.Errorf("got Set-Cookie %v, want both values", got)
+	}
+
+	req.URL = srv.URL + "/redirect"
+	if res, err = c.Do(context.Background(), req); err != nil || res.StatusCode != http.StatusFound || res.Headers.Get("Location") != "/target" {
+		t.Errorf("redirect should be returned to the browser, got %v, %v", res, err)
+	}
+}
+
+func TestClient_Do_HeaderOrder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 4096)
+			_, _ = conn.Read(buf)
+			_, _ = io.WriteString(conn, "HTTP/1.1 100 Continue\r\nx-early: 1\r\n\r\n"+
+				"HTTP/1.1 200 OK\r\nx-zeta: 1\r\nContent-Type: text/plain\r\nX-Alpha: a\r\nx-zeta: 2\r\nContent-Length: 2\r\n\r\nok")
+			conn.Close()
+		}
+	}()
+
+	c := forward.New(5*time.Second, logger.NewNop())
+	req := domain.NewRequest()
+	req.Method = "GET"
+	req.URL = "http://" + ln.Addr().String() + "/"
+	for i := 0; i < 3; i++ {
+		res, err := c.Do(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		want := domain.Header{
+			{Name: "x-zeta", Value: "1"},
+			{Name: "Content-Type", Value: "text/plain"},
+			{Name: "X-Alpha", Value: "a"},
+			{Name: "x-zeta", Value: "2"},
+			{Name: "Content-Length", Value: "2"},
+		}
+		if !reflect.DeepEqual(res.Headers, want) {
+			t.Fatalf("got headers %v, want wire order %v", res.Headers, want)
+		}
+	}
+}
+
+func TestErrorReason(t *testing.T) {
+	// 监听后立即关闭，得到一个拒绝连接的地址
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := forward.New(5*time.Second, logger.NewNop())
+	req := domain.NewRequest()
+	req.Method = "GET"
+	req.URL = "http://" + addr + "/"
+	_, err = c.Do(context.Background(), req)
+	if got := forward.ErrorReason(err); got != rulespec.ErrorReasonConnectionRefused {
+		t.Errorf("refused connection: got %q (%v)", got, err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want rulespec.ErrorReason
+	}{
+		{"DNS 解析失败", &net.DNSError{Err: "no such host", Name: "x.invalid"}, rulespec.ErrorReasonNameNotResolved},
+		{"超时", context.DeadlineExceeded, rulespec.ErrorReasonTimedOut},
+		{"其他错误", errors.New("boom"), rulespec.ErrorReasonFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forward.ErrorReason(tt.err); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cdpnetool/pkg/domain"
+)
+
+// maxRecordedHeader 每个连接最多记录的字节数，超出时响应头按名称排序
+const maxRecordedHeader = 64 << 10
+
+// recorderKey 请求上下文中 headerRecorder 的键
+type recorderKey struct{}
+
+// headerRecorder 记录连接上读取的原始字节，用于还原响应头的原始顺序与大小写
+type headerRecorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// write 追加读取的数据，达到上限后不再记录
+func (r *headerRecorder) write(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if room := maxRecordedHeader - r.buf.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		r.buf.Write(p)
+	}
+}
+
+// fields 解析最终响应（跳过 1xx）的头部字段，按原始顺序与大小写返回；记录不完整或无法解析时返回 false
+func (r *headerRecorder) fields() ([]domain.HeaderField, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(r.buf.Bytes())))
+	for {
+		status, err := tp.ReadLine()
+		if err != nil || !strings.HasPrefix(status, "HTTP/") {
+			return nil, false
+		}
+		var fields []domain.HeaderField
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return nil, false
+			}
+			if line == "" {
+				break
+			}
+			if name, value, ok := strings.Cut(line, ":"); ok && name != "" && name[0] != ' ' && name[0] != '\t' {
+				fields = append(fields, domain.HeaderField{Name: name, Value: strings.TrimSpace(value)})
+			}
+		}
+		// 100 Continue 等中间响应之后才是最终响应
+		if code := strings.Fields(status); len(code) < 2 || len(code[1]) != 3 || code[1][0] != '1' || code[1] == "101" {
+			return fields, true
+		}
+	}
+}
+
+// recordConn 将读取的数据写入 headerRecorder 的连接
+type recordConn struct {
+	net.Conn
+	rec *headerRecorder
+}
+
+// Read 读取数据并记录
+func (c *recordConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.rec.write(p[:n])
+	}
+	return n, err
+}
+
+// newTransport 创建记录响应头原始顺序的 Transport
+// 每个请求独占一个连接，从请求上下文取得 headerRecorder 包装连接；HTTPS 在记录层之下完成握手，记录的是明文
+func newTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	wrap := func(ctx context.Context, conn net.Conn) net.Conn {
+		if rec, ok := ctx.Value(recorderKey{}).(*headerRecorder); ok {
+			return &recordConn{Conn: conn, rec: rec}
+		}
+		return conn
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return wrap(ctx, conn), nil
+		},
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			host, _, _ := net.SplitHostPort(addr)
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return wrap(ctx, tlsConn), nil
+		},
+		DisableKeepAlives:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// orderedHeader 按记录的原始顺序与大小写输出响应头，值以 Go 解析后的 http.Header 为准（透明解压时移除的字段不再输出）
+// 未能记录原始顺序的字段按名称排序追加在末尾
+func orderedHeader(h http.Header, wire []domain.HeaderField) domain.Header {
+	out := make(domain.Header, 0, len(h))
+	used := make(map[string]int, len(h))
+	for _, f := range wire {
+		key := http.CanonicalHeaderKey(f.Name)
+		values := h[key]
+		if i := used[key]; i < len(values) {
+			out.Add(f.Name, values[i])
+			used[key] = i + 1
+		}
+	}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name][used[name]:] {
+			out.Add(name, v)
+		}
+	}
+	return out
+}
@@ -54,13 +54,13 @@ func TestRecord_Basic(t *testing.T) {
 		ID:           "req1",
 		URL:          "https://example.com",
 		Method:       "GET",
-		Headers:      make(domain.Header),
+		Headers:      domain.Header{},
 		ResourceType: "xhr",
 	}
 
 	res := &domain.Response{
 		StatusCode: 200,
-		Headers:    make(domain.Header),
+		Headers:    domain.Header{},
 		Body:       []byte("response body"),
 	}
 
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"regexp"
+
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+
+	"github.com/tidwall/gjson"
+)
+
+// Captures 返回规则中第一个匹配成功的正则条件的捕获组及命名捕获组
+// 按 allOf、anyOf 顺序深度优先查找，忽略取反条件与 noneOf；没有可用的正则条件时返回 nil
+func (m *MatchedRule) Captures(req *domain.Request, res *domain.Response) ([]string, map[string]string) {
+	if m.match == nil {
+		return nil, nil
+	}
+	return m.match.captures(&evalInput{req: req, res: res})
+}
+
+// RenderTemplate 渲染规则行为中的模板文本，已解析的模板缓存在已编译的规则上，随规则配置更新一并丢弃
+func (m *MatchedRule) RenderTemplate(text string, ctx *transformer.TemplateContext) (string, error) {
+	return m.templates.Render(text, ctx)
+}
+
+// captures 在条件组中查找第一个匹配成功的正则条件
+func (g *compiledGroup) captures(in *evalInput) ([]string, map[string]string) {
+	for _, nodes := range [][]*compiledNode{g.allOf, g.anyOf} {
+		for _, n := range nodes {
+			if n.cond.Negate {
+				continue
+			}
+			if n.group != nil {
+				if groups, named := n.group.captures(in); groups != nil {
+					return groups, named
+				}
+				continue
+			}
+			if n.re == nil {
+				continue
+			}
+			for _, s := range n.regexSubjects(in) {
+				if groups := n.re.FindStringSubmatch(s); groups != nil {
+					return groups, namedCaptures(n.re, groups)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// regexSubjects 返回正则条件实际匹配的文本（多值字段返回全部值）
+func (n *compiledNode) regexSubjects(in *evalInput) []string {
+	req, res, c := in.req, in.res, n.cond
+	switch c.Type {
+	case rulespec.ConditionURLRegex:
+		return []string{req.URL}
+	case rulespec.ConditionURLPath:
+		if u := in.url(); u != nil {
+			return []string{u.path}
+		}
+	case rulespec.ConditionPageURLRegex, rulespec.ConditionPageTitleRegex, rulespec.ConditionFrameURLRegex:
+		if req.Page == nil {
+			return nil
+		}
+		switch c.Type {
+		case rulespec.ConditionPageURLRegex:
+			return []string{req.Page.PageURL}
+		case rulespec.ConditionPageTitleRegex:
+			return []string{req.Page.PageTitle}
+		default:
+			return []string{req.Page.FrameURL}
+		}
+	case rulespec.ConditionHeaderRegex:
+		return req.Headers.Values(c.Name)
+	case rulespec.ConditionQueryRegex:
+		return req.Query.Values(c.Name)
+	case rulespec.ConditionCookieRegex:
+		if v, ok := req.Cookies[c.Name]; ok {
+			return []string{v}
+		}
+	case rulespec.ConditionBodyRegex:
+		return []string{string(req.Body)}
+	case rulespec.ConditionFormFieldRegex:
+		if f := in.form(); f != nil {
+			return f.Values(c.Name)
+		}
+	case rulespec.ConditionBodyJsonPath:
+		return jsonPathSubjects(req.Body, n)
+	case rulespec.ConditionGraphQLVariable:
+		var subjects []string
+		if gql := in.graphql(); gql != nil {
+			for _, op := range gql.Operations {
+				subjects = append(subjects, jsonPathSubjects(op.Variables, n)...)
+			}
+		}
+		return subjects
+	case rulespec.ConditionResponseHeaderRegex:
+		if res != nil {
+			return res.Headers.Values(c.Name)
+		}
+	case rulespec.ConditionResponseBodyRegex:
+		if res != nil {
+			return []string{string(res.Body)}
+		}
+	case rulespec.ConditionResponseBodyJsonPath:
+		if res != nil {
+			return jsonPathSubjects(res.Body, n)
+		}
+	}
+	return nil
+}
+
+// jsonPathSubjects 返回 regex 运算符的 JSON Path 条件取到的值
+func jsonPathSubjects(body []byte, n *compiledNode) []string {
+	if n.cond.Operator != rulespec.OperatorRegex || len(body) == 0 || n.path == "" {
+		return nil
+	}
+	if r := gjson.GetBytes(body, n.path); r.Exists() {
+		return []string{r.String()}
+	}
+	return nil
+}
+
+// namedCaptures 提取命名捕获组
+func namedCaptures(re *regexp.Regexp, groups []string) map[string]string {
+	var named map[string]string
+	for i, name := range re.SubexpNames() {
+		if name == "" || i >= len(groups) {
+			continue
+		}
+		if named == nil {
+			named = make(map[string]string)
+		}
+		named[name] = groups[i]
+	}
+	return named
+}
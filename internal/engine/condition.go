@@ -0,0 +1,394 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+
+	"github.com/tidwall/gjson"
+)
+
+// evalRequest 评估请求条件
+func (n *compiledNode) evalRequest(in *evalInput) bool {
+	req, c := in.req, n.cond
+	switch c.Type {
+	case rulespec.ConditionURLEquals:
+		return req.URL == c.Value
+	case rulespec.ConditionURLPrefix:
+		return strings.HasPrefix(req.URL, c.Value)
+	case rulespec.ConditionURLSuffix:
+		return strings.HasSuffix(req.URL, c.Value)
+	case rulespec.ConditionURLContains:
+		return strings.Contains(req.URL, c.Value)
+	case rulespec.ConditionURLRegex:
+		return n.matchRegex(req.URL)
+
+	case rulespec.ConditionURLHost:
+		u := in.url()
+		if u == nil {
+			return false
+		}
+		for _, v := range c.Values {
+			if matchHostPattern(u.host, v) {
+				return true
+			}
+		}
+		return false
+	case rulespec.ConditionURLPath:
+		u := in.url()
+		return u != nil && n.matchRegex(u.path)
+	case rulespec.ConditionURLScheme:
+		u := in.url()
+		return u != nil && containsFold(c.Values, u.scheme)
+	case rulespec.ConditionURLPort:
+		u := in.url()
+		return u != nil && containsFold(c.Values, u.port)
+	case rulespec.ConditionURLNoFragment:
+		return stripFragment(req.URL) == stripFragment(c.Value)
+	case rulespec.ConditionURLPattern:
+		u := in.url()
+		return u != nil && n.urlPat != nil && n.urlPat.match(u)
+
+	case rulespec.ConditionTargetID:
+		return req.Page != nil && containsTrimmed(c.Values, string(req.Page.TargetID))
+	case rulespec.ConditionPageURLContains:
+		return req.Page != nil && strings.Contains(req.Page.PageURL, c.Value)
+	case rulespec.ConditionPageURLPattern:
+		if req.Page == nil || n.urlPat == nil {
+			return false
+		}
+		u := parseURL(req.Page.PageURL)
+		return u != nil && n.urlPat.match(u)
+	case rulespec.ConditionPageURLRegex:
+		return req.Page != nil && n.matchRegex(req.Page.PageURL)
+	case rulespec.ConditionPageTitleContains:
+		return req.Page != nil && strings.Contains(req.Page.PageTitle, c.Value)
+	case rulespec.ConditionPageTitleRegex:
+		return req.Page != nil && n.matchRegex(req.Page.PageTitle)
+	case rulespec.ConditionFrameURLContains:
+		return req.Page != nil && strings.Contains(req.Page.FrameURL, c.Value)
+	case rulespec.ConditionFrameURLRegex:
+		return req.Page != nil && n.matchRegex(req.Page.FrameURL)
+
+	case rulespec.ConditionMethod:
+		return containsFold(c.Values, req.Method)
+
+	case rulespec.ConditionResourceType:
+		// ResourceType 已规范化为小写，配置值忽略大小写比较
+		for _, v := range c.Values {
+			if strings.EqualFold(string(req.ResourceType), v) {
+				return true
+			}
+		}
+		return false
+
+	case rulespec.ConditionHeaderExists:
+		return req.Headers.Has(c.Name)
+	case rulespec.ConditionHeaderNotExists:
+		return !req.Headers.Has(c.Name)
+	case rulespec.ConditionHeaderEquals:
+		return anyValue(req.Headers.Values(c.Name), func(s string) bool { return s == c.Value })
+	case rulespec.ConditionHeaderContains:
+		return anyValue(req.Headers.Values(c.Name), func(s string) bool { return strings.Contains(s, c.Value) })
+	case rulespec.ConditionHeaderRegex:
+		return anyValue(req.Headers.Values(c.Name), n.matchRegex)
+
+	case rulespec.ConditionQueryExists:
+		return req.Query.Has(c.Name)
+	case rulespec.ConditionQueryNotExists:
+		return !req.Query.Has(c.Name)
+	case rulespec.ConditionQueryEquals:
+		return anyValue(req.Query.Values(c.Name), func(s string) bool { return s == c.Value })
+	case rulespec.ConditionQueryContains:
+		return anyValue(req.Query.Values(c.Name), func(s string) bool { return strings.Contains(s, c.Value) })
+	case rulespec.ConditionQueryRegex:
+		return anyValue(req.Query.Values(c.Name), n.matchRegex)
+
+	case rulespec.ConditionCookieExists:
+		_, ok := req.Cookies[c.Name]
+		return ok
+	case rulespec.ConditionCookieNotExists:
+		_, ok := req.Cookies[c.Name]
+		return !ok
+	case rulespec.ConditionCookieEquals:
+		v, ok := req.Cookies[c.Name]
+		return ok && v == c.Value
+	case rulespec.ConditionCookieContains:
+		v, ok := req.Cookies[c.Name]
+		return ok && strings.Contains(v, c.Value)
+	case rulespec.ConditionCookieRegex:
+		v, ok := req.Cookies[c.Name]
+		return ok && n.matchRegex(v)
+
+	case rulespec.ConditionBodyContains:
+		return strings.Contains(string(req.Body), c.Value)
+	case rulespec.ConditionBodyRegex:
+		return n.matchRegex(string(req.Body))
+	case rulespec.ConditionBodyJsonPath:
+		return n.evalJsonPath(req.Body)
+
+	case rulespec.ConditionFormFieldExists:
+		f := in.form()
+		return f != nil && f.Has(c.Name)
+	case rulespec.ConditionFormFieldEquals:
+		f := in.form()
+		return f != nil && anyValue(f.Values(c.Name), func(s string) bool { return s == c.Value })
+	case rulespec.ConditionFormFieldRegex:
+		f := in.form()
+		return f != nil && anyValue(f.Values(c.Name), n.matchRegex)
+
+	case rulespec.ConditionGraphQLOperationName:
+		return in.anyOperation(func(op *transformer.GraphQLOperation) bool {
+			return op.Name != "" && containsTrimmed(c.Values, op.Name)
+		})
+	case rulespec.ConditionGraphQLOperationType:
+		return in.anyOperation(func(op *transformer.GraphQLOperation) bool {
+			return op.Type != "" && containsFold(c.Values, op.Type)
+		})
+	case rulespec.ConditionGraphQLVariable:
+		return in.anyOperation(func(op *transformer.GraphQLOperation) bool {
+			return n.evalJsonPath(op.Variables)
+		})
+
+	case rulespec.ConditionScenarioState:
+		return in.state != nil && containsTrimmed(c.Values, in.state.scenario(c.Name))
+	case rulespec.ConditionVariableExists:
+		if in.state == nil {
+			return false
+		}
+		_, ok := in.state.variable(c.Name)
+		return ok
+	case rulespec.ConditionVariableEquals:
+		if in.state == nil {
+			return false
+		}
+		v, ok := in.state.variable(c.Name)
+		return ok && v == c.Value
+
+	default:
+		return false
+	}
+}
+
+// evalResponse 评估响应条件
+func (n *compiledNode) evalResponse(res *domain.Response) bool {
+	c := n.cond
+	switch c.Type {
+	case rulespec.ConditionStatusCode:
+		code := strconv.Itoa(res.StatusCode)
+		for _, v := range c.Values {
+			if strings.TrimSpace(v) == code {
+				return true
+			}
+		}
+		return false
+	case rulespec.ConditionStatusCodeRange:
+		return n.rangeOK && res.StatusCode >= n.lo && res.StatusCode <= n.hi
+
+	case rulespec.ConditionResponseHeaderExists:
+		return res.Headers.Has(c.Name)
+	case rulespec.ConditionResponseHeaderNotExists:
+		return !res.Headers.Has(c.Name)
+	case rulespec.ConditionResponseHeaderEquals:
+		return anyValue(res.Headers.Values(c.Name), func(s string) bool { return s == c.Value })
+	case rulespec.ConditionResponseHeaderContains:
+		return anyValue(res.Headers.Values(c.Name), func(s string) bool { return strings.Contains(s, c.Value) })
+	case rulespec.ConditionResponseHeaderRegex:
+		return anyValue(res.Headers.Values(c.Name), n.matchRegex)
+
+	case rulespec.ConditionResponseBodyContains:
+		return strings.Contains(string(res.Body), c.Value)
+	case rulespec.ConditionResponseBodyRegex:
+		return n.matchRegex(string(res.Body))
+	case rulespec.ConditionResponseBodyJsonPath:
+		return n.evalJsonPath(res.Body)
+
+	case rulespec.ConditionMimeType:
+		mime := mimeTypeOf(res.Headers)
+		for _, v := range c.Values {
+			if matchMimeType(mime, v) {
+				return true
+			}
+		}
+		return false
+
+	default:
+		return false
+	}
+}
+
+// containsFold 判断列表中是否存在忽略大小写相等的值
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsTrimmed 判断列表中是否存在去除首尾空白后相等的值（区分大小写）
+func containsTrimmed(values []string, s string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRegex 使用预编译的正则匹配，正则非法时恒不匹配
+func (n *compiledNode) matchRegex(s string) bool {
+	return n.re != nil && n.re.MatchString(s)
+}
+
+// evalJsonPath 使用预解析的路径取值，并按运算符与期望值比较
+func (n *compiledNode) evalJsonPath(body []byte) bool {
+	var result gjson.Result
+	if len(body) > 0 && n.path != "" {
+		result = gjson.GetBytes(body, n.path)
+	}
+	c := n.cond
+	switch c.Operator {
+	case rulespec.OperatorNotExists:
+		return !result.Exists()
+	case rulespec.OperatorExists:
+		return result.Exists()
+	}
+	if !result.Exists() {
+		return false
+	}
+
+	switch c.Operator {
+	case "", rulespec.OperatorEq:
+		return jsonEquals(result, c.Value)
+	case rulespec.OperatorNe:
+		return !jsonEquals(result, c.Value)
+	case rulespec.OperatorGt, rulespec.OperatorGte, rulespec.OperatorLt, rulespec.OperatorLte:
+		v, ok := jsonNumber(result)
+		if !ok || !n.numOK {
+			return false
+		}
+		switch c.Operator {
+		case rulespec.OperatorGt:
+			return v > n.num
+		case rulespec.OperatorGte:
+			return v >= n.num
+		case rulespec.OperatorLt:
+			return v < n.num
+		default:
+			return v <= n.num
+		}
+	case rulespec.OperatorRegex:
+		return n.matchRegex(result.String())
+	case rulespec.OperatorIn:
+		for _, v := range c.Values {
+			if jsonEquals(result, v) {
+				return true
+			}
+		}
+		return false
+	case rulespec.OperatorContains:
+		if result.IsArray() {
+			found := false
+			result.ForEach(func(_, elem gjson.Result) bool {
+				found = jsonEquals(elem, c.Value)
+				return !found
+			})
+			return found
+		}
+		return result.Type == gjson.String && strings.Contains(result.Str, c.Value)
+	default:
+		return false
+	}
+}
+
+// jsonEquals 按 JSON 值的类型与字符串形式的期望值比较
+// 数字按数值比较（"1.0" 等于 1），布尔按布尔值比较，null 等于 "null" 或空串，其余比较字符串形式
+func jsonEquals(r gjson.Result, want string) bool {
+	switch r.Type {
+	case gjson.Number:
+		if f, ok := parseNumber(want); ok {
+			return r.Num == f
+		}
+		return r.Raw == want
+	case gjson.True, gjson.False:
+		b, err := strconv.ParseBool(strings.TrimSpace(want))
+		return err == nil && r.Bool() == b
+	case gjson.Null:
+		return want == "null" || want == ""
+	default:
+		return r.String() == want
+	}
+}
+
+// jsonNumber 获取 JSON 值的数值，字符串形式的数字同样视为数值
+func jsonNumber(r gjson.Result) (float64, bool) {
+	switch r.Type {
+	case gjson.Number:
+		return r.Num, true
+	case gjson.String:
+		return parseNumber(r.Str)
+	default:
+		return 0, false
+	}
+}
+
+// parseNumber 解析数值操作数
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
+
+// normalizeJsonPath 将 "$.a.b" 形式的 JSON Path 转换为 gjson 路径
+func normalizeJsonPath(path string) string {
+	return strings.TrimPrefix(path, "$.")
+}
+
+// parseStatusRange 解析 "500-599" 格式的状态码范围，单个数字视为精确范围
+func parseStatusRange(s string) (int, int, bool) {
+	loStr, hiStr, found := strings.Cut(strings.TrimSpace(s), "-")
+	lo, err := strconv.Atoi(strings.TrimSpace(loStr))
+	if err != nil {
+		return 0, 0, false
+	}
+	if !found {
+		return lo, lo, true
+	}
+	hi, err := strconv.Atoi(strings.TrimSpace(hiStr))
+	if err != nil || hi < lo {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+// mimeTypeOf 从响应头中提取不带参数的小写 MIME 类型
+func mimeTypeOf(h domain.Header) string {
+	mime, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	return strings.ToLower(strings.TrimSpace(mime))
+}
+
+// anyValue 判断多值字段（如重复的 Header 或查询参数）中是否有任意一个值满足条件
+func anyValue(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMimeType 匹配 MIME 类型，支持 "text/*" 形式的子类型通配
+func matchMimeType(mime, want string) bool {
+	want = strings.ToLower(strings.TrimSpace(want))
+	if mime == "" || want == "" {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(want, "/*"); ok {
+		return strings.HasPrefix(mime, prefix+"/")
+	}
+	return mime == want
+}
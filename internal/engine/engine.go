@@ -1,240 +1,331 @@
 package engine
 
 import (
-	"sort"
-	"strings"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"cdpnetool/internal/regexutil"
+	"cdpnetool/internal/transformer"
 	"cdpnetool/pkg/domain"
 	"cdpnetool/pkg/rulespec"
-
-	"github.com/tidwall/gjson"
 )
 
 // MatchedRule 匹配成功的规则及其详细信息
 type MatchedRule struct {
-	Rule *rulespec.Rule
+	Rule      *rulespec.Rule
+	GroupMode rulespec.GroupMode // 所属分组的执行模式，未分组时为空
+
+	match     *compiledGroup             // 规则的已编译条件，用于提取正则捕获组
+	templates *transformer.TemplateCache // 规则的模板解析缓存
 }
 
 // Engine 规则决策引擎
 type Engine struct {
-	config  *rulespec.Config
+	matcher atomic.Pointer[matcher] // 当前生效的已编译匹配器
 	mu      sync.RWMutex
 	total   int64
 	matched int64
 	byRule  map[string]int64
+	byStage map[rulespec.Stage]*stageCounter // 按阶段的评估统计
+	rules   map[string]*ruleCounter          // 按规则的运行统计
+	resetAt time.Time                        // 统计起始时间
+	limits  map[string]*limitCounter         // 规则命中限制计数，Update 时重置
 	cache   *regexutil.Cache
+	state   *stateStore // 会话变量与场景状态，Update 时保留
+}
+
+// stageCounter 单个阶段的评估统计
+type stageCounter struct {
+	total    int64
+	matched  int64
+	evalTime time.Duration
+}
+
+// ruleCounter 单条规则的运行统计
+type ruleCounter struct {
+	hits      int64
+	lastMatch time.Time
+	applyTime time.Duration
+	errors    int64
+	lastErr   string
+}
+
+// limitCounter 单条规则的命中限制计数
+type limitCounter struct {
+	hits  int64      // 条件匹配次数
+	fires int64      // 实际生效次数
+	rng   *rand.Rand // 指定种子时的独立随机源
 }
 
 // New 创建一个新的规则引擎实例
 func New(config *rulespec.Config) *Engine {
-	return &Engine{
-		config: config,
-		byRule: make(map[string]int64),
-		cache:  regexutil.New(),
+	e := &Engine{
+		byRule:  make(map[string]int64),
+		byStage: make(map[rulespec.Stage]*stageCounter),
+		rules:   make(map[string]*ruleCounter),
+		resetAt: time.Now(),
+		limits:  make(map[string]*limitCounter),
+		cache:   regexutil.New(),
+		state:   newStateStore(),
 	}
+	e.matcher.Store(compile(config, e.cache))
+	return e
 }
 
-// Update 更新规则配置
+// Update 更新规则配置，预编译为新的匹配器后原子替换，并重置命中限制计数（会话状态保留）
+// 匹配器与命中限制计数在同一把锁内一起替换，新规则不会沿用旧配置的计数
 func (e *Engine) Update(config *rulespec.Config) {
+	m := compile(config, e.cache)
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.config = config
+	e.limits = make(map[string]*limitCounter)
+	e.matcher.Store(m)
 }
 
-// Eval 评估请求并返回匹配的规则列表 (按优先级降序)
-func (e *Engine) Eval(req *domain.Request, stage rulespec.Stage) []*MatchedRule {
-	e.mu.RLock()
-	config := e.config
-	e.mu.RUnlock()
+// evalInput 单次评估的输入数据
+type evalInput struct {
+	state     *stateStore
+	req       *domain.Request
+	res       *domain.Response // 仅响应阶段非空
+	parsed    *parsedURL       // 延迟解析的请求 URL
+	urlParsed bool             // 是否已尝试解析 URL
 
-	if config == nil || len(config.Rules) == 0 {
-		return nil
+	parsedForm *transformer.Form // 延迟解析的表单请求体
+	formParsed bool              // 是否已尝试解析表单
+
+	parsedGraphQL *transformer.GraphQLRequest // 延迟解析的 GraphQL 请求体
+	graphqlParsed bool                        // 是否已尝试解析 GraphQL
+}
+
+// url 返回解析后的请求 URL，同一次评估内仅解析一次
+func (in *evalInput) url() *parsedURL {
+	if !in.urlParsed {
+		in.parsed = parseURL(in.req.URL)
+		in.urlParsed = true
 	}
+	return in.parsed
+}
 
-	var matched []*MatchedRule
-	for i := range config.Rules {
-		rule := &config.Rules[i]
-		if !rule.Enabled || rule.Stage != stage {
-			continue
+// form 返回解析后的表单请求体，同一次评估内仅解析一次
+// Content-Type 为非表单类型或解析失败时返回 nil；未声明 Content-Type 时按 urlencoded 解析
+func (in *evalInput) form() *transformer.Form {
+	if !in.formParsed {
+		in.formParsed = true
+		ct := in.req.Headers.Get("Content-Type")
+		if ct == "" || transformer.IsFormContentType(ct) {
+			in.parsedForm, _ = transformer.ParseForm(ct, in.req.Body)
 		}
+	}
+	return in.parsedForm
+}
 
-		if e.matchRule(req, &rule.Match) {
-			matched = append(matched, &MatchedRule{Rule: rule})
-		}
+// graphql 返回解析后的 GraphQL 请求体，同一次评估内仅解析一次；不是 GraphQL 请求时返回 nil
+func (in *evalInput) graphql() *transformer.GraphQLRequest {
+	if !in.graphqlParsed {
+		in.graphqlParsed = true
+		in.parsedGraphQL, _ = transformer.ParseGraphQL(in.req.Body)
 	}
+	return in.parsedGraphQL
+}
 
-	if len(matched) == 0 {
-		return nil
+// anyOperation 判断 GraphQL 请求中是否有任意一个操作满足条件
+func (in *evalInput) anyOperation(fn func(op *transformer.GraphQLOperation) bool) bool {
+	gql := in.graphql()
+	if gql == nil {
+		return false
+	}
+	for i := range gql.Operations {
+		if fn(&gql.Operations[i]) {
+			return true
+		}
 	}
+	return false
+}
 
-	// 按优先级从大到小排序
-	sort.Slice(matched, func(i, j int) bool {
-		return matched[i].Rule.Priority > matched[j].Rule.Priority
-	})
+// Eval 评估请求并返回匹配的规则列表 (按优先级降序)
+func (e *Engine) Eval(req *domain.Request, stage rulespec.Stage) []*MatchedRule {
+	return e.eval(&evalInput{state: e.state, req: req}, stage)
+}
 
-	return matched
+// EvalResponse 结合请求与响应评估响应阶段规则，返回匹配的规则列表 (按优先级降序)
+func (e *Engine) EvalResponse(req *domain.Request, res *domain.Response) []*MatchedRule {
+	return e.eval(&evalInput{state: e.state, req: req, res: res}, rulespec.StageResponse)
 }
 
-// RecordStats 记录匹配统计信息
-func (e *Engine) RecordStats(matched []*MatchedRule) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	e.total++
-	if len(matched) > 0 {
-		e.matched++
-		for _, m := range matched {
-			e.byRule[m.Rule.ID]++
+// ResponseBodyNeeded 判断响应阶段可能生效的规则是否需要读取响应体，res 为尚未读取响应体的响应
+// 含响应体条件的规则在其余条件可能满足时即需要；其余规则命中且有行为读取响应体时需要
+func (e *Engine) ResponseBodyNeeded(req *domain.Request, res *domain.Response) bool {
+	idx := e.matcher.Load().stage(rulespec.StageResponse)
+	if idx == nil {
+		return false
+	}
+	in := &evalInput{state: e.state, req: req, res: res}
+	for _, cr := range idx.candidates(req.URL) {
+		if cr.bodyCond && cr.match.mayMatch(in) {
+			return true
+		}
+		if !cr.bodyCond && cr.bodyUse && cr.match.match(in) {
+			return true
 		}
 	}
+	return false
 }
 
-// matchRule 评估单个规则的匹配条件
-func (e *Engine) matchRule(req *domain.Request, m *rulespec.Match) bool {
-	// allOf: 必须全部满足
-	if len(m.AllOf) > 0 {
-		for i := range m.AllOf {
-			if !e.evalCondition(req, &m.AllOf[i]) {
-				return false
-			}
-		}
+// eval 评估指定阶段的规则
+func (e *Engine) eval(in *evalInput, stage rulespec.Stage) []*MatchedRule {
+	idx := e.matcher.Load().stage(stage)
+	if idx == nil {
+		return nil
 	}
-	// anyOf: 满足任一即可
-	if len(m.AnyOf) > 0 {
-		anyMatch := false
-		for i := range m.AnyOf {
-			if e.evalCondition(req, &m.AnyOf[i]) {
-				anyMatch = true
-				break
-			}
-		}
-		if !anyMatch {
-			return false
+
+	// 候选规则已按优先级从大到小排序
+	var matched []*MatchedRule
+	for _, cr := range idx.candidates(in.req.URL) {
+		if cr.match.match(in) {
+			matched = append(matched, &MatchedRule{Rule: cr.rule, GroupMode: cr.groupMode, match: &cr.match, templates: cr.templates})
 		}
 	}
-	return true
+	return matched
 }
 
-// evalCondition 评估单个条件
-func (e *Engine) evalCondition(req *domain.Request, c *rulespec.Condition) bool {
-	switch c.Type {
-	case rulespec.ConditionURLEquals:
-		return req.URL == c.Value
-	case rulespec.ConditionURLPrefix:
-		return strings.HasPrefix(req.URL, c.Value)
-	case rulespec.ConditionURLSuffix:
-		return strings.HasSuffix(req.URL, c.Value)
-	case rulespec.ConditionURLContains:
-		return strings.Contains(req.URL, c.Value)
-	case rulespec.ConditionURLRegex:
-		return e.matchRegex(req.URL, c.Pattern)
-
-	case rulespec.ConditionMethod:
-		for _, v := range c.Values {
-			if strings.EqualFold(req.Method, v) {
-				return true
-			}
-		}
+// Allow 判断条件已匹配的规则本次是否生效，并累计命中限制计数
+// 依次检查过期时间、每第 k 次、最大生效次数与概率，未配置限制时总是生效
+func (e *Engine) Allow(rule *rulespec.Rule) bool {
+	limit := rule.Limit
+	if limit == nil {
+		return true
+	}
+	if limit.ExpiresAt > 0 && time.Now().UnixMilli() >= limit.ExpiresAt {
 		return false
+	}
 
-	case rulespec.ConditionResourceType:
-		// 直接比较 ResourceType（已经是规范化的小写字符串）
-		for _, v := range c.Values {
-			if string(req.ResourceType) == v {
-				return true
-			}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.limits[rule.ID]
+	if c == nil {
+		c = &limitCounter{}
+		if limit.Seed != nil {
+			c.rng = rand.New(rand.NewSource(*limit.Seed))
 		}
-		return false
+		e.limits[rule.ID] = c
+	}
 
-	case rulespec.ConditionHeaderExists:
-		return req.Headers.Get(c.Name) != ""
-	case rulespec.ConditionHeaderNotExists:
-		return req.Headers.Get(c.Name) == ""
-	case rulespec.ConditionHeaderEquals:
-		return req.Headers.Get(c.Name) == c.Value
-	case rulespec.ConditionHeaderContains:
-		return strings.Contains(req.Headers.Get(c.Name), c.Value)
-	case rulespec.ConditionHeaderRegex:
-		return e.matchRegex(req.Headers.Get(c.Name), c.Pattern)
-
-	case rulespec.ConditionQueryExists:
-		_, ok := req.Query[c.Name]
-		return ok
-	case rulespec.ConditionQueryNotExists:
-		_, ok := req.Query[c.Name]
-		return !ok
-	case rulespec.ConditionQueryEquals:
-		v, ok := req.Query[c.Name]
-		return ok && v == c.Value
-	case rulespec.ConditionQueryContains:
-		v, ok := req.Query[c.Name]
-		return ok && strings.Contains(v, c.Value)
-	case rulespec.ConditionQueryRegex:
-		v, ok := req.Query[c.Name]
-		return ok && e.matchRegex(v, c.Pattern)
-
-	case rulespec.ConditionCookieExists:
-		_, ok := req.Cookies[c.Name]
-		return ok
-	case rulespec.ConditionCookieNotExists:
-		_, ok := req.Cookies[c.Name]
-		return !ok
-	case rulespec.ConditionCookieEquals:
-		v, ok := req.Cookies[c.Name]
-		return ok && v == c.Value
-	case rulespec.ConditionCookieContains:
-		v, ok := req.Cookies[c.Name]
-		return ok && strings.Contains(v, c.Value)
-	case rulespec.ConditionCookieRegex:
-		v, ok := req.Cookies[c.Name]
-		return ok && e.matchRegex(v, c.Pattern)
-
-	case rulespec.ConditionBodyContains:
-		return strings.Contains(string(req.Body), c.Value)
-	case rulespec.ConditionBodyRegex:
-		return e.matchRegex(string(req.Body), c.Pattern)
-	case rulespec.ConditionBodyJsonPath:
-		val, ok := e.evalJsonPath(string(req.Body), c.Path)
-		return ok && val == c.Value
-
-	default:
+	c.hits++
+	if limit.EveryNth > 1 && c.hits%int64(limit.EveryNth) != 0 {
+		return false
+	}
+	if limit.MaxHits > 0 && c.fires >= int64(limit.MaxHits) {
 		return false
 	}
+	if limit.Probability > 0 && limit.Probability < 1 {
+		var r float64
+		if c.rng != nil {
+			r = c.rng.Float64()
+		} else {
+			r = rand.Float64()
+		}
+		if r >= limit.Probability {
+			return false
+		}
+	}
+	c.fires++
+	return true
 }
 
-// evalJsonPath 评估 JSON Path 表达式
-func (e *Engine) evalJsonPath(body, path string) (string, bool) {
-	if body == "" || path == "" {
-		return "", false
+// RecordStats 记录一次阶段评估的统计信息，matched 为实际执行的规则，evalTime 为评估耗时
+func (e *Engine) RecordStats(stage rulespec.Stage, matched []*MatchedRule, evalTime time.Duration) {
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.total++
+	sc := e.byStage[stage]
+	if sc == nil {
+		sc = &stageCounter{}
+		e.byStage[stage] = sc
 	}
-	searchPath := path
-	if strings.HasPrefix(path, "$.") {
-		searchPath = path[2:]
+	sc.total++
+	sc.evalTime += evalTime
+	if len(matched) > 0 {
+		e.matched++
+		sc.matched++
+		for _, m := range matched {
+			e.byRule[m.Rule.ID]++
+			rc := e.ruleCounter(m.Rule.ID)
+			rc.hits++
+			rc.lastMatch = now
+		}
 	}
-	result := gjson.Get(body, searchPath)
-	if !result.Exists() {
-		return "", false
+}
+
+// RecordApply 记录单条规则一次执行行为的耗时与失败情况
+func (e *Engine) RecordApply(ruleID string, applyTime time.Duration, errs []error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rc := e.ruleCounter(ruleID)
+	rc.applyTime += applyTime
+	if len(errs) > 0 {
+		rc.errors += int64(len(errs))
+		rc.lastErr = errs[len(errs)-1].Error()
 	}
-	return result.String(), true
 }
 
-// matchRegex 正则匹配，使用缓存提升性能
-func (e *Engine) matchRegex(s, pattern string) bool {
-	re, err := e.cache.Get(pattern)
-	if err != nil {
-		return false
+// ruleCounter 获取或创建规则的运行统计，调用方需持有写锁
+func (e *Engine) ruleCounter(ruleID string) *ruleCounter {
+	rc := e.rules[ruleID]
+	if rc == nil {
+		rc = &ruleCounter{}
+		e.rules[ruleID] = rc
 	}
-	return re.MatchString(s)
+	return rc
 }
 
-// GetStats 获取统计信息
-func (e *Engine) GetStats() (int64, int64, map[string]int64) {
+// GetStats 获取统计信息快照
+func (e *Engine) GetStats() domain.EngineStats {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	byRule := make(map[string]int64, len(e.byRule))
+	stats := domain.EngineStats{
+		Total:   e.total,
+		Matched: e.matched,
+		ByRule:  make(map[domain.RuleID]int64, len(e.byRule)),
+		ByStage: make(map[string]domain.StageStats, len(e.byStage)),
+		Rules:   make(map[domain.RuleID]domain.RuleStats, len(e.rules)),
+		ResetAt: e.resetAt.UnixMilli(),
+	}
 	for k, v := range e.byRule {
-		byRule[k] = v
+		stats.ByRule[domain.RuleID(k)] = v
+	}
+	for stage, sc := range e.byStage {
+		stats.ByStage[string(stage)] = domain.StageStats{
+			Total:      sc.total,
+			Matched:    sc.matched,
+			EvalTimeUS: sc.evalTime.Microseconds(),
+		}
+	}
+	for id, rc := range e.rules {
+		rs := domain.RuleStats{
+			Hits:         rc.hits,
+			ApplyTimeUS:  rc.applyTime.Microseconds(),
+			ActionErrors: rc.errors,
+			LastError:    rc.lastErr,
+		}
+		if !rc.lastMatch.IsZero() {
+			rs.LastMatchAt = rc.lastMatch.UnixMilli()
+		}
+		stats.Rules[domain.RuleID(id)] = rs
 	}
-	return e.total, e.matched, byRule
+	return stats
+}
+
+// ResetStats 清空统计信息（不影响命中限制计数）
+func (e *Engine) ResetStats() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.total = 0
+	e.matched = 0
+	e.byRule = make(map[string]int64)
+	e.byStage = make(map[rulespec.Stage]*stageCounter)
+	e.rules = make(map[string]*ruleCounter)
+	e.resetAt = time.Now()
 }
@@ -1,7 +1,13 @@
 package engine_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"cdpnetool/internal/engine"
 	"cdpnetool/pkg/domain"
@@ -297,7 +303,7 @@ func TestEval_HeaderExists(t *testing.T) {
 		ID:      "req1",
 		URL:     "https://example.com",
 		Method:  "GET",
-		Headers: make(domain.Header),
+		Headers: domain.Header{},
 	}
 	req.Headers.Set("Authorization", "Bearer token")
 
@@ -328,7 +334,7 @@ func TestEval_HeaderNotExists(t *testing.T) {
 		ID:      "req1",
 		URL:     "https://example.com",
 		Method:  "GET",
-		Headers: make(domain.Header),
+		Headers: domain.Header{},
 	}
 
 	matched := eng.Eval(req, rulespec.StageRequest)
@@ -358,7 +364,7 @@ func TestEval_QueryExists(t *testing.T) {
 		ID:     "req1",
 		URL:    "https://example.com?id=123",
 		Method: "GET",
-		Query:  map[string]string{"id": "123"},
+		Query:  domain.Query{{Key: "id", Value: "123"}},
 	}
 
 	matched := eng.Eval(req, rulespec.StageRequest)
@@ -367,6 +373,145 @@ func TestEval_QueryExists(t *testing.T) {
 	}
 }
 
+func TestEval_QueryMultiValue(t *testing.T) {
+	req := &domain.Request{
+		URL:    "https://example.com/search?tag=a&tag=b%20c&q=%E4%B8%AD",
+		Method: "GET",
+		Query: domain.Query{
+			{Key: "tag", Value: "a"},
+			{Key: "tag", Value: "b c"},
+			{Key: "q", Value: "中"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		cond rulespec.Condition
+		want bool
+	}{
+		{"重复键任一值相等", rulespec.Condition{Type: rulespec.ConditionQueryEquals, Name: "tag", Value: "b c"}, true},
+		{"解码后比较", rulespec.Condition{Type: rulespec.ConditionQueryEquals, Name: "q", Value: "中"}, true},
+		{"原始编码不再匹配", rulespec.Condition{Type: rulespec.ConditionQueryEquals, Name: "q", Value: "%E4%B8%AD"}, false},
+		{"重复键正则", rulespec.Condition{Type: rulespec.ConditionQueryRegex, Name: "tag", Pattern: `^b\s`}, true},
+		{"键区分大小写", rulespec.Condition{Type: rulespec.ConditionQueryExists, Name: "TAG"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "r",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{tt.cond}},
+			}}
+			got := len(engine.New(cfg).Eval(req, rulespec.StageRequest)) == 1
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_FormFields(t *testing.T) {
+	multipart := domain.NewRequest()
+	multipart.Method = "POST"
+	multipart.Headers.Set("Content-Type", "multipart/form-data; boundary=XYZ")
+	multipart.Body = []byte("--XYZ\r\n" +
+		"Content-Disposition: form-data; name=\"user\"\r\n\r\nalice\r\n" +
+		"--XYZ\r\n" +
+		"Content-Disposition: form-data; name=\"file\"; filename=\"report.pdf\"\r\nContent-Type: application/pdf\r\n\r\n%PDF\r\n" +
+		"--XYZ--\r\n")
+
+	urlencoded := domain.NewRequest()
+	urlencoded.Method = "POST"
+	urlencoded.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	urlencoded.Body = []byte("user=bob&tag=a&tag=b+c")
+
+	jsonReq := domain.NewRequest()
+	jsonReq.Method = "POST"
+	jsonReq.Headers.Set("Content-Type", "application/json")
+	jsonReq.Body = []byte("user=bob")
+
+	tests := []struct {
+		name string
+		req  *domain.Request
+		cond rulespec.Condition
+		want bool
+	}{
+		{"multipart 字段存在", multipart, rulespec.Condition{Type: rulespec.ConditionFormFieldExists, Name: "user"}, true},
+		{"multipart 字段相等", multipart, rulespec.Condition{Type: rulespec.ConditionFormFieldEquals, Name: "user", Value: "alice"}, true},
+		{"multipart 文件字段按文件名匹配", multipart, rulespec.Condition{Type: rulespec.ConditionFormFieldRegex, Name: "file", Pattern: `\.pdf$`}, true},
+		{"urlencoded 重复字段任一值", urlencoded, rulespec.Condition{Type: rulespec.ConditionFormFieldEquals, Name: "tag", Value: "b c"}, true},
+		{"urlencoded 字段不存在", urlencoded, rulespec.Condition{Type: rulespec.ConditionFormFieldExists, Name: "missing"}, false},
+		{"非表单类型不解析", jsonReq, rulespec.Condition{Type: rulespec.ConditionFormFieldExists, Name: "user"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "r",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{tt.cond}},
+			}}
+			got := len(engine.New(cfg).Eval(tt.req, rulespec.StageRequest)) == 1
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_GraphQL(t *testing.T) {
+	single := domain.NewRequest()
+	single.Method = "POST"
+	single.URL = "https://api.example.com/graphql"
+	single.Body = []byte(`{"operationName":"GetUser","query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"42","filter":{"tags":["a","b"]}}}`)
+
+	batch := domain.NewRequest()
+	batch.Method = "POST"
+	batch.URL = "https://api.example.com/graphql"
+	batch.Body = []byte(`[{"query":"query Feed { feed { id } }"},{"query":"mutation Like($id: ID!) { like(id: $id) }","variables":{"id":7}}]`)
+
+	notGraphQL := domain.NewRequest()
+	notGraphQL.Body = []byte(`{"id":"42"}`)
+
+	tests := []struct {
+		name string
+		req  *domain.Request
+		cond rulespec.Condition
+		want bool
+	}{
+		{"操作名", single, rulespec.Condition{Type: rulespec.ConditionGraphQLOperationName, Values: []string{"GetUser"}}, true},
+		{"操作名区分大小写", single, rulespec.Condition{Type: rulespec.ConditionGraphQLOperationName, Values: []string{"getuser"}}, false},
+		{"操作类型", single, rulespec.Condition{Type: rulespec.ConditionGraphQLOperationType, Values: []string{"query"}}, true},
+		{"变量等于", single, rulespec.Condition{Type: rulespec.ConditionGraphQLVariable, Path: "$.id", Value: "42"}, true},
+		{"变量包含", single, rulespec.Condition{Type: rulespec.ConditionGraphQLVariable, Path: "$.filter.tags", Operator: rulespec.OperatorContains, Value: "b"}, true},
+		{"批量请求任一操作名", batch, rulespec.Condition{Type: rulespec.ConditionGraphQLOperationName, Values: []string{"Like"}}, true},
+		{"批量请求任一操作类型", batch, rulespec.Condition{Type: rulespec.ConditionGraphQLOperationType, Values: []string{"Mutation"}}, true},
+		{"批量请求变量", batch, rulespec.Condition{Type: rulespec.ConditionGraphQLVariable, Path: "$.id", Operator: rulespec.OperatorGt, Value: "5"}, true},
+		{"非 GraphQL 请求体", notGraphQL, rulespec.Condition{Type: rulespec.ConditionGraphQLVariable, Path: "$.id", Operator: rulespec.OperatorExists}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "r",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{tt.cond}},
+			}}
+			got := len(engine.New(cfg).Eval(tt.req, rulespec.StageRequest)) == 1
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestEval_CookieEquals(t *testing.T) {
 	cfg := rulespec.NewConfig("test")
 	cfg.Rules = []rulespec.Rule{
@@ -457,6 +602,69 @@ func TestEval_BodyJsonPath(t *testing.T) {
 	}
 }
 
+func TestEval_BodyJsonPathOperators(t *testing.T) {
+	body := []byte(`{"page":{"size":120,"no":"3"},"items":[{"id":41},{"id":42}],"ids":[1,42],"tag":"beta-user","ok":true,"empty":null}`)
+
+	tests := []struct {
+		name string
+		cond rulespec.Condition
+		want bool
+	}{
+		{"默认 eq 字符串", rulespec.Condition{Path: "$.tag", Value: "beta-user"}, true},
+		{"eq 数值比较", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorEq, Value: "120.0"}, true},
+		{"eq 布尔比较", rulespec.Condition{Path: "$.ok", Operator: rulespec.OperatorEq, Value: "true"}, true},
+		{"eq null", rulespec.Condition{Path: "$.empty", Operator: rulespec.OperatorEq, Value: "null"}, true},
+		{"ne 不等", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorNe, Value: "100"}, true},
+		{"ne 路径不存在", rulespec.Condition{Path: "$.missing", Operator: rulespec.OperatorNe, Value: "100"}, false},
+		{"gt 命中", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorGt, Value: "100"}, true},
+		{"gt 未命中", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorGt, Value: "120"}, false},
+		{"gte 边界", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorGte, Value: "120"}, true},
+		{"lt 字符串数字", rulespec.Condition{Path: "$.page.no", Operator: rulespec.OperatorLt, Value: "5"}, true},
+		{"lte 非数值", rulespec.Condition{Path: "$.tag", Operator: rulespec.OperatorLte, Value: "5"}, false},
+		{"gt 非法操作数", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorGt, Value: "abc"}, false},
+		{"exists", rulespec.Condition{Path: "$.empty", Operator: rulespec.OperatorExists}, true},
+		{"notExists", rulespec.Condition{Path: "$.missing", Operator: rulespec.OperatorNotExists}, true},
+		{"regex", rulespec.Condition{Path: "$.tag", Operator: rulespec.OperatorRegex, Value: `^beta-`}, true},
+		{"in 命中", rulespec.Condition{Path: "$.page.size", Operator: rulespec.OperatorIn, Values: []string{"100", "120"}}, true},
+		{"in 未命中", rulespec.Condition{Path: "$.tag", Operator: rulespec.OperatorIn, Values: []string{"alpha"}}, false},
+		{"数组包含", rulespec.Condition{Path: "$.ids", Operator: rulespec.OperatorContains, Value: "42"}, true},
+		{"对象数组包含", rulespec.Condition{Path: "$.items.#.id", Operator: rulespec.OperatorContains, Value: "42"}, true},
+		{"数组不包含", rulespec.Condition{Path: "$.ids", Operator: rulespec.OperatorContains, Value: "7"}, false},
+		{"字符串包含", rulespec.Condition{Path: "$.tag", Operator: rulespec.OperatorContains, Value: "user"}, true},
+		{"未知运算符", rulespec.Condition{Path: "$.tag", Operator: "like", Value: "beta-user"}, false},
+	}
+	for _, tt := range tests {
+		for _, stage := range []rulespec.Stage{rulespec.StageRequest, rulespec.StageResponse} {
+			t.Run(tt.name+"/"+string(stage), func(t *testing.T) {
+				cond := tt.cond
+				cond.Type = rulespec.ConditionBodyJsonPath
+				if stage == rulespec.StageResponse {
+					cond.Type = rulespec.ConditionResponseBodyJsonPath
+				}
+				cfg := rulespec.NewConfig("test")
+				cfg.Rules = []rulespec.Rule{{
+					ID:      "rule1",
+					Enabled: true,
+					Stage:   stage,
+					Match:   rulespec.Match{AllOf: []rulespec.Condition{cond}},
+				}}
+				eng := engine.New(cfg)
+
+				req := &domain.Request{ID: "req1", URL: "https://example.com/api", Method: "POST", Body: body}
+				var matched []*engine.MatchedRule
+				if stage == rulespec.StageRequest {
+					matched = eng.Eval(req, stage)
+				} else {
+					matched = eng.EvalResponse(req, &domain.Response{StatusCode: 200, Body: body})
+				}
+				if got := len(matched) == 1; got != tt.want {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
 func TestEval_Priority(t *testing.T) {
 	cfg := rulespec.NewConfig("test")
 	cfg.Rules = []rulespec.Rule{
@@ -594,6 +802,711 @@ func TestEval_AnyOf(t *testing.T) {
 	}
 }
 
+func TestEval_NestedGroups(t *testing.T) {
+	// (urlContains /api/) AND NOT (urlContains /auth/ OR urlContains /login)
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "rule1",
+			Name:    "nested rule",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionURLContains, Value: "/api/"},
+					{NoneOf: []rulespec.Condition{
+						{Type: rulespec.ConditionURLContains, Value: "/auth/"},
+						{Type: rulespec.ConditionURLContains, Value: "/login"},
+					}},
+				},
+			},
+		},
+	}
+
+	eng := engine.New(cfg)
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"https://example.com/api/users", 1},
+		{"https://example.com/api/auth/token", 0},
+		{"https://example.com/api/login", 0},
+		{"https://example.com/static/app.js", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := &domain.Request{ID: "req1", URL: tt.url, Method: "GET"}
+			matched := eng.Eval(req, rulespec.StageRequest)
+			if len(matched) != tt.want {
+				t.Errorf("got %d matches, want %d", len(matched), tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_NoneOfAndNegate(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "rule1",
+			Name:    "negate rule",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionMethod, Values: []string{"GET"}, Negate: true},
+					{AnyOf: []rulespec.Condition{
+						{Type: rulespec.ConditionURLContains, Value: "a.com"},
+						{Type: rulespec.ConditionURLContains, Value: "b.com"},
+					}},
+				},
+				NoneOf: []rulespec.Condition{
+					{Type: rulespec.ConditionHeaderExists, Name: "X-Skip"},
+				},
+			},
+		},
+	}
+
+	eng := engine.New(cfg)
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		headers domain.Header
+		want    int
+	}{
+		{"POST 命中", "POST", "https://a.com/x", nil, 1},
+		{"GET 被取反排除", "GET", "https://a.com/x", nil, 0},
+		{"子组不满足", "POST", "https://c.com/x", nil, 0},
+		{"noneOf 排除", "POST", "https://b.com/x", domain.Header{{Name: "X-Skip", Value: "1"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.Request{ID: "req1", URL: tt.url, Method: tt.method, Headers: tt.headers}
+			matched := eng.Eval(req, rulespec.StageRequest)
+			if len(matched) != tt.want {
+				t.Errorf("got %d matches, want %d", len(matched), tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch_FlatConfigCompatible(t *testing.T) {
+	raw := `{"allOf":[{"type":"urlContains","value":"example.com"}],"anyOf":[]}`
+	var m rulespec.Match
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(m.AllOf) != 1 || m.AllOf[0].IsGroup() || m.NoneOf != nil {
+		t.Errorf("unexpected match: %+v", m)
+	}
+	out, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(out) != raw {
+		t.Errorf("got %s, want %s", out, raw)
+	}
+}
+
+func TestEvalResponse_Conditions(t *testing.T) {
+	req := &domain.Request{ID: "req1", URL: "https://example.com/api", Method: "GET"}
+	res := &domain.Response{
+		StatusCode: 502,
+		Headers: domain.Header{
+			{Name: "content-type", Value: "application/json; charset=utf-8"},
+			{Name: "X-Trace", Value: "abc-123"},
+			{Name: "Set-Cookie", Value: "sid=1; Path=/"},
+			{Name: "Set-Cookie", Value: "token=abc; HttpOnly"},
+		},
+		Body: []byte(`{"error":{"code":"UPSTREAM"}}`),
+	}
+
+	tests := []struct {
+		name string
+		cond rulespec.Condition
+		want bool
+	}{
+		{"状态码命中", rulespec.Condition{Type: rulespec.ConditionStatusCode, Values: []string{"500", "502"}}, true},
+		{"状态码未命中", rulespec.Condition{Type: rulespec.ConditionStatusCode, Values: []string{"200"}}, false},
+		{"状态码范围", rulespec.Condition{Type: rulespec.ConditionStatusCodeRange, Value: "500-599"}, true},
+		{"状态码范围外", rulespec.Condition{Type: rulespec.ConditionStatusCodeRange, Value: "400-499"}, false},
+		{"非法范围", rulespec.Condition{Type: rulespec.ConditionStatusCodeRange, Value: "abc"}, false},
+		{"响应头存在", rulespec.Condition{Type: rulespec.ConditionResponseHeaderExists, Name: "X-Trace"}, true},
+		{"响应头不存在", rulespec.Condition{Type: rulespec.ConditionResponseHeaderNotExists, Name: "X-Other"}, true},
+		{"响应头相等", rulespec.Condition{Type: rulespec.ConditionResponseHeaderEquals, Name: "X-Trace", Value: "abc-123"}, true},
+		{"响应头包含", rulespec.Condition{Type: rulespec.ConditionResponseHeaderContains, Name: "X-Trace", Value: "abc"}, true},
+		{"响应头正则", rulespec.Condition{Type: rulespec.ConditionResponseHeaderRegex, Name: "X-Trace", Pattern: `^\w+-\d+$`}, true},
+		{"响应头名称忽略大小写", rulespec.Condition{Type: rulespec.ConditionResponseHeaderEquals, Name: "x-trace", Value: "abc-123"}, true},
+		{"多值响应头任一命中", rulespec.Condition{Type: rulespec.ConditionResponseHeaderContains, Name: "set-cookie", Value: "token="}, true},
+		{"多值响应头均不匹配", rulespec.Condition{Type: rulespec.ConditionResponseHeaderEquals, Name: "Set-Cookie", Value: "sid=1"}, false},
+		{"响应体包含", rulespec.Condition{Type: rulespec.ConditionResponseBodyContains, Value: "UPSTREAM"}, true},
+		{"响应体正则", rulespec.Condition{Type: rulespec.ConditionResponseBodyRegex, Pattern: `"code":"\w+"`}, true},
+		{"响应体 JSON Path", rulespec.Condition{Type: rulespec.ConditionResponseBodyJsonPath, Path: "$.error.code", Value: "UPSTREAM"}, true},
+		{"MIME 类型", rulespec.Condition{Type: rulespec.ConditionMimeType, Values: []string{"application/json"}}, true},
+		{"MIME 通配", rulespec.Condition{Type: rulespec.ConditionMimeType, Values: []string{"application/*"}}, true},
+		{"MIME 不匹配", rulespec.Condition{Type: rulespec.ConditionMimeType, Values: []string{"text/html"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageResponse,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{tt.cond}},
+			}}
+			eng := engine.New(cfg)
+			matched := eng.EvalResponse(req, res)
+			if got := len(matched) == 1; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_ResponseConditionWithoutResponse(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID:      "rule1",
+		Enabled: true,
+		Stage:   rulespec.StageResponse,
+		Match: rulespec.Match{AllOf: []rulespec.Condition{
+			{Type: rulespec.ConditionStatusCode, Values: []string{"500"}, Negate: true},
+		}},
+	}}
+	eng := engine.New(cfg)
+	req := &domain.Request{ID: "req1", URL: "https://example.com", Method: "GET"}
+	if matched := eng.Eval(req, rulespec.StageResponse); matched != nil {
+		t.Errorf("got %d matches, want nil", len(matched))
+	}
+}
+
+func TestEval_StructuredURLConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		cond rulespec.Condition
+		want bool
+	}{
+		{"主机精确", "https://api.example.com/x", rulespec.Condition{Type: rulespec.ConditionURLHost, Values: []string{"API.example.com"}}, true},
+		{"主机通配子域", "https://a.b.example.com/x", rulespec.Condition{Type: rulespec.ConditionURLHost, Values: []string{"*.example.com"}}, true},
+		{"主机通配根域", "https://example.com/x", rulespec.Condition{Type: rulespec.ConditionURLHost, Values: []string{"*.example.com"}}, true},
+		{"主机通配不误匹配", "https://badexample.com/x", rulespec.Condition{Type: rulespec.ConditionURLHost, Values: []string{"*.example.com"}}, false},
+		{"路径单段通配", "https://a.com/api/v1/users", rulespec.Condition{Type: rulespec.ConditionURLPath, Value: "/api/*/users"}, true},
+		{"路径单段不跨层", "https://a.com/api/v1/v2/users", rulespec.Condition{Type: rulespec.ConditionURLPath, Value: "/api/*/users"}, false},
+		{"路径多层通配", "https://a.com/api/v1/v2/users", rulespec.Condition{Type: rulespec.ConditionURLPath, Value: "/api/**/users"}, true},
+		{"路径多层零层", "https://a.com/api/users", rulespec.Condition{Type: rulespec.ConditionURLPath, Value: "/api/**/users"}, true},
+		{"路径尾部多层", "https://a.com/static/js/app.js?v=1", rulespec.Condition{Type: rulespec.ConditionURLPath, Value: "/static/**"}, true},
+		{"路径特殊字符", "https://a.com/a+b.json", rulespec.Condition{Type: rulespec.ConditionURLPath, Value: "/a+b.json"}, true},
+		{"协议", "HTTPS://a.com/", rulespec.Condition{Type: rulespec.ConditionURLScheme, Values: []string{"https"}}, true},
+		{"默认端口", "https://a.com/", rulespec.Condition{Type: rulespec.ConditionURLPort, Values: []string{"443"}}, true},
+		{"显式端口", "http://localhost:8080/", rulespec.Condition{Type: rulespec.ConditionURLPort, Values: []string{"8080"}}, true},
+		{"去除片段", "https://a.com/page#top", rulespec.Condition{Type: rulespec.ConditionURLNoFragment, Value: "https://a.com/page"}, true},
+		{"模式匹配", "https://cdn.example.com/api/items?id=1", rulespec.Condition{Type: rulespec.ConditionURLPattern, Value: "*://*.example.com/api/*"}, true},
+		{"模式协议不符", "ftp://cdn.example.com/api/items", rulespec.Condition{Type: rulespec.ConditionURLPattern, Value: "*://*.example.com/api/*"}, false},
+		{"模式端口", "http://localhost:3000/app", rulespec.Condition{Type: rulespec.ConditionURLPattern, Value: "http://localhost:3000/*"}, true},
+		{"模式端口不符", "http://localhost:4000/app", rulespec.Condition{Type: rulespec.ConditionURLPattern, Value: "http://localhost:3000/*"}, false},
+		{"全部 URL", "wss://a.com/socket", rulespec.Condition{Type: rulespec.ConditionURLPattern, Value: "<all_urls>"}, true},
+		{"非法模式", "https://a.com/", rulespec.Condition{Type: rulespec.ConditionURLPattern, Value: "not-a-pattern"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{tt.cond}},
+			}}
+			eng := engine.New(cfg)
+			req := &domain.Request{ID: "req1", URL: tt.url, Method: "GET"}
+			if got := len(eng.Eval(req, rulespec.StageRequest)) == 1; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_PageConditions(t *testing.T) {
+	admin := &domain.PageContext{
+		TargetID:  "T-ADMIN",
+		PageURL:   "https://admin.example.com/console#/orders",
+		PageTitle: "Admin Console",
+		FrameURL:  "https://widgets.example.com/chart",
+	}
+	store := &domain.PageContext{
+		TargetID:  "T-STORE",
+		PageURL:   "https://shop.example.com/",
+		PageTitle: "Storefront",
+		FrameURL:  "https://shop.example.com/",
+	}
+
+	tests := []struct {
+		name string
+		cond rulespec.Condition
+		page *domain.PageContext
+		want bool
+	}{
+		{"目标 ID", rulespec.Condition{Type: rulespec.ConditionTargetID, Values: []string{"T-ADMIN"}}, admin, true},
+		{"目标 ID 不符", rulespec.Condition{Type: rulespec.ConditionTargetID, Values: []string{"T-ADMIN"}}, store, false},
+		{"页面 URL 包含", rulespec.Condition{Type: rulespec.ConditionPageURLContains, Value: "admin.example.com"}, admin, true},
+		{"页面 URL 模式", rulespec.Condition{Type: rulespec.ConditionPageURLPattern, Value: "https://admin.example.com/*"}, admin, true},
+		{"页面 URL 模式不符", rulespec.Condition{Type: rulespec.ConditionPageURLPattern, Value: "https://admin.example.com/*"}, store, false},
+		{"页面 URL 正则", rulespec.Condition{Type: rulespec.ConditionPageURLRegex, Pattern: `/console`}, admin, true},
+		{"页面标题包含", rulespec.Condition{Type: rulespec.ConditionPageTitleContains, Value: "Admin"}, admin, true},
+		{"页面标题正则", rulespec.Condition{Type: rulespec.ConditionPageTitleRegex, Pattern: `^Store`}, store, true},
+		{"frame URL 包含", rulespec.Condition{Type: rulespec.ConditionFrameURLContains, Value: "widgets."}, admin, true},
+		{"frame URL 正则", rulespec.Condition{Type: rulespec.ConditionFrameURLRegex, Pattern: `^https://shop\.`}, admin, false},
+		{"无页面上下文", rulespec.Condition{Type: rulespec.ConditionPageURLContains, Value: ""}, nil, false},
+		{"无页面上下文取反", rulespec.Condition{Type: rulespec.ConditionTargetID, Values: []string{"T-ADMIN"}, Negate: true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{tt.cond}},
+			}}
+			eng := engine.New(cfg)
+			req := &domain.Request{ID: "req1", URL: "https://api.example.com/orders", Method: "GET", Page: tt.page}
+			if got := len(eng.Eval(req, rulespec.StageRequest)) == 1; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_HostIndexKeepsPriorityOrder(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID: "hosted-low", Enabled: true, Priority: 1, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLPrefix, Value: "https://api.example.com/"},
+			}},
+		},
+		{
+			ID: "generic-mid", Enabled: true, Priority: 5, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLContains, Value: "/v1/"},
+			}},
+		},
+		{
+			ID: "hosted-high", Enabled: true, Priority: 10, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AnyOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLEquals, Value: "https://api.example.com/v1/users"},
+				{Type: rulespec.ConditionURLPrefix, Value: "https://other.com/"},
+			}},
+		},
+		{
+			ID: "other-host", Enabled: true, Priority: 20, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLPrefix, Value: "https://static.example.com/"},
+			}},
+		},
+	}
+
+	eng := engine.New(cfg)
+	req := &domain.Request{ID: "req1", URL: "https://api.example.com/v1/users", Method: "GET"}
+	matched := eng.Eval(req, rulespec.StageRequest)
+
+	want := []string{"hosted-high", "generic-mid", "hosted-low"}
+	if len(matched) != len(want) {
+		t.Fatalf("got %d matches, want %d", len(matched), len(want))
+	}
+	for i, id := range want {
+		if matched[i].Rule.ID != id {
+			t.Errorf("matched[%d] = %s, want %s", i, matched[i].Rule.ID, id)
+		}
+	}
+}
+
+func TestEval_PartialHostPrefix(t *testing.T) {
+	// 前缀未覆盖完整主机名时不能按主机索引
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID: "rule1", Enabled: true, Stage: rulespec.StageRequest,
+		Match: rulespec.Match{AllOf: []rulespec.Condition{
+			{Type: rulespec.ConditionURLPrefix, Value: "https://example.co"},
+		}},
+	}}
+	eng := engine.New(cfg)
+
+	for _, u := range []string{"https://example.co/a", "https://example.com/a", "https://example.co.uk/a"} {
+		req := &domain.Request{ID: "req1", URL: u, Method: "GET"}
+		if matched := eng.Eval(req, rulespec.StageRequest); len(matched) != 1 {
+			t.Errorf("%s: got %d matches, want 1", u, len(matched))
+		}
+	}
+}
+
+func TestEval_InvalidRegexNeverMatches(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID: "rule1", Enabled: true, Stage: rulespec.StageRequest,
+		Match: rulespec.Match{AllOf: []rulespec.Condition{
+			{Type: rulespec.ConditionURLRegex, Pattern: "("},
+		}},
+	}}
+	eng := engine.New(cfg)
+	req := &domain.Request{ID: "req1", URL: "https://example.com/(", Method: "GET"}
+	if matched := eng.Eval(req, rulespec.StageRequest); matched != nil {
+		t.Errorf("got %d matches, want nil", len(matched))
+	}
+}
+
+func TestEval_ConcurrentUpdate(t *testing.T) {
+	newCfg := func(value string) *rulespec.Config {
+		cfg := rulespec.NewConfig("test")
+		cfg.Rules = []rulespec.Rule{{
+			ID: "rule1", Enabled: true, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLContains, Value: value},
+			}},
+		}}
+		return cfg
+	}
+	eng := engine.New(newCfg("example.com"))
+	req := &domain.Request{ID: "req1", URL: "https://example.com/a", Method: "GET"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			eng.Update(newCfg("example.com"))
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if matched := eng.Eval(req, rulespec.StageRequest); len(matched) != 1 {
+			t.Fatalf("got %d matches, want 1", len(matched))
+		}
+	}
+	<-done
+}
+
+func BenchmarkEval_ManyRules(b *testing.B) {
+	cfg := rulespec.NewConfig("bench")
+	for i := 0; i < 500; i++ {
+		cfg.Rules = append(cfg.Rules, rulespec.Rule{
+			ID: rulespec.GenerateRuleID(i), Enabled: true, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLPrefix, Value: fmt.Sprintf("https://host%d.example.com/", i)},
+				{Type: rulespec.ConditionURLRegex, Pattern: `/api/v\d+/items/\d+`},
+			}},
+		})
+	}
+	eng := engine.New(cfg)
+	req := &domain.Request{ID: "req1", URL: "https://host42.example.com/api/v1/items/7", Method: "GET"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		eng.Eval(req, rulespec.StageRequest)
+	}
+}
+
+func TestEval_SessionState(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID: "started", Enabled: true, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionScenarioState, Name: "cart", Values: []string{rulespec.ScenarioStarted}}}},
+		},
+		{
+			ID: "checkout", Enabled: true, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionScenarioState, Name: "cart", Values: []string{"Filled", " Paid "}}}},
+		},
+		{
+			ID: "hasToken", Enabled: true, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionVariableExists, Name: "token"}}},
+		},
+		{
+			ID: "tokenIsA", Enabled: true, Stage: rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionVariableEquals, Name: "token", Value: "a"}}},
+		},
+	}
+	e := engine.New(cfg)
+	req := domain.NewRequest()
+	req.URL = "https://example.com/"
+
+	ids := func() []string {
+		var out []string
+		for _, m := range e.Eval(req, rulespec.StageRequest) {
+			out = append(out, m.Rule.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		setup func()
+		want  []string
+	}{
+		{"初始状态", func() {}, []string{"started"}},
+		{"切换场景状态", func() { e.SetScenarioState("cart", "Paid") }, []string{"checkout"}},
+		{"设置变量", func() { e.SetVariable("token", "b") }, []string{"checkout", "hasToken"}},
+		{"变量值匹配", func() { e.SetVariable("token", "a") }, []string{"checkout", "hasToken", "tokenIsA"}},
+		{"更新规则后保留状态", func() { e.Update(cfg) }, []string{"checkout", "hasToken", "tokenIsA"}},
+		{"重置状态", func() { e.ResetState() }, []string{"started"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			if got := ids(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	e.SetScenarioState("cart", "Filled")
+	e.SetVariable("token", "x")
+	state := e.GetState()
+	if state.Scenarios["cart"] != "Filled" || state.Variables["token"] != "x" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	state.Variables["token"] = "mutated"
+	if v, _ := e.Variable("token"); v != "x" {
+		t.Error("GetState should return a copy")
+	}
+	if got := e.ScenarioState("unknown"); got != rulespec.ScenarioStarted {
+		t.Errorf("unknown scenario state = %q", got)
+	}
+
+	for _, tr := range e.Explain(req, nil, rulespec.StageRequest) {
+		if tr.RuleID != "started" {
+			continue
+		}
+		want := `scenario "cart" state is "Filled"; expected one of [Started]`
+		if c := tr.Conditions[0]; c.Matched || c.Reason != want {
+			t.Errorf("explain: got matched=%v reason=%q", c.Matched, c.Reason)
+		}
+	}
+}
+
+func TestMatchedRule_Captures(t *testing.T) {
+	req := domain.NewRequest()
+	req.URL = "https://example.com/api/users/42/posts"
+	req.Headers.Set("Authorization", "Bearer tok-123")
+
+	tests := []struct {
+		name      string
+		match     rulespec.Match
+		wantGroup string
+		wantNamed string
+	}{
+		{
+			name: "URL 正则命名分组",
+			match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLContains, Value: "example.com"},
+				{Type: rulespec.ConditionURLRegex, Pattern: `/users/(?P<id>\d+)`},
+			}},
+			wantGroup: "42",
+			wantNamed: "42",
+		},
+		{
+			name: "跳过取反条件并进入嵌套组",
+			match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLRegex, Pattern: `/admin/(\w+)`, Negate: true},
+				{AnyOf: []rulespec.Condition{
+					{Type: rulespec.ConditionHeaderRegex, Name: "authorization", Pattern: `^Bearer (?P<id>.+)$`},
+				}},
+			}},
+			wantGroup: "tok-123",
+			wantNamed: "tok-123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{ID: "r", Enabled: true, Stage: rulespec.StageRequest, Match: tt.match}}
+			matched := engine.New(cfg).Eval(req, rulespec.StageRequest)
+			if len(matched) != 1 {
+				t.Fatalf("expected rule to match")
+			}
+			groups, named := matched[0].Captures(req, nil)
+			if len(groups) < 2 || groups[1] != tt.wantGroup || named["id"] != tt.wantNamed {
+				t.Errorf("got groups %v named %v", groups, named)
+			}
+		})
+	}
+
+	if groups, _ := (&engine.MatchedRule{}).Captures(req, nil); groups != nil {
+		t.Errorf("rule without compiled conditions should have no captures, got %v", groups)
+	}
+}
+
+func TestExplain_ConditionReasons(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:       "need-token",
+			Enabled:  true,
+			Priority: 10,
+			Stage:    rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionURLContains, Value: "/api/"},
+					{Type: rulespec.ConditionHeaderExists, Name: "X-Token"},
+				},
+			},
+		},
+		{
+			ID:      "disabled",
+			Enabled: false,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/api/"}},
+			},
+		},
+		{
+			ID:      "nested",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AnyOf: []rulespec.Condition{
+					{Type: rulespec.ConditionMethod, Values: []string{"POST"}},
+					{AllOf: []rulespec.Condition{{Type: rulespec.ConditionQueryEquals, Name: "id", Value: "1"}}},
+				},
+			},
+		},
+		{
+			ID:      "response-rule",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionStatusCode, Values: []string{"500"}}},
+			},
+		},
+	}
+	eng := engine.New(cfg)
+	req := &domain.Request{
+		ID:      "req1",
+		URL:     "https://example.com/api/users?id=1",
+		Method:  "GET",
+		Headers: domain.Header{},
+		Query:   domain.Query{{Key: "id", Value: "1"}},
+	}
+
+	traces := eng.Explain(req, nil, rulespec.StageRequest)
+	if len(traces) != 3 {
+		t.Fatalf("got %d traces, want 3", len(traces))
+	}
+	byID := make(map[string]domain.RuleExplain)
+	for _, tr := range traces {
+		byID[tr.RuleID] = tr
+	}
+
+	token := byID["need-token"]
+	if token.Matched || len(token.Conditions) != 2 {
+		t.Fatalf("need-token: got matched=%v conditions=%d", token.Matched, len(token.Conditions))
+	}
+	if !token.Conditions[0].Matched {
+		t.Errorf("url condition should match: %s", token.Conditions[0].Reason)
+	}
+	if c := token.Conditions[1]; c.Matched || !strings.Contains(c.Reason, `header "X-Token" missing`) {
+		t.Errorf("header condition: got matched=%v reason=%q", c.Matched, c.Reason)
+	}
+
+	if d := byID["disabled"]; d.Matched || d.Reason != "rule disabled" {
+		t.Errorf("disabled: got matched=%v reason=%q", d.Matched, d.Reason)
+	}
+
+	nested := byID["nested"]
+	if !nested.Matched || len(nested.Conditions) != 2 {
+		t.Fatalf("nested: got matched=%v conditions=%d", nested.Matched, len(nested.Conditions))
+	}
+	if c := nested.Conditions[0]; c.Matched || c.Group != "anyOf" {
+		t.Errorf("method condition: got matched=%v group=%s", c.Matched, c.Group)
+	}
+	if c := nested.Conditions[1]; !c.Matched || len(c.Children) != 1 {
+		t.Errorf("nested group: got matched=%v children=%d", c.Matched, len(c.Children))
+	}
+
+	resTraces := eng.Explain(req, nil, rulespec.StageResponse)
+	if len(resTraces) != 1 || resTraces[0].Conditions[0].Reason != "no response available" {
+		t.Errorf("response rule without response: got %+v", resTraces)
+	}
+}
+
+func TestAllow_Limits(t *testing.T) {
+	seed := int64(42)
+	tests := []struct {
+		name  string
+		limit *rulespec.RuleLimit
+		want  string // 连续 6 次匹配的生效情况
+	}{
+		{"无限制", nil, "111111"},
+		{"前 2 次", &rulespec.RuleLimit{MaxHits: 2}, "110000"},
+		{"每第 3 次", &rulespec.RuleLimit{EveryNth: 3}, "001001"},
+		{"每第 2 次且最多 2 次", &rulespec.RuleLimit{EveryNth: 2, MaxHits: 2}, "010100"},
+		{"已过期", &rulespec.RuleLimit{ExpiresAt: time.Now().Add(-time.Minute).UnixMilli()}, "000000"},
+		{"未过期", &rulespec.RuleLimit{ExpiresAt: time.Now().Add(time.Hour).UnixMilli()}, "111111"},
+		{"概率 1 总是生效", &rulespec.RuleLimit{Probability: 1}, "111111"},
+		{"极小概率", &rulespec.RuleLimit{Probability: 1e-12, Seed: &seed}, "000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := engine.New(rulespec.NewConfig("test"))
+			rule := &rulespec.Rule{ID: "rule1", Limit: tt.limit}
+			got := ""
+			for i := 0; i < len(tt.want); i++ {
+				if eng.Allow(rule) {
+					got += "1"
+				} else {
+					got += "0"
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllow_SeedReproducible(t *testing.T) {
+	seed := int64(7)
+	rule := &rulespec.Rule{ID: "rule1", Limit: &rulespec.RuleLimit{Probability: 0.5, Seed: &seed}}
+	run := func() string {
+		eng := engine.New(rulespec.NewConfig("test"))
+		out := ""
+		for i := 0; i < 32; i++ {
+			if eng.Allow(rule) {
+				out += "1"
+			} else {
+				out += "0"
+			}
+		}
+		return out
+	}
+	first, second := run(), run()
+	if first != second {
+		t.Errorf("seeded results differ: %s vs %s", first, second)
+	}
+	if !strings.Contains(first, "0") || !strings.Contains(first, "1") {
+		t.Errorf("probability 0.5 should both fire and skip, got %s", first)
+	}
+}
+
+func TestAllow_ResetOnUpdate(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	eng := engine.New(cfg)
+	rule := &rulespec.Rule{ID: "rule1", Limit: &rulespec.RuleLimit{MaxHits: 1}}
+
+	if !eng.Allow(rule) {
+		t.Fatal("first hit should fire")
+	}
+	if eng.Allow(rule) {
+		t.Fatal("second hit should be limited")
+	}
+	eng.Update(cfg)
+	if !eng.Allow(rule) {
+		t.Error("counter should reset after Update")
+	}
+}
+
 func TestRecordStats(t *testing.T) {
 	cfg := rulespec.NewConfig("test")
 	cfg.Rules = []rulespec.Rule{
@@ -618,17 +1531,75 @@ func TestRecordStats(t *testing.T) {
 	}
 
 	matched := eng.Eval(req, rulespec.StageRequest)
-	eng.RecordStats(matched)
+	eng.RecordStats(rulespec.StageRequest, matched, 3*time.Millisecond)
 
-	total, matchedCount, byRule := eng.GetStats()
-	if total != 1 {
-		t.Errorf("got total %d, want 1", total)
+	stats := eng.GetStats()
+	if stats.Total != 1 {
+		t.Errorf("got total %d, want 1", stats.Total)
+	}
+	if stats.Matched != 1 {
+		t.Errorf("got matched %d, want 1", stats.Matched)
 	}
-	if matchedCount != 1 {
-		t.Errorf("got matched %d, want 1", matchedCount)
+	if stats.ByRule["rule1"] != 1 {
+		t.Errorf("got rule1 count %d, want 1", stats.ByRule["rule1"])
 	}
-	if byRule["rule1"] != 1 {
-		t.Errorf("got rule1 count %d, want 1", byRule["rule1"])
+	stage := stats.ByStage["request"]
+	if stage.Total != 1 || stage.Matched != 1 || stage.EvalTimeUS != 3000 {
+		t.Errorf("got request stage stats %+v", stage)
+	}
+	rs := stats.Rules["rule1"]
+	if rs.Hits != 1 || rs.LastMatchAt == 0 {
+		t.Errorf("got rule1 stats %+v", rs)
+	}
+}
+
+func TestRecordApply(t *testing.T) {
+	eng := engine.New(rulespec.NewConfig("test"))
+	eng.RecordApply("rule1", time.Millisecond, nil)
+	eng.RecordApply("rule1", 2*time.Millisecond, []error{errors.New("decode"), errors.New("patch")})
+
+	rs := eng.GetStats().Rules["rule1"]
+	if rs.ApplyTimeUS != 3000 {
+		t.Errorf("got apply time %dus, want 3000", rs.ApplyTimeUS)
+	}
+	if rs.ActionErrors != 2 {
+		t.Errorf("got action errors %d, want 2", rs.ActionErrors)
+	}
+	if rs.LastError != "patch" {
+		t.Errorf("got last error %q, want %q", rs.LastError, "patch")
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID:      "rule1",
+		Enabled: true,
+		Stage:   rulespec.StageRequest,
+		Limit:   &rulespec.RuleLimit{MaxHits: 1},
+		Match: rulespec.Match{AllOf: []rulespec.Condition{
+			{Type: rulespec.ConditionURLContains, Value: "example.com"},
+		}},
+	}}
+	eng := engine.New(cfg)
+	matched := eng.Eval(&domain.Request{URL: "https://example.com"}, rulespec.StageRequest)
+	if !eng.Allow(matched[0].Rule) {
+		t.Fatal("first hit should be allowed")
+	}
+	eng.RecordStats(rulespec.StageRequest, matched, time.Microsecond)
+	eng.RecordApply("rule1", time.Microsecond, []error{errors.New("boom")})
+	before := eng.GetStats().ResetAt
+
+	eng.ResetStats()
+	stats := eng.GetStats()
+	if stats.Total != 0 || stats.Matched != 0 || len(stats.ByRule) != 0 || len(stats.ByStage) != 0 || len(stats.Rules) != 0 {
+		t.Errorf("stats not cleared: %+v", stats)
+	}
+	if stats.ResetAt < before {
+		t.Errorf("resetAt went backwards: %d < %d", stats.ResetAt, before)
+	}
+	if eng.Allow(matched[0].Rule) {
+		t.Error("ResetStats should not reset hit limits")
 	}
 }
 
@@ -636,14 +1607,204 @@ func TestGetStats(t *testing.T) {
 	cfg := rulespec.NewConfig("test")
 	eng := engine.New(cfg)
 
-	total, matched, byRule := eng.GetStats()
-	if total != 0 {
-		t.Errorf("got total %d, want 0", total)
+	stats := eng.GetStats()
+	if stats.Total != 0 {
+		t.Errorf("got total %d, want 0", stats.Total)
+	}
+	if stats.Matched != 0 {
+		t.Errorf("got matched %d, want 0", stats.Matched)
+	}
+	if len(stats.ByRule) != 0 {
+		t.Errorf("got byRule len %d, want 0", len(stats.ByRule))
+	}
+	if stats.ResetAt == 0 {
+		t.Error("resetAt should be set")
+	}
+}
+
+func TestResponseBodyNeeded(t *testing.T) {
+	urlIs := func(v string) rulespec.Condition {
+		return rulespec.Condition{Type: rulespec.ConditionURLContains, Value: v}
 	}
-	if matched != 0 {
-		t.Errorf("got matched %d, want 0", matched)
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID: "header", Enabled: true, Stage: rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{urlIs("/header")}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-A", Value: "1"}},
+		},
+		{
+			ID: "replace", Enabled: true, Stage: rulespec.StageResponse,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				urlIs("/replace"),
+				{Type: rulespec.ConditionStatusCode, Values: []string{"200"}},
+			}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionReplaceBodyText, Search: "a", Replace: "b"}},
+		},
+		{
+			ID: "bodyCond", Enabled: true, Stage: rulespec.StageResponse,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				urlIs("/cond"),
+				{AnyOf: []rulespec.Condition{{Type: rulespec.ConditionResponseBodyContains, Value: "x"}}},
+			}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionSetStatus, Value: 500}},
+		},
+		{
+			ID: "throttle", Enabled: true, Stage: rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{urlIs("/slow")}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionThrottle, BytesPerSecond: 100}},
+		},
+		{
+			ID: "request", Enabled: true, Stage: rulespec.StageRequest,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{urlIs("/request")}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionReplaceBodyText, Search: "a", Replace: "b"}},
+		},
+	}
+	eng := engine.New(cfg)
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		want   bool
+	}{
+		{"只修改头部", "https://example.com/header", 200, false},
+		{"命中且需要修改响应体", "https://example.com/replace", 200, true},
+		{"其他条件不满足", "https://example.com/replace", 404, false},
+		{"嵌套的响应体条件", "https://example.com/cond", 200, true},
+		{"限速", "https://example.com/slow", 200, true},
+		{"请求阶段规则不影响", "https://example.com/request", 200, false},
+		{"无候选规则", "https://example.com/none", 200, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.Request{ID: "r", URL: tt.url, Method: "GET"}
+			res := &domain.Response{StatusCode: tt.status, BodyOmitted: true}
+			if got := eng.ResponseBodyNeeded(req, res); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptPatterns_Conditions(t *testing.T) {
+	cond := func(typ rulespec.ConditionType, value string, values ...string) rulespec.Condition {
+		return rulespec.Condition{Type: typ, Value: value, Values: values}
+	}
+	tests := []struct {
+		name  string
+		match rulespec.Match
+		want  []string
+	}{
+		{"urlEquals", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLEquals, "https://a.com/x?q=*")}}, []string{`https://a.com/x\?q=\*`}},
+		{"urlPrefix", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLPrefix, "https://a.com/api/")}}, []string{"https://a.com/api/*"}},
+		{"urlSuffix", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLSuffix, ".json")}}, []string{"*.json"}},
+		{"urlContains", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLContains, "/api/")}}, []string{"*/api/*"}},
+		{"urlContains 含星号", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLContains, "a*")}}, []string{`*a\**`}},
+		{"urlNoFragment", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLNoFragment, "https://a.com/#top")}}, []string{"https://a.com/"}},
+		{"锚定的 urlRegex", rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLRegex, Pattern: `^https://a\.com/v\d+/`}}}, []string{"https://a.com/v*"}},
+		{"未锚定的 urlRegex", rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLRegex, Pattern: `/graphql$`}}}, []string{"*/graphql*"}},
+		{"无字面量前缀的 urlRegex", rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLRegex, Pattern: `(?i)api`}}}, []string{"*"}},
+		{"urlHost", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLHost, "", "*.a.com", "B.com")}}, []string{"*://*.a.com/*", "*://*.a.com:*", "*://a.com/*", "*://a.com:*", "*://b.com/*", "*://b.com:*"}},
+		{"urlPath", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLPath, "/api/**/users")}}, []string{"*/api/*/users", `*/api/*/users\?*`}},
+		{"urlScheme", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLScheme, "", "HTTP")}}, []string{"http://*"}},
+		{"urlPort 默认端口", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLPort, "", "443")}}, []string{"*://*:443/*", "https://*"}},
+		{"urlPattern", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLPattern, "https://*.a.com/api/*")}}, []string{"https://*.a.com/api/*", "https://*.a.com:*/api/*", "https://a.com/api/*", "https://a.com:*/api/*"}},
+		{"urlPattern 指定端口", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLPattern, "*://a.com:8080/x")}}, []string{"http://a.com:8080/x", "https://a.com:8080/x"}},
+		{"<all_urls>", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionURLPattern, "<all_urls>")}}, []string{"*"}},
+		{"取反条件不收窄", rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/api/", Negate: true}}}, []string{"*"}},
+		{"非 URL 条件不收窄", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionMethod, "", "POST")}}, []string{"*"}},
+		{"allOf 取第一个可表达的条件", rulespec.Match{AllOf: []rulespec.Condition{cond(rulespec.ConditionMethod, "", "POST"), cond(rulespec.ConditionURLContains, "/a"), cond(rulespec.ConditionURLContains, "/b")}}, []string{"*/a*"}},
+		{"anyOf 全部可表达时取并集", rulespec.Match{AnyOf: []rulespec.Condition{cond(rulespec.ConditionURLContains, "/a"), cond(rulespec.ConditionURLSuffix, ".js")}}, []string{"*/a*", "*.js"}},
+		{"anyOf 部分不可表达", rulespec.Match{AnyOf: []rulespec.Condition{cond(rulespec.ConditionURLContains, "/a"), cond(rulespec.ConditionMethod, "", "POST")}}, []string{"*"}},
+		{"嵌套条件组", rulespec.Match{AllOf: []rulespec.Condition{{AnyOf: []rulespec.Condition{cond(rulespec.ConditionURLContains, "/a"), cond(rulespec.ConditionURLContains, "/b")}}}}, []string{"*/a*", "*/b*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID: "r", Enabled: true, Stage: rulespec.StageResponse, Match: tt.match,
+				Actions: []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-A", Value: "1"}},
+			}}
+			var got []string
+			for _, p := range engine.New(cfg).InterceptPatterns() {
+				if !p.Response {
+					got = append(got, p.URLPattern)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptPatterns_Stages(t *testing.T) {
+	urlIs := func(v string) rulespec.Match {
+		return rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLPrefix, Value: v}}}
+	}
+	header := []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-A", Value: "1"}}
+	tests := []struct {
+		name  string
+		rules []rulespec.Rule
+		want  []domain.InterceptPattern
+	}{
+		{"无启用的规则", []rulespec.Rule{{ID: "off", Stage: rulespec.StageRequest, Match: urlIs("https://a.com/"), Actions: header}}, nil},
+		{
+			"请求阶段规则同时在响应阶段记录",
+			[]rulespec.Rule{{ID: "req", Enabled: true, Stage: rulespec.StageRequest, Match: urlIs("https://a.com/"), Actions: header}},
+			[]domain.InterceptPattern{{URLPattern: "https://a.com/*"}, {URLPattern: "https://a.com/*", Response: true}},
+		},
+		{
+			"block 规则只在请求阶段拦截",
+			[]rulespec.Rule{
+				{ID: "block", Enabled: true, Stage: rulespec.StageRequest, Match: urlIs("https://ads.com/"), Actions: []rulespec.Action{{Type: rulespec.ActionBlock, StatusCode: 204}}},
+				{ID: "res", Enabled: true, Stage: rulespec.StageResponse, Match: urlIs("https://a.com/"), Actions: header},
+			},
+			[]domain.InterceptPattern{{URLPattern: "https://ads.com/*"}, {URLPattern: "https://a.com/*"}, {URLPattern: "https://a.com/*", Response: true}},
+		},
+		{
+			"修改 URL 的规则使响应阶段回退为全部",
+			[]rulespec.Rule{{ID: "remote", Enabled: true, Stage: rulespec.StageRequest, Match: urlIs("https://a.com/"), Actions: []rulespec.Action{{Type: rulespec.ActionMapRemote, To: "http://localhost:3000"}}}},
+			[]domain.InterceptPattern{{URLPattern: "https://a.com/*"}, {URLPattern: "*", Response: true}},
+		},
+		{
+			"资源类型",
+			[]rulespec.Rule{{ID: "xhr", Enabled: true, Stage: rulespec.StageResponse, Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLHost, Values: []string{"a.com"}},
+				{Type: rulespec.ConditionResourceType, Values: []string{"XHR", "fetch"}},
+			}}, Actions: header}},
+			[]domain.InterceptPattern{
+				{URLPattern: "*://a.com/*", ResourceType: "xhr"}, {URLPattern: "*://a.com/*", ResourceType: "fetch"},
+				{URLPattern: "*://a.com:*", ResourceType: "xhr"}, {URLPattern: "*://a.com:*", ResourceType: "fetch"},
+				{URLPattern: "*://a.com/*", ResourceType: "xhr", Response: true}, {URLPattern: "*://a.com/*", ResourceType: "fetch", Response: true},
+				{URLPattern: "*://a.com:*", ResourceType: "xhr", Response: true}, {URLPattern: "*://a.com:*", ResourceType: "fetch", Response: true},
+			},
+		},
+		{
+			"可由扩展名推断的资源类型不收窄",
+			[]rulespec.Rule{{ID: "script", Enabled: true, Stage: rulespec.StageResponse, Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLPrefix, Value: "https://a.com/"},
+				{Type: rulespec.ConditionResourceType, Values: []string{"script"}},
+			}}, Actions: header}},
+			[]domain.InterceptPattern{{URLPattern: "https://a.com/*"}, {URLPattern: "https://a.com/*", Response: true}},
+		},
+		{
+			"存在无法收窄的规则时只保留全部",
+			[]rulespec.Rule{
+				{ID: "a", Enabled: true, Stage: rulespec.StageResponse, Match: urlIs("https://a.com/"), Actions: header},
+				{ID: "all", Enabled: true, Stage: rulespec.StageResponse, Actions: header},
+			},
+			[]domain.InterceptPattern{{URLPattern: "*"}, {URLPattern: "*", Response: true}},
+		},
 	}
-	if len(byRule) != 0 {
-		t.Errorf("got byRule len %d, want 0", len(byRule))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = tt.rules
+			if got := engine.New(cfg).InterceptPatterns(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
 	}
 }
@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxReasonValueLen 判定依据中实际值的最大展示长度
+const maxReasonValueLen = 120
+
+// Explain 评估指定阶段的全部规则（含禁用规则），返回逐条件的判定结果
+// 仅用于诊断，不计入统计与命中限制；res 为空时响应条件视为不匹配
+func (e *Engine) Explain(req *domain.Request, res *domain.Response, stage rulespec.Stage) []domain.RuleExplain {
+	in := &evalInput{state: e.state, req: req, res: res}
+	var out []domain.RuleExplain
+	for _, cr := range e.matcher.Load().rules {
+		if cr.rule.Stage != stage {
+			continue
+		}
+		matched, conds := cr.match.explain(in)
+		re := domain.RuleExplain{
+			RuleID:     cr.rule.ID,
+			RuleName:   cr.rule.Name,
+			Stage:      string(cr.rule.Stage),
+			Matched:    matched && cr.rule.Enabled,
+			Conditions: conds,
+		}
+		switch {
+		case !cr.rule.Enabled:
+			re.Reason = "rule disabled"
+		case !matched:
+			re.Reason = "conditions not satisfied"
+		}
+		out = append(out, re)
+	}
+	return out
+}
+
+// explain 评估条件组的全部条件（不短路），结果与 match 一致
+func (g *compiledGroup) explain(in *evalInput) (bool, []domain.ConditionExplain) {
+	var conds []domain.ConditionExplain
+	ok := true
+	for _, n := range g.allOf {
+		ce := n.explain(in, "allOf")
+		ok = ok && ce.Matched
+		conds = append(conds, ce)
+	}
+	if len(g.anyOf) > 0 {
+		anyMatch := false
+		for _, n := range g.anyOf {
+			ce := n.explain(in, "anyOf")
+			anyMatch = anyMatch || ce.Matched
+			conds = append(conds, ce)
+		}
+		ok = ok && anyMatch
+	}
+	for _, n := range g.noneOf {
+		ce := n.explain(in, "noneOf")
+		ok = ok && !ce.Matched
+		conds = append(conds, ce)
+	}
+	return ok, conds
+}
+
+// explain 评估单个条件节点并给出判定依据
+func (n *compiledNode) explain(in *evalInput, group string) domain.ConditionExplain {
+	ce := domain.ConditionExplain{Group: group, Negate: n.cond.Negate}
+	if n.group != nil {
+		ok, children := n.group.explain(in)
+		ce.Matched = ok != n.cond.Negate
+		ce.Children = children
+		ce.Reason = "nested group not satisfied"
+		if ok {
+			ce.Reason = "nested group satisfied"
+		}
+		if n.cond.Negate {
+			ce.Reason += " (negated)"
+		}
+		return ce
+	}
+
+	ce.Type = string(n.cond.Type)
+	if n.cond.Type.IsResponseOnly() && in.res == nil {
+		ce.Reason = "no response available"
+		return ce
+	}
+	ce.Matched = n.eval(in)
+	ce.Reason = n.reason(in)
+	return ce
+}
+
+// reason 生成 "<对象> is <实际值>; expected <期望>" 形式的判定依据
+func (n *compiledNode) reason(in *evalInput) string {
+	subject, actual, present := n.observe(in)
+	expect := n.expect()
+	if n.cond.Negate {
+		expect = "not (" + expect + ")"
+	}
+	if !present {
+		return fmt.Sprintf("%s missing; expected %s", subject, expect)
+	}
+	return fmt.Sprintf("%s is %s; expected %s", subject, quoteValue(actual), expect)
+}
+
+// observe 返回条件检查的对象名称、实际值及其是否存在
+func (n *compiledNode) observe(in *evalInput) (subject, actual string, present bool) {
+	req, res, c := in.req, in.res, n.cond
+	switch c.Type {
+	case rulespec.ConditionURLEquals, rulespec.ConditionURLPrefix, rulespec.ConditionURLSuffix,
+		rulespec.ConditionURLContains, rulespec.ConditionURLRegex, rulespec.ConditionURLPattern:
+		return "url", req.URL, true
+	case rulespec.ConditionURLNoFragment:
+		return "url without fragment", stripFragment(req.URL), true
+	case rulespec.ConditionURLHost, rulespec.ConditionURLPath, rulespec.ConditionURLScheme, rulespec.ConditionURLPort:
+		part := strings.TrimPrefix(string(c.Type), "url")
+		subject = "url " + strings.ToLower(part)
+		u := in.url()
+		if u == nil {
+			return subject, "", false
+		}
+		switch c.Type {
+		case rulespec.ConditionURLHost:
+			return subject, u.host, true
+		case rulespec.ConditionURLPath:
+			return subject, u.path, true
+		case rulespec.ConditionURLScheme:
+			return subject, u.scheme, true
+		default:
+			return subject, u.port, u.port != ""
+		}
+
+	case rulespec.ConditionTargetID:
+		if req.Page == nil {
+			return "target id", "", false
+		}
+		return "target id", string(req.Page.TargetID), true
+	case rulespec.ConditionPageURLContains, rulespec.ConditionPageURLPattern, rulespec.ConditionPageURLRegex:
+		if req.Page == nil {
+			return "page url", "", false
+		}
+		return "page url", req.Page.PageURL, true
+	case rulespec.ConditionPageTitleContains, rulespec.ConditionPageTitleRegex:
+		if req.Page == nil {
+			return "page title", "", false
+		}
+		return "page title", req.Page.PageTitle, true
+	case rulespec.ConditionFrameURLContains, rulespec.ConditionFrameURLRegex:
+		if req.Page == nil {
+			return "frame url", "", false
+		}
+		return "frame url", req.Page.FrameURL, true
+
+	case rulespec.ConditionMethod:
+		return "method", req.Method, true
+	case rulespec.ConditionResourceType:
+		return "resource type", string(req.ResourceType), req.ResourceType != ""
+
+	case rulespec.ConditionHeaderExists, rulespec.ConditionHeaderNotExists, rulespec.ConditionHeaderEquals,
+		rulespec.ConditionHeaderContains, rulespec.ConditionHeaderRegex:
+		values := req.Headers.Values(c.Name)
+		return fmt.Sprintf("header %q", c.Name), strings.Join(values, ", "), len(values) > 0
+	case rulespec.ConditionQueryExists, rulespec.ConditionQueryNotExists, rulespec.ConditionQueryEquals,
+		rulespec.ConditionQueryContains, rulespec.ConditionQueryRegex:
+		values := req.Query.Values(c.Name)
+		return fmt.Sprintf("query %q", c.Name), strings.Join(values, ", "), len(values) > 0
+	case rulespec.ConditionCookieExists, rulespec.ConditionCookieNotExists, rulespec.ConditionCookieEquals,
+		rulespec.ConditionCookieContains, rulespec.ConditionCookieRegex:
+		v, ok := req.Cookies[c.Name]
+		return fmt.Sprintf("cookie %q", c.Name), v, ok
+	case rulespec.ConditionBodyContains, rulespec.ConditionBodyRegex:
+		return "body", string(req.Body), len(req.Body) > 0
+	case rulespec.ConditionBodyJsonPath:
+		return n.observeJsonPath("body", req.Body)
+	case rulespec.ConditionFormFieldExists, rulespec.ConditionFormFieldEquals, rulespec.ConditionFormFieldRegex:
+		subject = fmt.Sprintf("form field %q", c.Name)
+		f := in.form()
+		if f == nil {
+			return subject, "", false
+		}
+		values := f.Values(c.Name)
+		return subject, strings.Join(values, ", "), len(values) > 0
+	case rulespec.ConditionGraphQLOperationName, rulespec.ConditionGraphQLOperationType, rulespec.ConditionGraphQLVariable:
+		return n.observeGraphQL(in)
+	case rulespec.ConditionScenarioState:
+		subject = fmt.Sprintf("scenario %q state", c.Name)
+		if in.state == nil {
+			return subject, "", false
+		}
+		return subject, in.state.scenario(c.Name), true
+	case rulespec.ConditionVariableExists, rulespec.ConditionVariableEquals:
+		subject = fmt.Sprintf("variable %q", c.Name)
+		if in.state == nil {
+			return subject, "", false
+		}
+		v, ok := in.state.variable(c.Name)
+		return subject, v, ok
+
+	case rulespec.ConditionStatusCode, rulespec.ConditionStatusCodeRange:
+		return "status code", strconv.Itoa(res.StatusCode), true
+	case rulespec.ConditionResponseHeaderExists, rulespec.ConditionResponseHeaderNotExists, rulespec.ConditionResponseHeaderEquals,
+		rulespec.ConditionResponseHeaderContains, rulespec.ConditionResponseHeaderRegex:
+		values := res.Headers.Values(c.Name)
+		return fmt.Sprintf("response header %q", c.Name), strings.Join(values, ", "), len(values) > 0
+	case rulespec.ConditionResponseBodyContains, rulespec.ConditionResponseBodyRegex:
+		return "response body", string(res.Body), len(res.Body) > 0
+	case rulespec.ConditionResponseBodyJsonPath:
+		return n.observeJsonPath("response body", res.Body)
+	case rulespec.ConditionMimeType:
+		mime := mimeTypeOf(res.Headers)
+		return "mime type", mime, mime != ""
+	}
+	return "condition", "", false
+}
+
+// observeJsonPath 返回 JSON Path 取到的原始值
+func (n *compiledNode) observeJsonPath(source string, body []byte) (string, string, bool) {
+	subject := fmt.Sprintf("%s json path %q", source, n.path)
+	if len(body) == 0 || n.path == "" {
+		return subject, "", false
+	}
+	result := gjson.GetBytes(body, n.path)
+	return subject, result.Raw, result.Exists()
+}
+
+// observeGraphQL 返回 GraphQL 各操作的操作名、操作类型或变量取值，批量请求以逗号分隔
+func (n *compiledNode) observeGraphQL(in *evalInput) (string, string, bool) {
+	var subject string
+	switch n.cond.Type {
+	case rulespec.ConditionGraphQLOperationName:
+		subject = "graphql operation name"
+	case rulespec.ConditionGraphQLOperationType:
+		subject = "graphql operation type"
+	default:
+		subject = fmt.Sprintf("graphql variables json path %q", n.path)
+	}
+	gql := in.graphql()
+	if gql == nil {
+		return subject, "", false
+	}
+	var values []string
+	for _, op := range gql.Operations {
+		switch n.cond.Type {
+		case rulespec.ConditionGraphQLOperationName:
+			values = append(values, op.Name)
+		case rulespec.ConditionGraphQLOperationType:
+			values = append(values, op.Type)
+		default:
+			if len(op.Variables) > 0 && n.path != "" {
+				if r := gjson.GetBytes(op.Variables, n.path); r.Exists() {
+					values = append(values, r.Raw)
+				}
+			}
+		}
+	}
+	return subject, strings.Join(values, ", "), len(values) > 0
+}
+
+// expect 描述条件期望满足的关系
+func (n *compiledNode) expect() string {
+	c := n.cond
+	switch c.Type {
+	case rulespec.ConditionURLEquals, rulespec.ConditionURLNoFragment,
+		rulespec.ConditionHeaderEquals, rulespec.ConditionQueryEquals, rulespec.ConditionCookieEquals,
+		rulespec.ConditionResponseHeaderEquals, rulespec.ConditionFormFieldEquals, rulespec.ConditionVariableEquals:
+		return "equals " + strconv.Quote(c.Value)
+	case rulespec.ConditionURLPrefix:
+		return "starts with " + strconv.Quote(c.Value)
+	case rulespec.ConditionURLSuffix:
+		return "ends with " + strconv.Quote(c.Value)
+	case rulespec.ConditionURLContains, rulespec.ConditionPageURLContains, rulespec.ConditionPageTitleContains,
+		rulespec.ConditionFrameURLContains, rulespec.ConditionHeaderContains, rulespec.ConditionQueryContains,
+		rulespec.ConditionCookieContains, rulespec.ConditionBodyContains,
+		rulespec.ConditionResponseHeaderContains, rulespec.ConditionResponseBodyContains:
+		return "contains " + strconv.Quote(c.Value)
+	case rulespec.ConditionURLRegex, rulespec.ConditionPageURLRegex, rulespec.ConditionPageTitleRegex,
+		rulespec.ConditionFrameURLRegex, rulespec.ConditionHeaderRegex, rulespec.ConditionQueryRegex,
+		rulespec.ConditionCookieRegex, rulespec.ConditionBodyRegex, rulespec.ConditionFormFieldRegex,
+		rulespec.ConditionResponseHeaderRegex, rulespec.ConditionResponseBodyRegex:
+		if n.re == nil {
+			return fmt.Sprintf("matches /%s/ (invalid regex)", c.Pattern)
+		}
+		return fmt.Sprintf("matches /%s/", c.Pattern)
+	case rulespec.ConditionURLPath:
+		return "matches glob " + strconv.Quote(c.Value)
+	case rulespec.ConditionURLPattern, rulespec.ConditionPageURLPattern:
+		if n.urlPat == nil {
+			return fmt.Sprintf("matches pattern %q (invalid pattern)", c.Value)
+		}
+		return "matches pattern " + strconv.Quote(c.Value)
+	case rulespec.ConditionHeaderExists, rulespec.ConditionQueryExists, rulespec.ConditionCookieExists,
+		rulespec.ConditionFormFieldExists, rulespec.ConditionResponseHeaderExists, rulespec.ConditionVariableExists:
+		return "exists"
+	case rulespec.ConditionHeaderNotExists, rulespec.ConditionQueryNotExists, rulespec.ConditionCookieNotExists,
+		rulespec.ConditionResponseHeaderNotExists:
+		return "missing"
+	case rulespec.ConditionURLHost, rulespec.ConditionURLScheme, rulespec.ConditionURLPort, rulespec.ConditionTargetID,
+		rulespec.ConditionMethod, rulespec.ConditionResourceType, rulespec.ConditionStatusCode, rulespec.ConditionMimeType,
+		rulespec.ConditionGraphQLOperationName, rulespec.ConditionGraphQLOperationType, rulespec.ConditionScenarioState:
+		return "one of [" + strings.Join(c.Values, ", ") + "]"
+	case rulespec.ConditionStatusCodeRange:
+		if !n.rangeOK {
+			return fmt.Sprintf("in range %q (invalid range)", c.Value)
+		}
+		return fmt.Sprintf("in range %d-%d", n.lo, n.hi)
+	case rulespec.ConditionBodyJsonPath, rulespec.ConditionResponseBodyJsonPath, rulespec.ConditionGraphQLVariable:
+		op := c.Operator
+		if op == "" {
+			op = rulespec.OperatorEq
+		}
+		switch op {
+		case rulespec.OperatorExists, rulespec.OperatorNotExists:
+			return string(op)
+		case rulespec.OperatorIn:
+			return "in [" + strings.Join(c.Values, ", ") + "]"
+		default:
+			return string(op) + " " + strconv.Quote(c.Value)
+		}
+	}
+	return "unknown condition type " + strconv.Quote(string(c.Type))
+}
+
+// quoteValue 截断过长的实际值并加引号
+func quoteValue(v string) string {
+	if len(v) > maxReasonValueLen {
+		return strconv.Quote(v[:maxReasonValueLen]) + "..."
+	}
+	return strconv.Quote(v)
+}
@@ -0,0 +1,390 @@
+package engine
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"cdpnetool/internal/regexutil"
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/rulespec"
+)
+
+// matcher 由规则配置编译得到的不可变匹配器，Update 时整体原子替换
+type matcher struct {
+	stages map[rulespec.Stage]*stageIndex
+	rules  []*compiledRule // 全部规则（含禁用），按优先级从大到小排列，供 Explain 使用
+}
+
+// stageIndex 单个生命周期阶段的规则索引
+type stageIndex struct {
+	byHost  map[string][]*compiledRule // 按主机名索引、只可能匹配该主机的规则
+	generic []*compiledRule            // 无法按主机索引、需要逐条评估的规则
+}
+
+// compiledRule 预编译后的规则
+type compiledRule struct {
+	rule      *rulespec.Rule
+	rank      int // 全局排序位置，数值越小优先级越高
+	groupMode rulespec.GroupMode
+	match     compiledGroup
+	bodyCond  bool                       // 条件中包含响应体条件
+	bodyUse   bool                       // 行为需要读取响应体
+	templates *transformer.TemplateCache // 行为模板的解析缓存，规则没有启用模板的行为时为 nil
+}
+
+// compiledGroup 预编译后的条件组
+type compiledGroup struct {
+	allOf  []*compiledNode
+	anyOf  []*compiledNode
+	noneOf []*compiledNode
+}
+
+// compiledNode 预编译后的条件节点（普通条件或嵌套条件组）
+type compiledNode struct {
+	cond    *rulespec.Condition
+	group   *compiledGroup // 非空表示嵌套条件组
+	re      *regexp.Regexp // 已解析的正则，编译失败时为 nil（恒不匹配）
+	path    string         // 已规范化的 gjson 路径
+	lo, hi  int            // 已解析的状态码范围
+	rangeOK bool           // 状态码范围是否合法
+	urlPat  *urlPattern    // 已解析的 URL 匹配模式，非法时为 nil
+	num     float64        // 已解析的数值比较操作数
+	numOK   bool           // 数值操作数是否合法
+	body    bool           // 条件（或嵌套组中的条件）读取响应体
+}
+
+// compile 将规则配置编译为匹配器，非法正则在此阶段解析并视为恒不匹配
+func compile(config *rulespec.Config, cache *regexutil.Cache) *matcher {
+	m := &matcher{stages: make(map[rulespec.Stage]*stageIndex)}
+	if config == nil {
+		return m
+	}
+
+	// 按优先级从大到小稳定排序，相同优先级保持配置顺序
+	rules := make([]*rulespec.Rule, len(config.Rules))
+	for i := range config.Rules {
+		rules[i] = &config.Rules[i]
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+
+	groupModes := config.GroupModes()
+	for rank, rule := range rules {
+		cr := &compiledRule{
+			rule:  rule,
+			rank:  rank,
+			match: compileGroup(rule.Match.AllOf, rule.Match.AnyOf, rule.Match.NoneOf, cache),
+		}
+		cr.bodyCond, cr.bodyUse = cr.match.readsBody(), actionsReadBody(rule.Actions)
+		if actionsUseTemplate(rule.Actions) {
+			cr.templates = transformer.NewTemplateCache()
+		}
+		if rule.Group != "" {
+			cr.groupMode = groupModes[rule.Group]
+		}
+		m.rules = append(m.rules, cr)
+		if !rule.Enabled {
+			continue
+		}
+
+		idx := m.stages[rule.Stage]
+		if idx == nil {
+			idx = &stageIndex{byHost: make(map[string][]*compiledRule)}
+			m.stages[rule.Stage] = idx
+		}
+		hosts := indexHosts(&rule.Match)
+		if len(hosts) == 0 {
+			idx.generic = append(idx.generic, cr)
+			continue
+		}
+		for _, h := range hosts {
+			idx.byHost[h] = append(idx.byHost[h], cr)
+		}
+	}
+	return m
+}
+
+// actionsReadBody 判断行为是否需要读取响应体，限速需要返回完整响应体、模板可能引用响应体，均视为需要
+func actionsReadBody(actions []rulespec.Action) bool {
+	for i := range actions {
+		a := &actions[i]
+		if a.ReadsBody() || a.Type == rulespec.ActionThrottle || a.Template {
+			return true
+		}
+	}
+	return false
+}
+
+// actionsUseTemplate 判断行为中是否有启用模板的行为
+func actionsUseTemplate(actions []rulespec.Action) bool {
+	for i := range actions {
+		if actions[i].Template {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGroup 编译条件组
+func compileGroup(allOf, anyOf, noneOf []rulespec.Condition, cache *regexutil.Cache) compiledGroup {
+	return compiledGroup{
+		allOf:  compileNodes(allOf, cache),
+		anyOf:  compileNodes(anyOf, cache),
+		noneOf: compileNodes(noneOf, cache),
+	}
+}
+
+// readsBody 判断条件组中是否有读取响应体的条件
+func (g *compiledGroup) readsBody() bool {
+	for _, nodes := range [][]*compiledNode{g.allOf, g.anyOf, g.noneOf} {
+		for _, n := range nodes {
+			if n.body {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileNodes 编译条件列表
+func compileNodes(conds []rulespec.Condition, cache *regexutil.Cache) []*compiledNode {
+	if len(conds) == 0 {
+		return nil
+	}
+	nodes := make([]*compiledNode, len(conds))
+	for i := range conds {
+		nodes[i] = compileNode(&conds[i], cache)
+	}
+	return nodes
+}
+
+// compileNode 编译单个条件节点
+func compileNode(c *rulespec.Condition, cache *regexutil.Cache) *compiledNode {
+	n := &compiledNode{cond: c, body: c.Type.IsResponseBody()}
+	if c.IsGroup() {
+		g := compileGroup(c.AllOf, c.AnyOf, c.NoneOf, cache)
+		n.group = &g
+		n.body = g.readsBody()
+		return n
+	}
+	pattern := c.Pattern
+	switch c.Type {
+	case rulespec.ConditionURLPath:
+		pattern = pathGlobToRegex(c.Value)
+	case rulespec.ConditionURLPattern, rulespec.ConditionPageURLPattern:
+		n.urlPat, _ = compileURLPattern(c.Value)
+	case rulespec.ConditionStatusCodeRange:
+		n.lo, n.hi, n.rangeOK = parseStatusRange(c.Value)
+	case rulespec.ConditionBodyJsonPath, rulespec.ConditionResponseBodyJsonPath, rulespec.ConditionGraphQLVariable:
+		switch c.Operator {
+		case rulespec.OperatorRegex:
+			pattern = c.Value
+		case rulespec.OperatorGt, rulespec.OperatorGte, rulespec.OperatorLt, rulespec.OperatorLte:
+			n.num, n.numOK = parseNumber(c.Value)
+		}
+	}
+	if pattern != "" {
+		if re, err := cache.Get(pattern); err == nil {
+			n.re = re
+		}
+	}
+	n.path = normalizeJsonPath(c.Path)
+	return n
+}
+
+// stage 获取指定阶段的索引
+func (m *matcher) stage(stage rulespec.Stage) *stageIndex {
+	if m == nil {
+		return nil
+	}
+	return m.stages[stage]
+}
+
+// candidates 返回可能匹配该 URL 的规则，按优先级从大到小排列
+func (idx *stageIndex) candidates(rawURL string) []*compiledRule {
+	hosted := idx.byHost[urlHost(rawURL)]
+	if len(hosted) == 0 {
+		return idx.generic
+	}
+	if len(idx.generic) == 0 {
+		return hosted
+	}
+	// 归并两个已按 rank 排序的列表
+	out := make([]*compiledRule, 0, len(hosted)+len(idx.generic))
+	i, j := 0, 0
+	for i < len(hosted) && j < len(idx.generic) {
+		if hosted[i].rank < idx.generic[j].rank {
+			out = append(out, hosted[i])
+			i++
+		} else {
+			out = append(out, idx.generic[j])
+			j++
+		}
+	}
+	out = append(out, hosted[i:]...)
+	return append(out, idx.generic[j:]...)
+}
+
+// indexHosts 推导规则必然要求的主机名集合，无法推导时返回 nil
+// 仅依据顶层 allOf 中的 URL 条件，或全部由 URL 条件组成的顶层 anyOf
+func indexHosts(m *rulespec.Match) []string {
+	for i := range m.AllOf {
+		if hosts := conditionHosts(&m.AllOf[i]); len(hosts) > 0 {
+			return hosts
+		}
+	}
+	if len(m.AnyOf) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(m.AnyOf))
+	var hosts []string
+	for i := range m.AnyOf {
+		hs := conditionHosts(&m.AnyOf[i])
+		if len(hs) == 0 {
+			return nil
+		}
+		for _, h := range hs {
+			if !seen[h] {
+				seen[h] = true
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts
+}
+
+// conditionHosts 从 URL 条件中提取可能匹配的完整主机名，无法确定时返回 nil
+func conditionHosts(c *rulespec.Condition) []string {
+	if c.Negate || c.IsGroup() {
+		return nil
+	}
+	switch c.Type {
+	case rulespec.ConditionURLEquals, rulespec.ConditionURLNoFragment:
+		if h := urlHost(c.Value); h != "" {
+			return []string{h}
+		}
+	case rulespec.ConditionURLPrefix:
+		// 前缀必须覆盖整个主机部分，否则 "https://a.com" 也可能匹配 "https://a.com.cn"
+		rest, ok := afterScheme(c.Value)
+		if ok && strings.ContainsAny(rest, "/?#") {
+			if h := urlHost(c.Value); h != "" {
+				return []string{h}
+			}
+		}
+	case rulespec.ConditionURLHost:
+		hosts := make([]string, 0, len(c.Values))
+		for _, v := range c.Values {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if !isExactHost(v) {
+				return nil
+			}
+			hosts = append(hosts, v)
+		}
+		return hosts
+	case rulespec.ConditionURLPattern:
+		if p, ok := compileURLPattern(c.Value); ok && !p.all && isExactHost(p.host) {
+			return []string{p.host}
+		}
+	}
+	return nil
+}
+
+// urlHost 快速提取 URL 中的小写主机名（不含端口和用户信息）
+func urlHost(rawURL string) string {
+	rest, ok := afterScheme(rawURL)
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexAny(rest, "/?#"); i != -1 {
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, "@"); i != -1 {
+		rest = rest[i+1:]
+	}
+	if strings.HasPrefix(rest, "[") {
+		// IPv6 字面量
+		if i := strings.Index(rest, "]"); i != -1 {
+			return strings.ToLower(rest[:i+1])
+		}
+		return ""
+	}
+	if i := strings.LastIndex(rest, ":"); i != -1 {
+		rest = rest[:i]
+	}
+	return strings.ToLower(rest)
+}
+
+// afterScheme 返回 "scheme://" 之后的部分
+func afterScheme(rawURL string) (string, bool) {
+	i := strings.Index(rawURL, "://")
+	if i <= 0 {
+		return "", false
+	}
+	return rawURL[i+3:], true
+}
+
+// match 评估条件组：allOf 全部满足、anyOf 至少满足一个、noneOf 全部不满足
+func (g *compiledGroup) match(in *evalInput) bool {
+	// allOf: 必须全部满足
+	for _, n := range g.allOf {
+		if !n.eval(in) {
+			return false
+		}
+	}
+	// anyOf: 满足任一即可
+	if len(g.anyOf) > 0 {
+		anyMatch := false
+		for _, n := range g.anyOf {
+			if n.eval(in) {
+				anyMatch = true
+				break
+			}
+		}
+		if !anyMatch {
+			return false
+		}
+	}
+	// noneOf: 任一满足即不匹配
+	for _, n := range g.noneOf {
+		if n.eval(in) {
+			return false
+		}
+	}
+	return true
+}
+
+// mayMatch 在尚未读取响应体时判断条件组是否可能匹配
+// allOf 中不涉及响应体的条件必须满足，其余条件保守地视为可能满足
+func (g *compiledGroup) mayMatch(in *evalInput) bool {
+	for _, n := range g.allOf {
+		if !n.body {
+			if !n.eval(in) {
+				return false
+			}
+			continue
+		}
+		if n.group != nil && !n.cond.Negate && !n.group.mayMatch(in) {
+			return false
+		}
+	}
+	return true
+}
+
+// eval 评估条件节点（普通条件或嵌套条件组），并处理取反
+func (n *compiledNode) eval(in *evalInput) bool {
+	var ok bool
+	if n.group != nil {
+		ok = n.group.match(in)
+	} else if n.cond.Type.IsResponseOnly() {
+		// 响应条件在没有响应数据时恒不匹配（取反也不生效）
+		if in.res == nil {
+			return false
+		}
+		ok = n.evalResponse(in.res)
+	} else {
+		ok = n.evalRequest(in)
+	}
+	return ok != n.cond.Negate
+}
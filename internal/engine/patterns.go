@@ -0,0 +1,373 @@
+package engine
+
+import (
+	"regexp/syntax"
+	"strings"
+
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+)
+
+// wildcardAll 匹配任意 URL 的拦截模式
+const wildcardAll = "*"
+
+// exactResourceTypes 与 CDP 资源类型一一对应、不会由 URL 扩展名推断得到的资源类型，可用于收窄拦截模式
+var exactResourceTypes = map[domain.ResourceType]bool{
+	domain.ResourceTypeDocument:  true,
+	domain.ResourceTypeXHR:       true,
+	domain.ResourceTypeFetch:     true,
+	domain.ResourceTypeWebSocket: true,
+}
+
+// InterceptPatterns 由启用的规则推导物理拦截所需的模式，请求阶段在前
+// 所有规则都在请求阶段拦截（响应阶段依赖请求阶段入池的上下文）；请求阶段规则还需在响应阶段记录匹配事件，
+// 包含 block 或 fail 的规则命中后不会进入响应阶段，不在响应阶段拦截；修改 URL 的请求阶段规则使响应阶段回退为 "*"
+// 无法用通配符表达的条件回退为 "*"，没有启用的规则时返回 nil
+func (e *Engine) InterceptPatterns() []domain.InterceptPattern {
+	var reqSet, resSet patternSet
+	for _, cr := range e.matcher.Load().rules {
+		r := cr.rule
+		if !r.Enabled {
+			continue
+		}
+		pats := matchPatterns(&r.Match)
+		reqSet.add(pats)
+		if r.Stage == rulespec.StageRequest {
+			if rewritesURL(r.Actions) {
+				resSet.add([]domain.InterceptPattern{{URLPattern: wildcardAll}})
+				continue
+			}
+			if endsRequest(r.Actions) {
+				continue
+			}
+		}
+		resSet.add(pats)
+	}
+	if len(reqSet.items) == 0 {
+		return nil
+	}
+	return append(reqSet.list(false), resSet.list(true)...)
+}
+
+// patternSet 去重并保持加入顺序的拦截模式集合
+type patternSet struct {
+	seen  map[domain.InterceptPattern]bool
+	items []domain.InterceptPattern
+}
+
+// add 加入模式
+func (s *patternSet) add(pats []domain.InterceptPattern) {
+	if s.seen == nil {
+		s.seen = make(map[domain.InterceptPattern]bool)
+	}
+	for _, p := range pats {
+		if !s.seen[p] {
+			s.seen[p] = true
+			s.items = append(s.items, p)
+		}
+	}
+}
+
+// list 返回指定阶段的模式，包含不限资源类型的 "*" 时只保留该模式
+func (s *patternSet) list(response bool) []domain.InterceptPattern {
+	if s.seen[domain.InterceptPattern{URLPattern: wildcardAll}] {
+		return []domain.InterceptPattern{{URLPattern: wildcardAll, Response: response}}
+	}
+	out := make([]domain.InterceptPattern, len(s.items))
+	for i, p := range s.items {
+		p.Response = response
+		out[i] = p
+	}
+	return out
+}
+
+// matchPatterns 推导规则条件可能匹配的拦截模式（URL 模式与资源类型的组合）
+func matchPatterns(m *rulespec.Match) []domain.InterceptPattern {
+	urls := groupURLPatterns(m.AllOf, m.AnyOf)
+	if urls == nil {
+		urls = []string{wildcardAll}
+	}
+	types := matchResourceTypes(m.AllOf)
+	out := make([]domain.InterceptPattern, 0, len(urls)*max(len(types), 1))
+	for _, u := range urls {
+		if len(types) == 0 {
+			out = append(out, domain.InterceptPattern{URLPattern: u})
+			continue
+		}
+		for _, t := range types {
+			out = append(out, domain.InterceptPattern{URLPattern: u, ResourceType: t})
+		}
+	}
+	return out
+}
+
+// matchResourceTypes 从顶层 allOf 的资源类型条件推导可收窄的资源类型，无法收窄时返回 nil
+func matchResourceTypes(allOf []rulespec.Condition) []domain.ResourceType {
+	for i := range allOf {
+		c := &allOf[i]
+		if c.Negate || c.IsGroup() || c.Type != rulespec.ConditionResourceType || len(c.Values) == 0 {
+			continue
+		}
+		types := make([]domain.ResourceType, 0, len(c.Values))
+		for _, v := range c.Values {
+			t := domain.ResourceType(strings.ToLower(strings.TrimSpace(v)))
+			if !exactResourceTypes[t] {
+				types = nil
+				break
+			}
+			types = append(types, t)
+		}
+		if types != nil {
+			return types
+		}
+	}
+	return nil
+}
+
+// groupURLPatterns 推导条件组可能匹配的 URL 模式，无法收窄时返回 nil
+// 取 allOf 中第一个可表达的 URL 条件，或全部可表达的 anyOf 的并集
+func groupURLPatterns(allOf, anyOf []rulespec.Condition) []string {
+	for i := range allOf {
+		if pats := conditionURLPatterns(&allOf[i]); pats != nil {
+			return pats
+		}
+	}
+	if len(anyOf) == 0 {
+		return nil
+	}
+	var out []string
+	for i := range anyOf {
+		pats := conditionURLPatterns(&anyOf[i])
+		if pats == nil {
+			return nil
+		}
+		out = append(out, pats...)
+	}
+	return out
+}
+
+// conditionURLPatterns 将 URL 条件转换为覆盖其全部匹配结果的通配符模式，无法表达时返回 nil
+func conditionURLPatterns(c *rulespec.Condition) []string {
+	if c.Negate {
+		return nil
+	}
+	if c.IsGroup() {
+		return groupURLPatterns(c.AllOf, c.AnyOf)
+	}
+	switch c.Type {
+	case rulespec.ConditionURLEquals:
+		return []string{escapeWildcard(c.Value)}
+	case rulespec.ConditionURLNoFragment:
+		return []string{escapeWildcard(stripFragment(c.Value))}
+	case rulespec.ConditionURLPrefix:
+		return []string{joinWildcard(escapeWildcard(c.Value), wildcardAll)}
+	case rulespec.ConditionURLSuffix:
+		return []string{joinWildcard(wildcardAll, escapeWildcard(c.Value))}
+	case rulespec.ConditionURLContains:
+		return []string{joinWildcard(wildcardAll, escapeWildcard(c.Value), wildcardAll)}
+	case rulespec.ConditionURLRegex:
+		return regexURLPatterns(c.Pattern)
+	case rulespec.ConditionURLHost:
+		return hostURLPatterns(c.Values)
+	case rulespec.ConditionURLPath:
+		return pathURLPatterns(c.Value)
+	case rulespec.ConditionURLScheme:
+		var out []string
+		for _, v := range c.Values {
+			if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+				out = append(out, escapeWildcard(v)+"://*")
+			}
+		}
+		return out
+	case rulespec.ConditionURLPort:
+		return portURLPatterns(c.Values)
+	case rulespec.ConditionURLPattern:
+		return chromeURLPatterns(c.Value)
+	}
+	return nil
+}
+
+// regexURLPatterns 按正则开头的字面量推导模式，以 "^" 开头时字面量位于 URL 开头
+func regexURLPatterns(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	anchored := false
+	var prefix strings.Builder
+	for i, sub := range subs {
+		if i == 0 && sub.Op == syntax.OpBeginText {
+			anchored = true
+			continue
+		}
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix.WriteString(string(sub.Rune))
+	}
+	if prefix.Len() == 0 {
+		return nil
+	}
+	if anchored {
+		return []string{escapeWildcard(prefix.String()) + "*"}
+	}
+	return []string{"*" + escapeWildcard(prefix.String()) + "*"}
+}
+
+// hostURLPatterns 将主机名条件转换为模式，同时覆盖带端口与不带端口的 URL
+func hostURLPatterns(values []string) []string {
+	var out []string
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if v == "*" {
+			return nil
+		}
+		for _, h := range hostWildcards(v) {
+			out = append(out, "*://"+h+"/*", "*://"+h+":*")
+		}
+	}
+	return out
+}
+
+// hostWildcards 将主机模式转换为通配符，"*.example.com" 同时覆盖根域名
+func hostWildcards(host string) []string {
+	if host == "*" {
+		return []string{wildcardAll}
+	}
+	if base, ok := strings.CutPrefix(host, "*."); ok {
+		return []string{"*." + escapeWildcard(base), escapeWildcard(base)}
+	}
+	return []string{escapeWildcard(host)}
+}
+
+// pathURLPatterns 将路径 glob 转换为模式，"*" 与 "**" 均转换为 "*"，同时覆盖带查询字符串的 URL
+func pathURLPatterns(glob string) []string {
+	p := joinWildcard(wildcardAll, globWildcard(glob, "*?"))
+	if strings.HasSuffix(p, "*") {
+		return []string{p}
+	}
+	return []string{p, p + `\?*`}
+}
+
+// portURLPatterns 将端口条件转换为模式，协议默认端口同时覆盖未显式指定端口的 URL
+func portURLPatterns(values []string) []string {
+	var out []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		out = append(out, "*://*:"+escapeWildcard(v)+"/*")
+		switch v {
+		case "80":
+			out = append(out, "http://*")
+		case "443":
+			out = append(out, "https://*")
+		}
+	}
+	return out
+}
+
+// chromeURLPatterns 将 Chrome 风格的 URL 匹配模式转换为通配符模式
+func chromeURLPatterns(pattern string) []string {
+	p, ok := compileURLPattern(pattern)
+	if !ok || p.all {
+		return nil
+	}
+	_, rest, _ := strings.Cut(strings.TrimSpace(pattern), "://")
+	path := "/*"
+	if i := strings.Index(rest, "/"); i != -1 {
+		path = rest[i:]
+	}
+	path = joinWildcard(globWildcard(path, "*"))
+
+	var out []string
+	for _, scheme := range p.schemes {
+		for _, h := range hostWildcards(p.host) {
+			prefix := escapeWildcard(scheme) + "://" + h
+			switch {
+			case h == wildcardAll:
+				out = append(out, prefix+path)
+			case p.port == "" || p.port == "*":
+				out = append(out, prefix+path, prefix+":*"+path)
+			default:
+				out = append(out, prefix+":"+escapeWildcard(p.port)+path)
+				if defaultPorts[scheme] == p.port {
+					out = append(out, prefix+path)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// escapeWildcard 转义模式中的通配符与转义字符
+func escapeWildcard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`).Replace(s)
+}
+
+// joinWildcard 拼接模式片段并合并相邻的未转义 "*"
+func joinWildcard(parts ...string) string {
+	s := strings.Join(parts, "")
+	var b strings.Builder
+	escaped, star := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped, star = false, false
+		case r == '\\':
+			escaped, star = true, false
+		case r == '*':
+			if star {
+				continue
+			}
+			star = true
+		default:
+			star = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// globWildcard 将 glob 转换为通配符模式，wild 中的字符保留为通配符，其余字符转义
+func globWildcard(glob, wild string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		if strings.ContainsRune(wild, r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(escapeWildcard(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// rewritesURL 判断行为是否可能修改请求 URL，修改后的请求可能不再命中由原 URL 推导的模式
+func rewritesURL(actions []rulespec.Action) bool {
+	for i := range actions {
+		switch actions[i].Type {
+		case rulespec.ActionSetUrl, rulespec.ActionMapRemote, rulespec.ActionSetQueryParam, rulespec.ActionRemoveQueryParam:
+			return true
+		}
+	}
+	return false
+}
+
+// endsRequest 判断规则命中后是否必然以 block 或 fail 结束请求，不再进入响应阶段
+func endsRequest(actions []rulespec.Action) bool {
+	for i := range actions {
+		a := &actions[i]
+		if (a.Type == rulespec.ActionBlock && !a.Template) || a.Type == rulespec.ActionFail {
+			return true
+		}
+	}
+	return false
+}
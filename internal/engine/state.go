@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"sync"
+
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+)
+
+// stateStore 会话内共享的变量与场景状态，规则配置更新时保留
+type stateStore struct {
+	mu        sync.RWMutex
+	variables map[string]string
+	scenarios map[string]string
+}
+
+// newStateStore 创建空的状态存储
+func newStateStore() *stateStore {
+	return &stateStore{
+		variables: make(map[string]string),
+		scenarios: make(map[string]string),
+	}
+}
+
+// variable 读取会话变量
+func (s *stateStore) variable(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.variables[name]
+	return v, ok
+}
+
+// scenario 读取场景的当前状态，从未切换过的场景返回 rulespec.ScenarioStarted
+func (s *stateStore) scenario(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if st, ok := s.scenarios[name]; ok {
+		return st
+	}
+	return rulespec.ScenarioStarted
+}
+
+// Variable 读取会话变量
+func (e *Engine) Variable(name string) (string, bool) {
+	return e.state.variable(name)
+}
+
+// SetVariable 设置会话变量
+func (e *Engine) SetVariable(name, value string) {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.variables[name] = value
+}
+
+// ScenarioState 读取场景的当前状态，从未切换过的场景返回 rulespec.ScenarioStarted
+func (e *Engine) ScenarioState(name string) string {
+	return e.state.scenario(name)
+}
+
+// SetScenarioState 切换场景状态
+func (e *Engine) SetScenarioState(name, state string) {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.scenarios[name] = state
+}
+
+// GetState 获取会话状态快照
+func (e *Engine) GetState() domain.SessionState {
+	e.state.mu.RLock()
+	defer e.state.mu.RUnlock()
+	st := domain.SessionState{
+		Variables: make(map[string]string, len(e.state.variables)),
+		Scenarios: make(map[string]string, len(e.state.scenarios)),
+	}
+	for k, v := range e.state.variables {
+		st.Variables[k] = v
+	}
+	for k, v := range e.state.scenarios {
+		st.Scenarios[k] = v
+	}
+	return st
+}
+
+// ResetState 清空会话变量，并将全部场景恢复为初始状态
+func (e *Engine) ResetState() {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.variables = make(map[string]string)
+	e.state.scenarios = make(map[string]string)
+}
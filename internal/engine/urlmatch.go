@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultPorts 常见协议的默认端口
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// parsedURL 请求 URL 的结构化组成部分
+type parsedURL struct {
+	scheme string // 小写协议
+	host   string // 小写主机名，不含端口
+	port   string // 端口，未显式指定时使用协议默认端口
+	path   string // 解码前的路径，至少为 "/"
+	query  string // 原始查询字符串
+}
+
+// parseURL 解析 URL，失败时返回 nil
+func parseURL(raw string) *parsedURL {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return nil
+	}
+	p := &parsedURL{
+		scheme: strings.ToLower(u.Scheme),
+		host:   strings.ToLower(u.Hostname()),
+		port:   u.Port(),
+		path:   u.EscapedPath(),
+		query:  u.RawQuery,
+	}
+	if p.port == "" {
+		p.port = defaultPorts[p.scheme]
+	}
+	if p.path == "" {
+		p.path = "/"
+	}
+	return p
+}
+
+// stripFragment 去除 URL 中的片段部分
+func stripFragment(raw string) string {
+	if i := strings.Index(raw, "#"); i != -1 {
+		return raw[:i]
+	}
+	return raw
+}
+
+// matchHostPattern 匹配主机名，支持 "*" 与 "*.example.com"（同时匹配根域名与所有子域名）
+func matchHostPattern(host, pattern string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	switch {
+	case pattern == "":
+		return false
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		base := pattern[2:]
+		return host == base || strings.HasSuffix(host, "."+base)
+	default:
+		return host == pattern
+	}
+}
+
+// isExactHost 判断主机模式是否为可用于索引的精确主机名（不含通配符与 IPv6 字面量）
+func isExactHost(pattern string) bool {
+	return pattern != "" && !strings.ContainsAny(pattern, "*[]:")
+}
+
+// pathGlobToRegex 将路径 glob 转换为正则表达式
+// "*" 匹配单个路径段内的任意字符，"**" 匹配任意层级（包括零层），"?" 匹配单个非 "/" 字符
+func pathGlobToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); {
+		rest := glob[i:]
+		switch {
+		case strings.HasPrefix(rest, "/**/"):
+			b.WriteString("/(?:.*/)?")
+			i += 4
+		case i == 0 && strings.HasPrefix(rest, "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case rest == "/**":
+			b.WriteString("(?:/.*)?")
+			i += 3
+		case strings.HasPrefix(rest, "**"):
+			b.WriteString(".*")
+			i += 2
+		case rest[0] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case rest[0] == '?':
+			b.WriteString("[^/]")
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			i++
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// urlPattern Chrome 风格的 URL 匹配模式，如 "*://*.example.com/api/*"
+type urlPattern struct {
+	all     bool           // <all_urls>
+	schemes []string       // 允许的协议，"*" 表示 http 与 https
+	host    string         // 主机模式
+	port    string         // 端口，空或 "*" 表示任意
+	path    *regexp.Regexp // 路径（含查询字符串）模式，"*" 匹配任意字符
+}
+
+// compileURLPattern 解析 Chrome 风格的 URL 匹配模式
+func compileURLPattern(pattern string) (*urlPattern, bool) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "<all_urls>" {
+		return &urlPattern{all: true}, true
+	}
+	scheme, rest, ok := strings.Cut(pattern, "://")
+	if !ok || scheme == "" {
+		return nil, false
+	}
+	p := &urlPattern{}
+	if scheme == "*" {
+		p.schemes = []string{"http", "https"}
+	} else {
+		p.schemes = []string{strings.ToLower(scheme)}
+	}
+
+	hostPort, path := rest, "/*"
+	if i := strings.Index(rest, "/"); i != -1 {
+		hostPort, path = rest[:i], rest[i:]
+	}
+	if hostPort == "" {
+		return nil, false
+	}
+	p.host = hostPort
+	if i := strings.LastIndex(hostPort, ":"); i != -1 && !strings.HasSuffix(hostPort, "]") {
+		p.host, p.port = hostPort[:i], hostPort[i+1:]
+	}
+	p.host = strings.ToLower(p.host)
+
+	var b strings.Builder
+	b.WriteString("^")
+	for _, part := range strings.Split(path, "*") {
+		b.WriteString(regexp.QuoteMeta(part))
+		b.WriteString(".*")
+	}
+	re, err := regexp.Compile(strings.TrimSuffix(b.String(), ".*") + "$")
+	if err != nil {
+		return nil, false
+	}
+	p.path = re
+	return p, true
+}
+
+// match 判断解析后的 URL 是否满足模式
+func (p *urlPattern) match(u *parsedURL) bool {
+	if p.all {
+		return true
+	}
+	schemeOK := false
+	for _, s := range p.schemes {
+		if u.scheme == s {
+			schemeOK = true
+			break
+		}
+	}
+	if !schemeOK || !matchHostPattern(u.host, p.host) {
+		return false
+	}
+	if p.port != "" && p.port != "*" && p.port != u.port {
+		return false
+	}
+	target := u.path
+	if u.query != "" {
+		target += "?" + u.query
+	}
+	return p.path.MatchString(target)
+}
@@ -212,6 +212,20 @@ func (a *App) AttachTarget(sessionID, targetID string) api.Response[api.EmptyDat
 	return api.OK(api.EmptyData{})
 }
 
+// SetAutoAttach 设置是否自动附着新打开的标签页、弹窗与窗口。
+// urlPattern 为空时不限 URL，openerOnly 为 true 时仅附着由已附着页面打开的页面。
+func (a *App) SetAutoAttach(sessionID string, enabled bool, urlPattern string, openerOnly bool) api.Response[api.EmptyData] {
+	opts := domain.AutoAttachOptions{Enabled: enabled, URLPattern: strings.TrimSpace(urlPattern), OpenerOnly: openerOnly}
+	err := a.service.SetAutoAttach(a.ctx, domain.SessionID(sessionID), opts)
+	if err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[api.EmptyData](code, msg)
+	}
+
+	a.log.Debug("已设置自动附着", "enabled", enabled, "urlPattern", opts.URLPattern, "openerOnly", openerOnly)
+	return api.OK(api.EmptyData{})
+}
+
 // DetachTarget 从会话中移除指定页面目标。
 func (a *App) DetachTarget(sessionID, targetID string) api.Response[api.EmptyData] {
 	err := a.service.DetachTarget(a.ctx, domain.SessionID(sessionID), domain.TargetID(targetID))
@@ -343,6 +357,71 @@ func (a *App) GetRuleStats(sessionID string) api.Response[StatsData] {
 	return api.OK(StatsData{Stats: stats})
 }
 
+// ResetRuleStats 清空指定会话的规则统计信息。
+func (a *App) ResetRuleStats(sessionID string) api.Response[api.EmptyData] {
+	if err := a.service.ResetRuleStats(a.ctx, domain.SessionID(sessionID)); err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[api.EmptyData](code, msg)
+	}
+	return api.OK(api.EmptyData{})
+}
+
+// GetSessionState 获取指定会话的变量与场景状态。
+func (a *App) GetSessionState(sessionID string) api.Response[SessionStateData] {
+	state, err := a.service.GetSessionState(a.ctx, domain.SessionID(sessionID))
+	if err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[SessionStateData](code, msg)
+	}
+	return api.OK(SessionStateData{State: state})
+}
+
+// ResetSessionState 清空指定会话的变量并将场景恢复为初始状态。
+func (a *App) ResetSessionState(sessionID string) api.Response[api.EmptyData] {
+	if err := a.service.ResetSessionState(a.ctx, domain.SessionID(sessionID)); err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[api.EmptyData](code, msg)
+	}
+	return api.OK(api.EmptyData{})
+}
+
+// ExplainRules 使用样例请求（及可选的样例响应）试运行规则配置，无需连接浏览器。
+// 样例的 body 字段为文本内容。
+func (a *App) ExplainRules(configJSON, requestJSON, responseJSON string) api.Response[ExplainData] {
+	var cfg rulespec.Config
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[ExplainData](code, msg)
+	}
+
+	// 样例中的 body 为文本，直接作为请求体与响应体的原始字节
+	sampleReq := explainRequest{Request: domain.NewRequest()}
+	if err := json.Unmarshal([]byte(requestJSON), &sampleReq); err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[ExplainData](code, msg)
+	}
+	req := sampleReq.Request
+	req.Body = []byte(sampleReq.Body)
+
+	var res *domain.Response
+	if strings.TrimSpace(responseJSON) != "" {
+		sampleRes := explainResponse{Response: domain.NewResponse()}
+		if err := json.Unmarshal([]byte(responseJSON), &sampleRes); err != nil {
+			code, msg := a.translateError(err)
+			return api.Fail[ExplainData](code, msg)
+		}
+		res = sampleRes.Response
+		res.Body = []byte(sampleRes.Body)
+	}
+
+	result, err := a.service.ExplainRules(a.ctx, &cfg, req, res)
+	if err != nil {
+		code, msg := a.translateError(err)
+		return api.Fail[ExplainData](code, msg)
+	}
+	return api.OK(ExplainData{Result: result})
+}
+
 // subscribeEvents 订阅拦截事件并通过 Wails 事件系统推送到前端。
 func (a *App) subscribeEvents(ctx context.Context, sessionID domain.SessionID) {
 	ch, err := a.service.SubscribeEvents(ctx, sessionID)
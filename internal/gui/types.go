@@ -56,6 +56,28 @@ type StatsData struct {
 	Stats domain.EngineStats `json:"stats"`
 }
 
+// SessionStateData 会话状态数据
+type SessionStateData struct {
+	State domain.SessionState `json:"state"`
+}
+
+// ExplainData 规则试运行结果数据
+type ExplainData struct {
+	Result *domain.ExplainResult `json:"result"`
+}
+
+// explainRequest 规则试运行的样例请求，body 为文本而非 base64
+type explainRequest struct {
+	*domain.Request
+	Body string `json:"body"`
+}
+
+// explainResponse 规则试运行的样例响应，body 为文本而非 base64
+type explainResponse struct {
+	*domain.Response
+	Body string `json:"body"`
+}
+
 // EventHistoryData 事件历史数据
 type EventHistoryData struct {
 	Events []model.NetworkEventRecord `json:"events"`
@@ -2,8 +2,13 @@ package processor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"cdpnetool/internal/auditor"
 	"cdpnetool/internal/engine"
@@ -20,6 +25,30 @@ type Result struct {
 	ModifiedReq *domain.Request  // 修改后的请求
 	ModifiedRes *domain.Response // 修改后的响应
 	MockRes     *domain.Response // 伪造的响应
+	FailReason  string           // 中止请求的网络错误原因（fail 行为）
+	Forward     bool             // 修改后的请求需由本地 HTTP 客户端代发（ContinueRequest 无法切换协议或修改 Host）
+
+	Delay    time.Duration // 应用结果前的延迟（delay 行为累加）
+	Throttle int64         // 伪造或改写后响应体的传输带宽，字节每秒，0 表示不限速
+
+	Rules []*engine.MatchedRule // 本阶段实际执行了行为的规则
+}
+
+// Wait 返回应用结果前需要等待的总时长：延迟时长加上按带宽传输将要返回的响应体所需的时间
+// Fetch.fulfillRequest 只能一次性返回完整响应体，限速通过推迟返回时机模拟
+func (r Result) Wait() time.Duration {
+	wait := r.Delay
+	if r.Throttle <= 0 {
+		return wait
+	}
+	var body []byte
+	switch {
+	case r.Action == ActionBlock && r.MockRes != nil:
+		body = r.MockRes.Body
+	case r.Action == ActionModify && r.ModifiedRes != nil:
+		body = r.ModifiedRes.Body
+	}
+	return wait + time.Duration(int64(len(body))*int64(time.Second)/r.Throttle)
 }
 
 type Action string
@@ -28,13 +57,21 @@ const (
 	ActionPass   Action = "pass"
 	ActionModify Action = "modify"
 	ActionBlock  Action = "block"
+	ActionFail   Action = "fail"
 )
 
+// ErrResponseBodyOmitted 响应体未读取，读取 Body 的行为无法执行
+var ErrResponseBodyOmitted = errors.New("processor: response body not loaded")
+
 // PendingState 暂存在 tracker 中的请求上下文
 type PendingState struct {
 	Request      *domain.Request
 	MatchedRules []*engine.MatchedRule
 	IsModified   bool
+	Throttle     int64 // 请求阶段 throttle 行为设定的带宽，在响应阶段生效
+
+	bodyNeeded  bool // 响应阶段可能生效的规则是否需要读取响应体
+	bodyChecked bool // bodyNeeded 是否已评估，读取响应体前评估一次，处理响应时沿用
 }
 
 // Processor 业务处理编排中心
@@ -72,8 +109,10 @@ func (p *Processor) SetContext(sessionID, targetID string) {
 func (p *Processor) ProcessRequest(ctx context.Context, req *domain.Request) Result {
 	p.log.Debug("[Processor] 开始处理请求", "requestID", req.ID, "url", req.URL, "method", req.Method)
 
-	matched := p.engine.Eval(req, rulespec.StageRequest)
-	p.engine.RecordStats(matched)
+	evalStart := time.Now()
+	candidates := p.engine.Eval(req, rulespec.StageRequest)
+	evalTime := time.Since(evalStart)
+	matched := p.selectRules(candidates)
 
 	// 记录匹配情况
 	if len(matched) == 0 {
@@ -88,46 +127,87 @@ func (p *Processor) ProcessRequest(ctx context.Context, req *domain.Request) Res
 
 	res := Result{Action: ActionPass}
 	isModified := false
+	origURL := req.URL
 
-	for _, mr := range matched {
+	for i, mr := range matched {
+		applyStart := time.Now()
+		var errs []error
+		tc := p.templateContext(mr, req, nil)
 		for _, action := range mr.Rule.Actions {
-			if action.Type == rulespec.ActionBlock {
-				p.log.Info("[Processor] 执行 Block 动作", "requestID", req.ID, "ruleID", mr.Rule.ID, "statusCode", action.StatusCode)
-				res.Action = ActionBlock
-				res.MockRes = domain.NewResponse()
-				res.MockRes.StatusCode = action.StatusCode
-				if action.Body != "" {
-					body, err := transformer.DecodeBody(action.Body, action.GetBodyEncoding())
-					if err != nil {
-						p.log.Err(err, "Block 动作中响应体解码失败", "requestID", req.ID)
-						res.MockRes.Body = []byte(action.Body)
-					} else {
-						res.MockRes.Body = []byte(body)
-					}
+			if action.Template {
+				var err error
+				if action, err = p.renderAction(mr, action, tc); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			if action.IsStateAction() {
+				if err := p.applyStateAction(req, nil, action); err != nil {
+					errs = append(errs, err)
 				}
-				res.MockRes.Headers = make(domain.Header)
-				for k, v := range action.Headers {
-					res.MockRes.Headers.Set(k, v)
+				continue
+			}
+			if action.IsNetworkAction() {
+				p.applyNetworkAction(req, &res, action)
+				continue
+			}
+
+			var mock *domain.Response
+			var err error
+			switch action.Type {
+			case rulespec.ActionBlock:
+				mock, err = p.blockResponse(req, action)
+			case rulespec.ActionMapLocal:
+				mock, err = p.mapLocalResponse(req, action)
+			case rulespec.ActionMockGraphQL:
+				mock, err = p.mockGraphQLRequest(req, action)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if mock != nil || action.Type == rulespec.ActionFail {
+				finalResult := "blocked"
+				if mock != nil {
+					p.log.Info("[Processor] 执行拦截动作", "requestID", req.ID, "ruleID", mr.Rule.ID, "actionType", action.Type, "statusCode", mock.StatusCode)
+					res.Action = ActionBlock
+					res.MockRes = mock
+				} else {
+					p.log.Info("[Processor] 执行网络错误动作", "requestID", req.ID, "ruleID", mr.Rule.ID, "errorReason", action.GetErrorReason())
+					res.Action = ActionFail
+					res.FailReason = string(action.GetErrorReason())
+					finalResult = "failed"
 				}
+				p.engine.RecordApply(mr.Rule.ID, time.Since(applyStart), errs)
+				// 终结性动作之后的规则不再执行，统计与审计只计入已执行的规则
+				res.Rules = matched[:i+1]
+				p.engine.RecordStats(rulespec.StageRequest, res.Rules, evalTime)
 
-				// Block 动作需立即记录审计（响应阶段不会再执行）
+				// 终结性动作需立即记录审计（响应阶段不会再执行）
+				ruleMatches := p.toRuleMatches(res.Rules)
 				// 1. 全量流量审计
-				p.trafficAuditor.Record(p.sessionID, p.targetID, req, res.MockRes, "blocked", p.toRuleMatches(matched))
-				// 2. 匹配事件审计（仅匹配时记录）
-				if len(matched) > 0 {
-					p.matchedAuditor.Record(p.sessionID, p.targetID, req, res.MockRes, "blocked", p.toRuleMatches(matched))
-				}
-				p.log.Debug("[Processor] Block 执行完成", "requestID", req.ID)
+				p.trafficAuditor.Record(p.sessionID, p.targetID, req, res.MockRes, finalResult, ruleMatches)
+				// 2. 匹配事件审计（终结性动作必然来自已匹配的规则）
+				p.matchedAuditor.Record(p.sessionID, p.targetID, req, res.MockRes, finalResult, ruleMatches)
+				p.log.Debug("[Processor] 终结性动作执行完成", "requestID", req.ID, "action", res.Action)
 				return res
 			}
+			if action.Type == rulespec.ActionMockGraphQL || action.Type == rulespec.ActionMapLocal {
+				// 未命中操作、批量请求仅部分命中或本地文件读取失败时不拦截，继续执行后续行为
+				continue
+			}
 
-			p.applyRequestAction(req, action)
+			if err := p.applyRequestAction(req, action); err != nil {
+				errs = append(errs, err)
+			} else if action.Type == rulespec.ActionMapRemote && action.PreserveHost {
+				res.Forward = true
+			}
 			isModified = true
 		}
+		p.engine.RecordApply(mr.Rule.ID, time.Since(applyStart), errs)
 	}
 
 	if isModified {
-		// 重建 URL（如果 Query 参数被修改）
+		// 重建 URL（仅当 Query 参数被修改）
 		rebuildURLFromQuery(req)
 		// 重建 Cookie Header（如果 Cookies 被修改）
 		if cookieStr := transformer.BuildCookieString(req.Cookies); cookieStr != "" {
@@ -138,19 +218,88 @@ func (p *Processor) ProcessRequest(ctx context.Context, req *domain.Request) Res
 
 		res.Action = ActionModify
 		res.ModifiedReq = req
-		p.log.Debug("[Processor] 请求已修改", "requestID", req.ID, "matchedCount", len(matched))
+		if urlScheme(req.URL) != urlScheme(origURL) {
+			res.Forward = true
+		}
+		p.log.Debug("[Processor] 请求已修改", "requestID", req.ID, "matchedCount", len(matched), "forward", res.Forward)
 	}
+	res.Rules = matched
+	p.engine.RecordStats(rulespec.StageRequest, matched, evalTime)
 
 	p.tracker.Set(req.ID, &PendingState{
 		Request:      req,
 		MatchedRules: matched,
 		IsModified:   isModified,
+		Throttle:     res.Throttle,
 	})
+	// 请求被延迟放行时顺延过期时间，避免响应到达前被当作超时事务清理
+	if res.Delay > 0 {
+		p.tracker.Extend(req.ID, res.Delay)
+	}
 	p.log.Debug("[Processor] 请求已入池", "requestID", req.ID)
 
 	return res
 }
 
+// NeedsResponseBody 判断响应阶段是否需要读取响应体：请求阶段设置了限速，或可能生效的响应规则需要读取响应体
+func (p *Processor) NeedsResponseBody(reqID string, res *domain.Response) bool {
+	stateVal, ok := p.tracker.Peek(reqID)
+	if !ok {
+		return false
+	}
+	state := stateVal.(*PendingState)
+	return state.Throttle > 0 || p.responseBodyNeeded(state, res)
+}
+
+// responseBodyNeeded 判断响应规则是否需要读取响应体，同一事务只评估一次
+func (p *Processor) responseBodyNeeded(state *PendingState, res *domain.Response) bool {
+	if !state.bodyChecked {
+		state.bodyNeeded = p.engine.ResponseBodyNeeded(state.Request, res)
+		state.bodyChecked = true
+	}
+	return state.bodyNeeded
+}
+
+// BodyPlan 响应阶段读取响应体的方式
+type BodyPlan int
+
+const (
+	BodySkip    BodyPlan = iota // 不读取响应体，原样放行
+	BodyGet                     // 规则需要，通过 GetResponseBody 一次性读取
+	BodyStream                  // 规则需要且长度超过阈值，通过 IO 流分块读取，读取后只能以 FulfillRequest 放行
+	BodyCapture                 // 仅流量捕获需要，通过 GetResponseBody 读取，失败时不影响放行
+)
+
+// ResponseBodyPlan 按规则与流量捕获的需要以及 Content-Length 决定响应体的读取方式
+// 仅当 Content-Length 已知且超过阈值时才通过流读取；长度未知（chunked、HTTP/2 等）时规则需要仍一次性读取；
+// 长度超过阈值或未知的音视频响应不读取；仅流量捕获需要时只读取不超过阈值的响应体，长度未知时只读取非二进制类型
+func (p *Processor) ResponseBodyPlan(reqID string, res *domain.Response, media bool, threshold int64) BodyPlan {
+	size := contentLength(res.Headers)
+	unknown := size < 0
+	large := size > threshold
+	switch {
+	case (large || unknown) && media:
+		return BodySkip
+	case p.NeedsResponseBody(reqID, res):
+		if large {
+			return BodyStream
+		}
+		return BodyGet
+	case p.trafficAuditor.IsEnabled() && !large && (!unknown || !transformer.IsBinaryContentType(res.Headers.Get("Content-Type"))):
+		return BodyCapture
+	}
+	return BodySkip
+}
+
+// contentLength 读取 Content-Length，缺失或非法时返回 -1
+func contentLength(h domain.Header) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(h.Get("Content-Length")), 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
 // ProcessResponse 处理响应阶段逻辑
 func (p *Processor) ProcessResponse(ctx context.Context, reqID string, res *domain.Response) Result {
 	p.log.Debug("[Processor] 开始处理响应", "requestID", reqID, "statusCode", res.StatusCode)
@@ -163,8 +312,28 @@ func (p *Processor) ProcessResponse(ctx context.Context, reqID string, res *doma
 	state := stateVal.(*PendingState)
 	p.log.Debug("[Processor] 从池中获取请求", "requestID", reqID, "url", state.Request.URL)
 
-	matched := p.engine.Eval(state.Request, rulespec.StageResponse)
-	p.engine.RecordStats(matched)
+	// 压缩或非 UTF-8 编码的响应体解码为 UTF-8 后，条件匹配与文本类行为都作用于解码后的内容
+	// 仅在响应体条件需要或有规则命中（行为可能读取、写回响应体）时解码，未命中的响应原样放行
+	raw := res.Body
+	var codec *transformer.BodyCodec
+	decode := func() {
+		body, c, err := transformer.DecodeResponseBody(res.Headers, raw)
+		if err != nil {
+			p.log.Err(err, "响应体解压或转码失败", "requestID", reqID, "contentEncoding", res.Headers.Get("Content-Encoding"))
+		}
+		res.Body, codec = body, c
+	}
+	if p.responseBodyNeeded(state, res) {
+		decode()
+	}
+
+	evalStart := time.Now()
+	candidates := p.engine.EvalResponse(state.Request, res)
+	evalTime := time.Since(evalStart)
+	matched := p.selectRules(candidates)
+	if codec == nil && (len(matched) > 0 || state.Throttle > 0) {
+		decode()
+	}
 
 	if len(matched) > 0 {
 		ruleIDs := make([]string, len(matched))
@@ -182,33 +351,120 @@ func (p *Processor) ProcessResponse(ctx context.Context, reqID string, res *doma
 		finalResult = "modified"
 	}
 
+	result := Result{Action: ActionPass, Throttle: state.Throttle, Rules: matched}
 	if len(matched) > 0 {
-		for _, mr := range matched {
+	rules:
+		for i, mr := range matched {
+			applyStart := time.Now()
+			var errs []error
+			tc := p.templateContext(mr, state.Request, res)
 			for _, action := range mr.Rule.Actions {
-				p.applyResponseAction(res, action, reqID)
+				if action.Template {
+					var err error
+					if action, err = p.renderAction(mr, action, tc); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+				}
+				if res.BodyOmitted && action.ReadsBody() {
+					p.log.Warn("[Processor] 响应体未读取，跳过行为", "requestID", reqID, "ruleID", mr.Rule.ID, "actionType", action.Type)
+					errs = append(errs, ErrResponseBodyOmitted)
+					continue
+				}
+				if action.IsStateAction() {
+					if err := p.applyStateAction(state.Request, res, action); err != nil {
+						errs = append(errs, err)
+					}
+					continue
+				}
+				if action.IsNetworkAction() {
+					p.applyNetworkAction(state.Request, &result, action)
+					continue
+				}
+				if action.Type == rulespec.ActionFail {
+					p.log.Info("[Processor] 执行网络错误动作", "requestID", reqID, "ruleID", mr.Rule.ID, "errorReason", action.GetErrorReason())
+					result.Action = ActionFail
+					result.FailReason = string(action.GetErrorReason())
+					result.Rules = matched[:i+1]
+					finalResult = "failed"
+					p.engine.RecordApply(mr.Rule.ID, time.Since(applyStart), errs)
+					break rules
+				}
+				if err := p.applyResponseAction(state.Request, res, action); err != nil {
+					errs = append(errs, err)
+				}
 				finalResult = "modified"
 			}
+			p.engine.RecordApply(mr.Rule.ID, time.Since(applyStart), errs)
 		}
 	}
 
-	allMatched := append(state.MatchedRules, matched...)
+	// 网络错误中止后的规则不再执行，统计与审计只计入已执行的规则
+	p.engine.RecordStats(rulespec.StageResponse, result.Rules, evalTime)
+	allMatched := append(state.MatchedRules, result.Rules...)
 	ruleMatches := p.toRuleMatches(allMatched)
 
+	// 中止的请求没有交付给页面的响应
+	auditRes := res
+	if result.Action == ActionFail {
+		auditRes = nil
+	}
 	// 1. 全量流量审计
-	p.trafficAuditor.Record(p.sessionID, p.targetID, state.Request, res, finalResult, ruleMatches)
+	p.trafficAuditor.Record(p.sessionID, p.targetID, state.Request, auditRes, finalResult, ruleMatches)
 	// 2. 匹配事件审计（仅匹配时记录）
 	if len(allMatched) > 0 {
-		p.matchedAuditor.Record(p.sessionID, p.targetID, state.Request, res, finalResult, ruleMatches)
+		p.matchedAuditor.Record(p.sessionID, p.targetID, state.Request, auditRes, finalResult, ruleMatches)
 	}
 	p.log.Debug("[Processor] 响应处理完成", "requestID", reqID, "finalResult", finalResult)
 
-	if finalResult == "modified" {
-		return Result{
-			Action:      ActionModify,
-			ModifiedRes: res,
+	if result.Action == ActionFail {
+		return result
+	}
+	// 限速需要由 FulfillRequest 返回响应体，未修改的响应也按原样返回
+	if finalResult == "modified" || result.Throttle > 0 {
+		// 按原字符集写回，并使 Content-Encoding、Content-Length 与实际返回的响应体一致
+		// 未读取响应体时只修改状态码与头部，由浏览器沿用原始响应体
+		if !res.BodyOmitted {
+			if codec == nil {
+				decode()
+			}
+			res.Body = codec.EncodeBody(&res.Headers, res.Body)
+		}
+		result.Action = ActionModify
+		result.ModifiedRes = res
+	} else {
+		// 未修改的响应（如代发得到的响应）与原始响应头保持一致
+		res.Body = raw
+	}
+	return result
+}
+
+// selectRules 按流程控制与命中限制筛选实际执行的规则（输入已按优先级降序）
+// first 模式的分组仅保留第一条生效规则；遇到 stopAfterMatch 规则后丢弃其后的所有规则
+// 被流程控制跳过的规则不计入命中限制
+func (p *Processor) selectRules(matched []*engine.MatchedRule) []*engine.MatchedRule {
+	if len(matched) == 0 {
+		return matched
+	}
+	selected := make([]*engine.MatchedRule, 0, len(matched))
+	firedGroups := make(map[string]bool)
+	for _, mr := range matched {
+		first := mr.GroupMode == rulespec.GroupModeFirst
+		if first && firedGroups[mr.Rule.Group] {
+			continue
+		}
+		if !p.engine.Allow(mr.Rule) {
+			continue
+		}
+		if first {
+			firedGroups[mr.Rule.Group] = true
+		}
+		selected = append(selected, mr)
+		if mr.Rule.StopAfterMatch {
+			break
 		}
 	}
-	return Result{Action: ActionPass}
+	return selected
 }
 
 // toRuleMatches 将内部匹配结果转换为领域模型
@@ -228,13 +484,14 @@ func (p *Processor) toRuleMatches(matched []*engine.MatchedRule) []domain.RuleMa
 	return res
 }
 
-// applyRequestAction 应用单个请求修改动作
-func (p *Processor) applyRequestAction(req *domain.Request, action rulespec.Action) {
+// applyRequestAction 应用单个请求修改动作，失败时记录日志并返回错误
+func (p *Processor) applyRequestAction(req *domain.Request, action rulespec.Action) error {
 	p.log.Debug("[Processor] 应用请求修改", "requestID", req.ID, "actionType", action.Type, "actionName", action.Name)
 	switch action.Type {
 	case rulespec.ActionSetUrl:
 		if v, ok := action.Value.(string); ok {
 			req.URL = v
+			req.Query = transformer.ParseQuery(v)
 		}
 	case rulespec.ActionSetMethod:
 		if v, ok := action.Value.(string); ok {
@@ -248,10 +505,10 @@ func (p *Processor) applyRequestAction(req *domain.Request, action rulespec.Acti
 		req.Headers.Del(action.Name)
 	case rulespec.ActionSetQueryParam:
 		if v, ok := action.Value.(string); ok {
-			req.Query[action.Name] = v
+			req.Query.Set(action.Name, v)
 		}
 	case rulespec.ActionRemoveQueryParam:
-		delete(req.Query, action.Name)
+		req.Query.Del(action.Name)
 	case rulespec.ActionSetCookie:
 		if v, ok := action.Value.(string); ok {
 			req.Cookies[action.Name] = v
@@ -263,18 +520,18 @@ func (p *Processor) applyRequestAction(req *domain.Request, action rulespec.Acti
 			body, err := transformer.DecodeBody(v, action.GetEncoding())
 			if err != nil {
 				p.log.Err(err, "请求体解码失败", "requestID", req.ID)
-			} else {
-				req.Body = []byte(body)
+				return err
 			}
+			req.Body = []byte(body)
 		}
 	case rulespec.ActionAppendBody:
 		if v, ok := action.Value.(string); ok {
 			appendText, err := transformer.DecodeBody(v, action.GetEncoding())
 			if err != nil {
 				p.log.Err(err, "追加请求体解码失败", "requestID", req.ID)
-			} else {
-				req.Body = append(req.Body, []byte(appendText)...)
+				return err
 			}
+			req.Body = append(req.Body, []byte(appendText)...)
 		}
 	case rulespec.ActionReplaceBodyText:
 		newBody := transformer.ReplaceText(string(req.Body), action.Search, action.Replace, action.ReplaceAll)
@@ -283,30 +540,298 @@ func (p *Processor) applyRequestAction(req *domain.Request, action rulespec.Acti
 		newBody, err := transformer.PatchJSON(string(req.Body), action.Patches)
 		if err != nil {
 			p.log.Err(err, "请求体 JSON Patch 失败", "requestID", req.ID)
-		} else {
-			req.Body = []byte(newBody)
+			return err
 		}
+		req.Body = []byte(newBody)
+	case rulespec.ActionMergeBodyJson:
+		newBody, err := transformer.MergeJSON(string(req.Body), action.Value)
+		if err != nil {
+			p.log.Err(err, "请求体 JSON Merge Patch 失败", "requestID", req.ID)
+			return err
+		}
+		req.Body = []byte(newBody)
 	case rulespec.ActionSetFormField:
 		if v, ok := action.Value.(string); ok {
-			newBody, err := transformer.SetFormUrlencoded(string(req.Body), action.Name, v)
+			return p.editForm(req, func(f *transformer.Form) error {
+				f.SetField(action.Name, v)
+				return nil
+			})
+		}
+	case rulespec.ActionRemoveFormField:
+		return p.editForm(req, func(f *transformer.Form) error {
+			f.RemoveField(action.Name)
+			return nil
+		})
+	case rulespec.ActionPatchGraphQLVariables:
+		gql, err := transformer.ParseGraphQL(req.Body)
+		if err != nil {
+			return nil
+		}
+		newBody, err := transformer.PatchGraphQLVariables(req.Body, gql, gql.Match(action.OperationName), action.Patches)
+		if err != nil {
+			p.log.Err(err, "GraphQL variables JSON Patch 失败", "requestID", req.ID)
+			return err
+		}
+		req.Body = newBody
+	case rulespec.ActionMapRemote:
+		return p.mapRemote(req, action)
+	case rulespec.ActionSetFormFile:
+		patch := transformer.FilePatch{Filename: action.FileName, ContentType: action.ContentType}
+		if v, ok := action.Value.(string); ok && v != "" {
+			content, err := transformer.DecodeBody(v, action.GetEncoding())
 			if err != nil {
-				p.log.Err(err, "设置表单字段失败", "requestID", req.ID)
-			} else {
-				req.Body = []byte(newBody)
+				p.log.Err(err, "表单文件内容解码失败", "requestID", req.ID)
+				return err
 			}
+			patch.Content = []byte(content)
 		}
-	case rulespec.ActionRemoveFormField:
-		newBody, err := transformer.RemoveFormUrlencoded(string(req.Body), action.Name)
+		return p.editForm(req, func(f *transformer.Form) error {
+			return f.SetFile(action.Name, patch)
+		})
+	}
+	return nil
+}
+
+// mapRemote 将请求 URL 映射到目标地址，按需保留原始 Host 或改写 Origin
+func (p *Processor) mapRemote(req *domain.Request, action rulespec.Action) error {
+	mapped, ok, err := transformer.MapRemoteURL(req.URL, action.From, action.To)
+	if err != nil {
+		p.log.Err(err, "映射远程地址失败", "requestID", req.ID, "to", action.To)
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	if action.PreserveHost {
+		if u, err := url.Parse(req.URL); err == nil && !req.Headers.Has("Host") {
+			req.Headers.Set("Host", u.Host)
+		}
+	}
+	if action.RewriteOrigin && req.Headers.Has("Origin") {
+		req.Headers.Set("Origin", transformer.URLOrigin(mapped))
+	}
+	req.URL = mapped
+	req.Query = transformer.ParseQuery(mapped)
+	return nil
+}
+
+// urlScheme 返回 URL 的协议（小写），无法解析时返回空字符串
+func urlScheme(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
+
+// editForm 按 Content-Type 解析表单请求体并执行修改，重建后同步 multipart 分隔符
+func (p *Processor) editForm(req *domain.Request, edit func(f *transformer.Form) error) error {
+	ct := req.Headers.Get("Content-Type")
+	form, err := transformer.ParseForm(ct, req.Body)
+	if err != nil {
+		p.log.Err(err, "解析表单请求体失败", "requestID", req.ID)
+		return err
+	}
+	if err := edit(form); err != nil {
+		p.log.Err(err, "修改表单字段失败", "requestID", req.ID)
+		return err
+	}
+	req.Body = form.Bytes()
+	if form.IsMultipart() {
+		if newCT := form.ContentType(); newCT != ct {
+			req.Headers.Set("Content-Type", newCT)
+		}
+	}
+	return nil
+}
+
+// applyStateAction 应用会话状态行为，extractVariable 在响应阶段读取响应体，否则读取请求体
+func (p *Processor) applyStateAction(req *domain.Request, res *domain.Response, action rulespec.Action) error {
+	p.log.Debug("[Processor] 应用会话状态行为", "requestID", req.ID, "actionType", action.Type, "actionName", action.Name)
+	switch action.Type {
+	case rulespec.ActionSetVariable:
+		if v, ok := action.Value.(string); ok {
+			p.engine.SetVariable(action.Name, v)
+		}
+	case rulespec.ActionExtractVariable:
+		body := req.Body
+		if res != nil {
+			body = res.Body
+		}
+		v, ok := transformer.ExtractJSONPath(body, action.Path)
+		if !ok {
+			err := fmt.Errorf("extractVariable: json path %q not found", action.Path)
+			p.log.Err(err, "提取会话变量失败", "requestID", req.ID, "variable", action.Name)
+			return err
+		}
+		p.engine.SetVariable(action.Name, v)
+	case rulespec.ActionSetScenarioState:
+		if v, ok := action.Value.(string); ok && v != "" {
+			p.engine.SetScenarioState(action.Name, v)
+		}
+	}
+	return nil
+}
+
+// applyNetworkAction 应用延迟与限速行为，只记录到处理结果中，由编排层推迟应用结果
+// 多个延迟累加，多个限速取最小带宽
+func (p *Processor) applyNetworkAction(req *domain.Request, res *Result, action rulespec.Action) {
+	switch action.Type {
+	case rulespec.ActionDelay:
+		ms := action.DelayMS
+		if action.DelayMaxMS > ms {
+			ms += rand.Intn(action.DelayMaxMS - ms + 1)
+		}
+		if ms > 0 {
+			res.Delay += time.Duration(ms) * time.Millisecond
+		}
+	case rulespec.ActionThrottle:
+		if bps := action.BytesPerSecond; bps > 0 && (res.Throttle == 0 || bps < res.Throttle) {
+			res.Throttle = bps
+		}
+	}
+	p.log.Debug("[Processor] 应用网络模拟行为", "requestID", req.ID, "actionType", action.Type, "delay", res.Delay, "throttle", res.Throttle)
+}
+
+// templateContext 为规则创建模板上下文，规则中没有启用模板的行为时返回 nil
+// 正则捕获组在执行该规则的行为之前提取，不受本规则行为修改的影响
+func (p *Processor) templateContext(mr *engine.MatchedRule, req *domain.Request, res *domain.Response) *transformer.TemplateContext {
+	for _, action := range mr.Rule.Actions {
+		if action.Template {
+			groups, named := mr.Captures(req, res)
+			return &transformer.TemplateContext{Req: req, Res: res, Groups: groups, Named: named, Vars: p.engine.Variable}
+		}
+	}
+	return nil
+}
+
+// renderAction 渲染启用模板的行为，返回渲染后的副本
+func (p *Processor) renderAction(mr *engine.MatchedRule, action rulespec.Action, tc *transformer.TemplateContext) (rulespec.Action, error) {
+	render := func(field, text string) (string, error) {
+		out, err := mr.RenderTemplate(text, tc)
+		if err != nil {
+			p.log.Err(err, "行为模板渲染失败", "requestID", tc.Req.ID, "actionType", action.Type, "field", field)
+		}
+		return out, err
+	}
+
+	var err error
+	if v, ok := action.Value.(string); ok {
+		if action.Value, err = render("value", v); err != nil {
+			return action, err
+		}
+	}
+	if action.Replace, err = render("replace", action.Replace); err != nil {
+		return action, err
+	}
+	if action.Body, err = render("body", action.Body); err != nil {
+		return action, err
+	}
+	if len(action.Headers) > 0 {
+		headers := make(map[string]string, len(action.Headers))
+		for k, v := range action.Headers {
+			if headers[k], err = render("headers", v); err != nil {
+				return action, err
+			}
+		}
+		action.Headers = headers
+	}
+	return action, nil
+}
+
+// blockResponse 根据 block 行为构造伪造响应，响应体解码失败时按原文返回并报告错误
+func (p *Processor) blockResponse(req *domain.Request, action rulespec.Action) (*domain.Response, error) {
+	mock := domain.NewResponse()
+	mock.StatusCode = action.StatusCode
+	var decodeErr error
+	if action.Body != "" {
+		body, err := transformer.DecodeBody(action.Body, action.GetBodyEncoding())
 		if err != nil {
-			p.log.Err(err, "移除表单字段失败", "requestID", req.ID)
+			p.log.Err(err, "Block 动作中响应体解码失败", "requestID", req.ID)
+			decodeErr = err
+			mock.Body = []byte(action.Body)
 		} else {
-			req.Body = []byte(newBody)
+			mock.Body = []byte(body)
+		}
+	}
+	mock.Headers = make(domain.Header, 0, len(action.Headers))
+	for k, v := range action.Headers {
+		mock.Headers.Set(k, v)
+	}
+	return mock, decodeErr
+}
+
+// mapLocalResponse 以本地文件构造响应，文件不存在时返回 404
+// 其他读取错误时返回 nil 与错误，请求继续发往服务器
+func (p *Processor) mapLocalResponse(req *domain.Request, action rulespec.Action) (*domain.Response, error) {
+	if action.LocalPath == "" {
+		err := errors.New("mapLocal: localPath is empty")
+		p.log.Err(err, "本地映射路径为空", "requestID", req.ID)
+		return nil, err
+	}
+	name, err := transformer.ResolveLocalPath(action.LocalPath, action.URLPrefix, req.URL)
+	var file *transformer.LocalFile
+	if err == nil {
+		file, err = transformer.ReadLocalFile(name)
+	}
+
+	mock := domain.NewResponse()
+	switch {
+	case errors.Is(err, transformer.ErrLocalFileNotFound):
+		p.log.Debug("[Processor] 本地映射文件不存在", "requestID", req.ID, "localPath", action.LocalPath, "url", req.URL)
+		mock.StatusCode = 404
+		mock.Headers.Set("Content-Type", "text/plain; charset=utf-8")
+		mock.Body = []byte("Not Found")
+	case err != nil:
+		p.log.Err(err, "读取本地映射文件失败", "requestID", req.ID, "localPath", action.LocalPath)
+		return nil, err
+	default:
+		mock.StatusCode = action.StatusCode
+		if mock.StatusCode == 0 {
+			mock.StatusCode = 200
 		}
+		mock.Headers.Set("Content-Type", file.ContentType)
+		mock.Body = file.Body
+	}
+	// 本地文件随时可能被重新构建，禁止浏览器缓存
+	mock.Headers.Set("Cache-Control", "no-store")
+	for k, v := range action.Headers {
+		mock.Headers.Set(k, v)
+	}
+	return mock, nil
+}
+
+// mockGraphQLRequest 在请求阶段为命中的 GraphQL 操作构造伪造响应
+// 没有命中的操作时返回 nil；批量请求仅部分命中时返回 nil 与 ErrPartialBatch
+func (p *Processor) mockGraphQLRequest(req *domain.Request, action rulespec.Action) (*domain.Response, error) {
+	gql, err := transformer.ParseGraphQL(req.Body)
+	if err != nil {
+		return nil, nil
+	}
+	indexes := gql.Match(action.OperationName)
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	result, err := transformer.GraphQLResult(action.Data, action.Errors)
+	if err != nil {
+		p.log.Err(err, "构造 GraphQL 伪造结果失败", "requestID", req.ID)
+		return nil, err
 	}
+	body, err := transformer.MockGraphQLRequest(gql, indexes, result)
+	if err != nil {
+		p.log.Err(err, "GraphQL 请求阶段伪造失败", "requestID", req.ID, "operationName", action.OperationName)
+		return nil, err
+	}
+	mock := domain.NewResponse()
+	mock.StatusCode = 200
+	mock.Headers.Set("Content-Type", "application/json; charset=utf-8")
+	mock.Body = body
+	return mock, nil
 }
 
-// applyResponseAction 应用单个响应修改动作
-func (p *Processor) applyResponseAction(res *domain.Response, action rulespec.Action, reqID string) {
+// applyResponseAction 应用单个响应修改动作，失败时记录日志并返回错误
+func (p *Processor) applyResponseAction(req *domain.Request, res *domain.Response, action rulespec.Action) error {
+	reqID := req.ID
 	p.log.Debug("[Processor] 应用响应修改", "requestID", reqID, "actionType", action.Type, "actionName", action.Name)
 	switch action.Type {
 	case rulespec.ActionSetStatus:
@@ -326,18 +851,19 @@ func (p *Processor) applyResponseAction(res *domain.Response, action rulespec.Ac
 			body, err := transformer.DecodeBody(v, action.GetEncoding())
 			if err != nil {
 				p.log.Err(err, "响应体解码失败", "requestID", reqID)
-			} else {
-				res.Body = []byte(body)
+				return err
 			}
+			res.Body = []byte(body)
+			res.BodyOmitted = false
 		}
 	case rulespec.ActionAppendBody:
 		if v, ok := action.Value.(string); ok {
 			appendText, err := transformer.DecodeBody(v, action.GetEncoding())
 			if err != nil {
 				p.log.Err(err, "追加响应体解码失败", "requestID", reqID)
-			} else {
-				res.Body = append(res.Body, []byte(appendText)...)
+				return err
 			}
+			res.Body = append(res.Body, []byte(appendText)...)
 		}
 	case rulespec.ActionReplaceBodyText:
 		newBody := transformer.ReplaceText(string(res.Body), action.Search, action.Replace, action.ReplaceAll)
@@ -346,10 +872,38 @@ func (p *Processor) applyResponseAction(res *domain.Response, action rulespec.Ac
 		newBody, err := transformer.PatchJSON(string(res.Body), action.Patches)
 		if err != nil {
 			p.log.Err(err, "响应体 JSON Patch 失败", "requestID", reqID)
-		} else {
-			res.Body = []byte(newBody)
+			return err
+		}
+		res.Body = []byte(newBody)
+	case rulespec.ActionMergeBodyJson:
+		newBody, err := transformer.MergeJSON(string(res.Body), action.Value)
+		if err != nil {
+			p.log.Err(err, "响应体 JSON Merge Patch 失败", "requestID", reqID)
+			return err
+		}
+		res.Body = []byte(newBody)
+	case rulespec.ActionMockGraphQL:
+		gql, err := transformer.ParseGraphQL(req.Body)
+		if err != nil {
+			return nil
+		}
+		indexes := gql.Match(action.OperationName)
+		if len(indexes) == 0 {
+			return nil
+		}
+		result, err := transformer.GraphQLResult(action.Data, action.Errors)
+		if err != nil {
+			p.log.Err(err, "构造 GraphQL 伪造结果失败", "requestID", reqID)
+			return err
+		}
+		newBody, err := transformer.MockGraphQLResponse(res.Body, gql, indexes, result)
+		if err != nil {
+			p.log.Err(err, "GraphQL 响应伪造失败", "requestID", reqID, "operationName", action.OperationName)
+			return err
 		}
+		res.Body = newBody
 	}
+	return nil
 }
 
 // IsMatched 判断请求是否匹配了任何规则
@@ -357,30 +911,12 @@ func (s *PendingState) IsMatched() bool {
 	return len(s.MatchedRules) > 0
 }
 
-// rebuildURLFromQuery 从 Query 字典重建 URL 的查询参数部分
+// rebuildURLFromQuery 从 Query 重建 URL 的查询字符串
+// 查询参数未变化时保持 URL 原样；否则只重新编码被修改的参数，其余参数与片段保持原始字节
 func rebuildURLFromQuery(req *domain.Request) {
-	if len(req.Query) == 0 {
-		// 如果 Query 为空，移除 URL 中的查询参数
-		if idx := strings.Index(req.URL, "?"); idx != -1 {
-			req.URL = req.URL[:idx]
-		}
+	rawQuery := transformer.BuildQueryString(req.Query)
+	if rawQuery == transformer.BuildQueryString(transformer.ParseQuery(req.URL)) {
 		return
 	}
-
-	// 解析基础 URL
-	parsedURL, err := url.Parse(req.URL)
-	if err != nil {
-		// 解析失败，保持原 URL 不变
-		return
-	}
-
-	// 从 Query 字典构建查询参数
-	query := url.Values{}
-	for k, v := range req.Query {
-		query.Set(k, v)
-	}
-
-	// 更新 URL 的查询参数
-	parsedURL.RawQuery = query.Encode()
-	req.URL = parsedURL.String()
+	req.URL = transformer.ReplaceQuery(req.URL, rawQuery)
 }
@@ -1,7 +1,13 @@
 package processor_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,8 +16,11 @@ import (
 	"cdpnetool/internal/logger"
 	"cdpnetool/internal/processor"
 	"cdpnetool/internal/tracker"
+	"cdpnetool/internal/transformer"
 	"cdpnetool/pkg/domain"
 	"cdpnetool/pkg/rulespec"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 func TestNew(t *testing.T) {
@@ -106,6 +115,149 @@ func TestProcessRequest_Block(t *testing.T) {
 	}
 }
 
+func TestProcess_Fail(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "offline",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/offline"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionFail, ErrorReason: rulespec.ErrorReasonInternetDisconnected},
+				{Type: rulespec.ActionSetHeader, Name: "X-After", Value: "1"},
+			},
+		},
+		{
+			ID:      "default",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/default"}}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionFail}},
+		},
+		{
+			ID:      "reset",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionStatusCodeRange, Value: "500-599"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionDelay, DelayMS: 10},
+				{Type: rulespec.ActionFail, ErrorReason: rulespec.ErrorReasonConnectionReset},
+				{Type: rulespec.ActionSetStatus, Value: 200},
+			},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	p := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+	tests := []struct {
+		name   string
+		url    string
+		reason string
+	}{
+		{"指定错误原因", "https://example.com/offline", "InternetDisconnected"},
+		{"默认错误原因", "https://example.com/default", "Failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := domain.NewRequest()
+			req.ID = tt.name
+			req.URL = tt.url
+			result := p.ProcessRequest(context.Background(), req)
+			if result.Action != processor.ActionFail || result.FailReason != tt.reason {
+				t.Errorf("got action %v reason %q, want fail %q", result.Action, result.FailReason, tt.reason)
+			}
+			if req.Headers.Get("X-After") != "" {
+				t.Error("actions after fail should not run")
+			}
+			if evt := <-trafficChan; evt.FinalResult != "failed" || evt.Response != nil {
+				t.Errorf("got audit result %q response %v", evt.FinalResult, evt.Response)
+			}
+		})
+	}
+
+	// 响应阶段中止，之前累加的延迟保留
+	req := domain.NewRequest()
+	req.ID = "r3"
+	req.URL = "https://example.com/api"
+	p.ProcessRequest(context.Background(), req)
+	res := domain.NewResponse()
+	res.StatusCode = 500
+	result := p.ProcessResponse(context.Background(), "r3", res)
+	if result.Action != processor.ActionFail || result.FailReason != "ConnectionReset" || result.Delay != 10*time.Millisecond {
+		t.Errorf("got %+v, want ConnectionReset after 10ms", result)
+	}
+	if res.StatusCode != 500 {
+		t.Error("actions after fail should not run in the response stage")
+	}
+}
+
+func TestProcessRequest_MapLocal(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	dist := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dist, "app.js"), []byte("console.log(1)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "local",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLPrefix, Value: "https://cdn.example.com/static/"}}},
+			Actions: []rulespec.Action{{
+				Type:      rulespec.ActionMapLocal,
+				LocalPath: dist,
+				URLPrefix: "https://cdn.example.com/static/",
+				Headers:   map[string]string{"Access-Control-Allow-Origin": "*"},
+			}},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	p := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		ct     string
+		body   string
+	}{
+		{"映射到本地文件", "https://cdn.example.com/static/app.js?v=3", 200, "text/javascript; charset=utf-8", "console.log(1)"},
+		{"本地文件不存在", "https://cdn.example.com/static/vendor.js", 404, "text/plain; charset=utf-8", "Not Found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := domain.NewRequest()
+			req.ID = tt.name
+			req.URL = tt.url
+			result := p.ProcessRequest(context.Background(), req)
+			if result.Action != processor.ActionBlock || result.MockRes == nil {
+				t.Fatalf("got %+v, want fulfilled response", result)
+			}
+			res := result.MockRes
+			if res.StatusCode != tt.status || res.Headers.Get("Content-Type") != tt.ct || string(res.Body) != tt.body {
+				t.Errorf("got %d %q %q", res.StatusCode, res.Headers.Get("Content-Type"), res.Body)
+			}
+			if res.Headers.Get("Access-Control-Allow-Origin") != "*" || res.Headers.Get("Cache-Control") != "no-store" {
+				t.Errorf("unexpected headers %v", res.Headers)
+			}
+		})
+	}
+}
+
 func TestProcessRequest_ModifyHeader(t *testing.T) {
 	tr := tracker.New(5*time.Second, logger.NewNop())
 	defer tr.Stop()
@@ -140,7 +292,7 @@ func TestProcessRequest_ModifyHeader(t *testing.T) {
 		ID:      "req1",
 		URL:     "https://example.com/test",
 		Method:  "GET",
-		Headers: make(domain.Header),
+		Headers: domain.Header{},
 	}
 
 	result := p.ProcessRequest(context.Background(), req)
@@ -200,6 +352,649 @@ func TestProcessRequest_ModifyURL(t *testing.T) {
 	}
 }
 
+func TestProcessRequest_MapRemote(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     rulespec.Action
+		wantURL    string
+		wantHost   string
+		wantOrigin string
+		forward    bool
+	}{
+		{
+			name:       "同协议映射改写 Origin",
+			action:     rulespec.Action{Type: rulespec.ActionMapRemote, From: "https://staging.example.com/api/", To: "https://api.local.test/v2/", RewriteOrigin: true},
+			wantURL:    "https://api.local.test/v2/users?id=1",
+			wantOrigin: "https://api.local.test",
+		},
+		{
+			name:       "切换协议时改由本地代发",
+			action:     rulespec.Action{Type: rulespec.ActionMapRemote, To: "http://localhost:8080"},
+			wantURL:    "http://localhost:8080/api/users?id=1",
+			wantOrigin: "https://staging.example.com",
+			forward:    true,
+		},
+		{
+			name:       "保留原始 Host 时改由本地代发",
+			action:     rulespec.Action{Type: rulespec.ActionMapRemote, To: "https://127.0.0.1:8443", PreserveHost: true},
+			wantURL:    "https://127.0.0.1:8443/api/users?id=1",
+			wantHost:   "staging.example.com",
+			wantOrigin: "https://staging.example.com",
+			forward:    true,
+		},
+		{
+			name:       "前缀不匹配时保持原样",
+			action:     rulespec.Action{Type: rulespec.ActionMapRemote, From: "https://other.example.com/", To: "http://localhost:8080/"},
+			wantURL:    "https://staging.example.com/api/users?id=1",
+			wantOrigin: "https://staging.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tracker.New(5*time.Second, logger.NewNop())
+			defer tr.Stop()
+
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "remote",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/api/"}}},
+				Actions: []rulespec.Action{tt.action},
+			}}
+			events := make(chan domain.NetworkEvent, 10)
+			trafficChan := make(chan domain.NetworkEvent, 10)
+			p := processor.New(tr, engine.New(cfg), auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+			req := domain.NewRequest()
+			req.ID = "req1"
+			req.Method = "GET"
+			req.URL = "https://staging.example.com/api/users?id=1"
+			req.Query = transformer.ParseQuery(req.URL)
+			req.Headers.Set("Origin", "https://staging.example.com")
+
+			result := p.ProcessRequest(context.Background(), req)
+			if result.Action != processor.ActionModify || result.Forward != tt.forward {
+				t.Fatalf("got action %v forward %v, want modify forward %v", result.Action, result.Forward, tt.forward)
+			}
+			if req.URL != tt.wantURL {
+				t.Errorf("got URL %q, want %q", req.URL, tt.wantURL)
+			}
+			if got := req.Headers.Get("Host"); got != tt.wantHost {
+				t.Errorf("got Host %q, want %q", got, tt.wantHost)
+			}
+			if got := req.Headers.Get("Origin"); got != tt.wantOrigin {
+				t.Errorf("got Origin %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestProcessRequest_QueryRebuild(t *testing.T) {
+	const signed = "https://example.com/api?sig=AbC%2Fd%3D%3D&b=1&&a=x+y#frag"
+	tests := []struct {
+		name    string
+		actions []rulespec.Action
+		wantURL string
+	}{
+		{
+			name:    "非查询行为不改动 URL",
+			actions: []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-Test", Value: "1"}},
+			wantURL: signed,
+		},
+		{
+			name:    "仅重新编码被修改的参数",
+			actions: []rulespec.Action{{Type: rulespec.ActionSetQueryParam, Name: "b", Value: "2 3"}},
+			wantURL: "https://example.com/api?sig=AbC%2Fd%3D%3D&b=2+3&a=x+y#frag",
+		},
+		{
+			name:    "移除参数",
+			actions: []rulespec.Action{{Type: rulespec.ActionRemoveQueryParam, Name: "a"}},
+			wantURL: "https://example.com/api?sig=AbC%2Fd%3D%3D&b=1#frag",
+		},
+		{
+			name: "setUrl 后的查询参数基于新 URL",
+			actions: []rulespec.Action{
+				{Type: rulespec.ActionSetUrl, Value: "https://new.com/x?k=%7E&z=1"},
+				{Type: rulespec.ActionSetQueryParam, Name: "z", Value: "2"},
+			},
+			wantURL: "https://new.com/x?k=%7E&z=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tracker.New(5*time.Second, logger.NewNop())
+			defer tr.Stop()
+
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match: rulespec.Match{AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionURLContains, Value: "example.com"},
+				}},
+				Actions: tt.actions,
+			}}
+			eng := engine.New(cfg)
+
+			events := make(chan domain.NetworkEvent, 10)
+			trafficChan := make(chan domain.NetworkEvent, 10)
+			matchedAud := auditor.New(events, logger.NewNop())
+			trafficAud := auditor.New(trafficChan, logger.NewNop())
+			p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+			req := domain.NewRequest()
+			req.ID = "req1"
+			req.URL = signed
+			req.Method = "GET"
+			req.Query = transformer.ParseQuery(signed)
+
+			p.ProcessRequest(context.Background(), req)
+			if req.URL != tt.wantURL {
+				t.Errorf("got url %s, want %s", req.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestProcessRequest_FormActions(t *testing.T) {
+	const ct = "multipart/form-data; boundary=XYZ"
+	body := "--XYZ\r\n" +
+		"Content-Disposition: form-data; name=\"user\"\r\n\r\nalice\r\n" +
+		"--XYZ\r\n" +
+		"Content-Disposition: form-data; name=\"file\"; filename=\"a.txt\"\r\nContent-Type: text/plain\r\n\r\nold\r\n" +
+		"--XYZ--\r\n"
+
+	tests := []struct {
+		name       string
+		actions    []rulespec.Action
+		wantBody   []string
+		wantSameCT bool
+	}{
+		{
+			name: "替换文件内容、文件名与类型",
+			actions: []rulespec.Action{{
+				Type: rulespec.ActionSetFormFile, Name: "file", Value: "bmV3", Encoding: rulespec.BodyEncodingBase64,
+				FileName: "b.json", ContentType: "application/json",
+			}},
+			wantBody: []string{
+				"Content-Disposition: form-data; name=\"file\"; filename=\"b.json\"\r\nContent-Type: application/json\r\n\r\nnew\r\n",
+				"name=\"user\"\r\n\r\nalice\r\n",
+			},
+			wantSameCT: true,
+		},
+		{
+			name: "字段内容包含分隔符时更新 Content-Type",
+			actions: []rulespec.Action{
+				{Type: rulespec.ActionSetFormField, Name: "user", Value: "x\r\n--XYZ\r\ny"},
+				{Type: rulespec.ActionRemoveFormField, Name: "file"},
+			},
+			wantBody: []string{"x\r\n--XYZ\r\ny"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tracker.New(5*time.Second, logger.NewNop())
+			defer tr.Stop()
+
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match: rulespec.Match{AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionFormFieldExists, Name: "user"},
+				}},
+				Actions: tt.actions,
+			}}
+			eng := engine.New(cfg)
+
+			events := make(chan domain.NetworkEvent, 10)
+			trafficChan := make(chan domain.NetworkEvent, 10)
+			matchedAud := auditor.New(events, logger.NewNop())
+			trafficAud := auditor.New(trafficChan, logger.NewNop())
+			p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+			req := domain.NewRequest()
+			req.ID = "req1"
+			req.URL = "https://example.com/upload"
+			req.Method = "POST"
+			req.Headers.Set("Content-Type", ct)
+			req.Body = []byte(body)
+
+			p.ProcessRequest(context.Background(), req)
+
+			gotCT := req.Headers.Get("Content-Type")
+			if (gotCT == ct) != tt.wantSameCT {
+				t.Errorf("unexpected Content-Type %s", gotCT)
+			}
+			form, err := transformer.ParseForm(gotCT, req.Body)
+			if err != nil {
+				t.Fatalf("body no longer parses with Content-Type %s: %v", gotCT, err)
+			}
+			if tt.wantSameCT != form.Has("file") {
+				t.Errorf("unexpected file field presence")
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(string(req.Body), want) {
+					t.Errorf("body %q does not contain %q", req.Body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRequest_GraphQL(t *testing.T) {
+	const batch = `[{"operationName":"Feed","query":"query Feed { feed }"},{"operationName":"Like","query":"mutation Like($id: ID!) { like(id: $id) }","variables":{"id":1}}]`
+	tests := []struct {
+		name       string
+		body       string
+		actions    []rulespec.Action
+		wantAction processor.Action
+		wantBody   string
+	}{
+		{
+			name: "修改指定操作的变量",
+			body: batch,
+			actions: []rulespec.Action{{
+				Type: rulespec.ActionPatchGraphQLVariables, OperationName: "Like",
+				Patches: []rulespec.JSONPatchOp{{Op: "replace", Path: "/id", Value: 2}},
+			}},
+			wantAction: processor.ActionModify,
+			wantBody:   `[{"operationName":"Feed","query":"query Feed { feed }"},{"operationName":"Like","query":"mutation Like($id: ID!) { like(id: $id) }","variables":{"id":2}}]`,
+		},
+		{
+			name:       "请求阶段伪造单个操作",
+			body:       `{"operationName":"Like","query":"mutation Like { like }"}`,
+			actions:    []rulespec.Action{{Type: rulespec.ActionMockGraphQL, OperationName: "Like", Errors: "rate limited"}},
+			wantAction: processor.ActionBlock,
+			wantBody:   `{"data":null,"errors":[{"message":"rate limited"}]}`,
+		},
+		{
+			name:       "批量请求部分命中时不拦截",
+			body:       batch,
+			actions:    []rulespec.Action{{Type: rulespec.ActionMockGraphQL, OperationName: "Like", Data: map[string]any{"like": true}}},
+			wantAction: processor.ActionPass,
+			wantBody:   batch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tracker.New(5*time.Second, logger.NewNop())
+			defer tr.Stop()
+
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match: rulespec.Match{AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionGraphQLOperationName, Values: []string{"Like"}},
+				}},
+				Actions: tt.actions,
+			}}
+			eng := engine.New(cfg)
+
+			events := make(chan domain.NetworkEvent, 10)
+			trafficChan := make(chan domain.NetworkEvent, 10)
+			matchedAud := auditor.New(events, logger.NewNop())
+			trafficAud := auditor.New(trafficChan, logger.NewNop())
+			p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+			req := domain.NewRequest()
+			req.ID = "req1"
+			req.URL = "https://example.com/graphql"
+			req.Method = "POST"
+			req.Body = []byte(tt.body)
+
+			result := p.ProcessRequest(context.Background(), req)
+			if result.Action != tt.wantAction {
+				t.Fatalf("got action %v, want %v", result.Action, tt.wantAction)
+			}
+			got := string(req.Body)
+			if result.Action == processor.ActionBlock {
+				got = string(result.MockRes.Body)
+				if ct := result.MockRes.Headers.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+					t.Errorf("got Content-Type %q", ct)
+				}
+			}
+			if got != tt.wantBody {
+				t.Errorf("got body %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProcessResponse_MockGraphQL(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID:      "rule1",
+		Enabled: true,
+		Stage:   rulespec.StageResponse,
+		Match: rulespec.Match{AllOf: []rulespec.Condition{
+			{Type: rulespec.ConditionGraphQLOperationType, Values: []string{"mutation"}},
+		}},
+		Actions: []rulespec.Action{{Type: rulespec.ActionMockGraphQL, OperationName: "Like", Data: map[string]any{"like": true}}},
+	}}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	matchedAud := auditor.New(events, logger.NewNop())
+	trafficAud := auditor.New(trafficChan, logger.NewNop())
+	p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+	req := domain.NewRequest()
+	req.ID = "req1"
+	req.URL = "https://example.com/graphql"
+	req.Method = "POST"
+	req.Body = []byte(`[{"operationName":"Feed","query":"query Feed { feed }"},{"operationName":"Like","query":"mutation Like { like }"}]`)
+	tr.Set("req1", &processor.PendingState{Request: req})
+
+	res := domain.NewResponse()
+	res.StatusCode = 200
+	res.Body = []byte(`[{"data":{"feed":[]}},{"data":null,"errors":[{"message":"denied"}]}]`)
+
+	result := p.ProcessResponse(context.Background(), "req1", res)
+	if result.Action != processor.ActionModify {
+		t.Fatalf("got action %v, want %v", result.Action, processor.ActionModify)
+	}
+	want := `[{"data":{"feed":[]}},{"data":{"like":true}}]`
+	if string(res.Body) != want {
+		t.Errorf("got body %s, want %s", res.Body, want)
+	}
+}
+
+func TestProcessRequest_TemplateActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []rulespec.Action
+		check   func(t *testing.T, req *domain.Request, result processor.Result)
+	}{
+		{
+			name: "重定向复用路径段与捕获组",
+			actions: []rulespec.Action{
+				{Type: rulespec.ActionSetUrl, Value: "https://new.example.com/v2/{{.Segment 0}}/{{.Capture \"id\"}}?from={{.Method | urlencode}}", Template: true},
+			},
+			check: func(t *testing.T, req *domain.Request, _ processor.Result) {
+				if want := "https://new.example.com/v2/users/42?from=GET"; req.URL != want {
+					t.Errorf("got url %s, want %s", req.URL, want)
+				}
+			},
+		},
+		{
+			name: "Mock 回显请求 ID",
+			actions: []rulespec.Action{{
+				Type: rulespec.ActionBlock, StatusCode: 200, Template: true,
+				Headers: map[string]string{"X-Request-Id": "{{.Header \"X-Request-Id\"}}"},
+				Body:    `{"requestId":"{{.Header "X-Request-Id"}}","user":{{.Capture 1}}}`,
+			}},
+			check: func(t *testing.T, _ *domain.Request, result processor.Result) {
+				if result.Action != processor.ActionBlock {
+					t.Fatalf("got action %v, want block", result.Action)
+				}
+				if got := string(result.MockRes.Body); got != `{"requestId":"rid-9","user":42}` {
+					t.Errorf("got body %s", got)
+				}
+				if got := result.MockRes.Headers.Get("X-Request-Id"); got != "rid-9" {
+					t.Errorf("got header %s", got)
+				}
+			},
+		},
+		{
+			name:    "未启用模板时原样使用",
+			actions: []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-Raw", Value: "{{.ID}}"}},
+			check: func(t *testing.T, req *domain.Request, _ processor.Result) {
+				if got := req.Headers.Get("X-Raw"); got != "{{.ID}}" {
+					t.Errorf("got header %s", got)
+				}
+			},
+		},
+		{
+			name: "模板错误时跳过该行为",
+			actions: []rulespec.Action{
+				{Type: rulespec.ActionSetHeader, Name: "X-Bad", Value: "{{.Nope}}", Template: true},
+				{Type: rulespec.ActionSetHeader, Name: "X-Id", Value: "{{.ID}}", Template: true},
+			},
+			check: func(t *testing.T, req *domain.Request, _ processor.Result) {
+				if req.Headers.Has("X-Bad") || req.Headers.Get("X-Id") != "req1" {
+					t.Errorf("unexpected headers %v", req.Headers)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tracker.New(5*time.Second, logger.NewNop())
+			defer tr.Stop()
+
+			cfg := rulespec.NewConfig("test")
+			cfg.Rules = []rulespec.Rule{{
+				ID:      "rule1",
+				Enabled: true,
+				Stage:   rulespec.StageRequest,
+				Match: rulespec.Match{AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionURLRegex, Pattern: `/users/(?P<id>\d+)`},
+				}},
+				Actions: tt.actions,
+			}}
+			eng := engine.New(cfg)
+
+			events := make(chan domain.NetworkEvent, 10)
+			trafficChan := make(chan domain.NetworkEvent, 10)
+			matchedAud := auditor.New(events, logger.NewNop())
+			trafficAud := auditor.New(trafficChan, logger.NewNop())
+			p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+			req := domain.NewRequest()
+			req.ID = "req1"
+			req.URL = "https://example.com/users/42"
+			req.Method = "GET"
+			req.Headers.Set("X-Request-Id", "rid-9")
+
+			result := p.ProcessRequest(context.Background(), req)
+			tt.check(t, req, result)
+		})
+	}
+}
+
+func TestProcess_StatefulScenario(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	loggedIn := rulespec.Condition{Type: rulespec.ConditionScenarioState, Name: "auth", Values: []string{"LoggedIn"}}
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "login",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/login"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionExtractVariable, Name: "token", Path: "$.data.token"},
+				{Type: rulespec.ActionSetScenarioState, Name: "auth", Value: "LoggedIn"},
+			},
+		},
+		{
+			ID:      "inject",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLContains, Value: "/api/"},
+				loggedIn,
+			}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionSetHeader, Name: "Authorization", Value: `Bearer {{.Var "token"}}`, Template: true},
+				{Type: rulespec.ActionSetVariable, Name: "lastPath", Value: "{{.Path}}", Template: true},
+			},
+		},
+		{
+			ID:      "unauthorized",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf:  []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/api/"}},
+				NoneOf: []rulespec.Condition{loggedIn},
+			},
+			Actions: []rulespec.Action{{Type: rulespec.ActionBlock, StatusCode: 401}},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	matchedAud := auditor.New(events, logger.NewNop())
+	trafficAud := auditor.New(trafficChan, logger.NewNop())
+	p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+	newReq := func(id, url string) *domain.Request {
+		req := domain.NewRequest()
+		req.ID = id
+		req.URL = url
+		req.Method = "GET"
+		return req
+	}
+
+	// 登录前访问接口被拦截
+	if result := p.ProcessRequest(context.Background(), newReq("r1", "https://example.com/api/profile")); result.Action != processor.ActionBlock || result.MockRes.StatusCode != 401 {
+		t.Fatalf("before login: got %+v, want 401 block", result)
+	}
+
+	// 登录响应中提取 token 并切换场景状态，仅读写状态的规则不视为修改响应
+	login := newReq("r2", "https://example.com/login")
+	p.ProcessRequest(context.Background(), login)
+	res := domain.NewResponse()
+	res.StatusCode = 200
+	res.Body = []byte(`{"data":{"token":"t-1"}}`)
+	if result := p.ProcessResponse(context.Background(), "r2", res); result.Action != processor.ActionPass {
+		t.Errorf("state-only response rule: got action %v, want pass", result.Action)
+	}
+
+	// 登录后请求注入 token
+	req := newReq("r3", "https://example.com/api/profile")
+	if result := p.ProcessRequest(context.Background(), req); result.Action != processor.ActionModify {
+		t.Fatalf("after login: got action %v, want modify", result.Action)
+	}
+	if got := req.Headers.Get("Authorization"); got != "Bearer t-1" {
+		t.Errorf("got Authorization %q", got)
+	}
+	state := eng.GetState()
+	if state.Variables["lastPath"] != "/api/profile" || state.Scenarios["auth"] != "LoggedIn" {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	// 响应中缺少 token 时计为行为错误，保留原变量
+	p.ProcessRequest(context.Background(), newReq("r4", "https://example.com/login"))
+	res = domain.NewResponse()
+	res.Body = []byte(`{"error":"expired"}`)
+	p.ProcessResponse(context.Background(), "r4", res)
+	if v, _ := eng.Variable("token"); v != "t-1" {
+		t.Errorf("token overwritten: %q", v)
+	}
+	if stats := eng.GetStats(); stats.Rules["login"].ActionErrors != 1 {
+		t.Errorf("got %d action errors, want 1", stats.Rules["login"].ActionErrors)
+	}
+
+	// 重置后回到初始状态
+	eng.ResetState()
+	if result := p.ProcessRequest(context.Background(), newReq("r5", "https://example.com/api/profile")); result.Action != processor.ActionBlock {
+		t.Errorf("after reset: got action %v, want block", result.Action)
+	}
+}
+
+func TestProcess_DelayAndThrottle(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "slow",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/slow"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionDelay, DelayMS: 100},
+				{Type: rulespec.ActionDelay, DelayMS: 200, DelayMaxMS: 300},
+				{Type: rulespec.ActionThrottle, BytesPerSecond: 1000},
+			},
+		},
+		{
+			ID:      "mock",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/mock"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionThrottle, BytesPerSecond: 10},
+				{Type: rulespec.ActionBlock, StatusCode: 200, Body: "0123456789"},
+			},
+		},
+		{
+			ID:      "slowResponse",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/slow"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionDelay, DelayMS: 50},
+				{Type: rulespec.ActionThrottle, BytesPerSecond: 2000},
+			},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	p := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+	// 请求阶段只累加延迟，不修改请求
+	req := domain.NewRequest()
+	req.ID = "r1"
+	req.URL = "https://example.com/slow"
+	result := p.ProcessRequest(context.Background(), req)
+	if result.Action != processor.ActionPass {
+		t.Errorf("got action %v, want pass", result.Action)
+	}
+	if result.Delay < 300*time.Millisecond || result.Delay > 400*time.Millisecond {
+		t.Errorf("got delay %v, want within [300ms, 400ms]", result.Delay)
+	}
+	if result.Wait() != result.Delay {
+		t.Errorf("throttle should not delay a request without body: wait %v, delay %v", result.Wait(), result.Delay)
+	}
+
+	// 响应阶段延迟单独计算，限速取两阶段中较小的带宽并按原响应体返回
+	res := domain.NewResponse()
+	res.StatusCode = 200
+	res.Body = make([]byte, 500)
+	result = p.ProcessResponse(context.Background(), "r1", res)
+	if result.Action != processor.ActionModify || result.ModifiedRes != res {
+		t.Fatalf("throttled response should be fulfilled as is, got %+v", result)
+	}
+	if result.Throttle != 1000 || result.Delay != 50*time.Millisecond {
+		t.Errorf("got throttle %d delay %v", result.Throttle, result.Delay)
+	}
+	if got := result.Wait(); got != 550*time.Millisecond {
+		t.Errorf("got wait %v, want 550ms", got)
+	}
+
+	// 伪造响应按带宽推迟返回
+	req = domain.NewRequest()
+	req.ID = "r2"
+	req.URL = "https://example.com/mock"
+	result = p.ProcessRequest(context.Background(), req)
+	if result.Action != processor.ActionBlock || result.Wait() != time.Second {
+		t.Errorf("got action %v wait %v, want block after 1s", result.Action, result.Wait())
+	}
+}
+
 func TestProcessResponse_NoMatch(t *testing.T) {
 	tr := tracker.New(5*time.Second, logger.NewNop())
 	defer tr.Stop()
@@ -264,7 +1059,7 @@ func TestProcessResponse_ModifyStatus(t *testing.T) {
 
 	res := &domain.Response{
 		StatusCode: 404,
-		Headers:    make(domain.Header),
+		Headers:    domain.Header{},
 	}
 
 	result := p.ProcessResponse(context.Background(), "req1", res)
@@ -320,7 +1115,7 @@ func TestProcessResponse_ModifyHeader(t *testing.T) {
 
 	res := &domain.Response{
 		StatusCode: 200,
-		Headers:    make(domain.Header),
+		Headers:    domain.Header{},
 	}
 
 	result := p.ProcessResponse(context.Background(), "req1", res)
@@ -332,6 +1127,516 @@ func TestProcessResponse_ModifyHeader(t *testing.T) {
 	}
 }
 
+func TestProcessResponse_EncodedBody(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID:      "rule1",
+		Enabled: true,
+		Stage:   rulespec.StageResponse,
+		Match: rulespec.Match{AllOf: []rulespec.Condition{
+			{Type: rulespec.ConditionURLContains, Value: "/legacy"},
+			{Type: rulespec.ConditionResponseBodyContains, Value: "旧标题"},
+		}},
+		Actions: []rulespec.Action{
+			{Type: rulespec.ActionReplaceBodyText, Search: "旧标题", Replace: "新标题"},
+		},
+	}}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	p := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+	gbk := func(s string) []byte {
+		out, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+		return out
+	}
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write(b)
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	newResponse := func(body []byte) *domain.Response {
+		res := domain.NewResponse()
+		res.StatusCode = 200
+		res.Headers.Set("Content-Type", "text/html; charset=GBK")
+		res.Headers.Set("Content-Encoding", "gzip")
+		res.Headers.Set("Content-Length", strconv.Itoa(len(body)))
+		res.Body = body
+		return res
+	}
+
+	// 解码后匹配与替换，按 GBK 写回并修正头部
+	tr.Set("r1", &processor.PendingState{Request: &domain.Request{ID: "r1", URL: "https://example.com/legacy"}})
+	res := newResponse(gzipped(gbk("<title>旧标题</title>")))
+	result := p.ProcessResponse(context.Background(), "r1", res)
+	if result.Action != processor.ActionModify {
+		t.Fatalf("got action %v, want modify", result.Action)
+	}
+	want := gbk("<title>新标题</title>")
+	if !bytes.Equal(res.Body, want) {
+		t.Errorf("got body %q, want %q", res.Body, want)
+	}
+	if res.Headers.Has("Content-Encoding") || res.Headers.Get("Content-Length") != strconv.Itoa(len(want)) {
+		t.Errorf("unexpected headers %v", res.Headers)
+	}
+
+	// 未修改的响应保持原始内容
+	tr.Set("r2", &processor.PendingState{Request: &domain.Request{ID: "r2", URL: "https://example.com/legacy"}})
+	original := gzipped(gbk("<title>其他</title>"))
+	res = newResponse(original)
+	result = p.ProcessResponse(context.Background(), "r2", res)
+	if result.Action != processor.ActionPass || !bytes.Equal(res.Body, original) {
+		t.Errorf("unmodified response should keep raw body, got %v %q", result.Action, res.Body)
+	}
+
+	// 没有规则可能命中的响应不解码，流量记录保留原始响应体
+	tr.Set("r3", &processor.PendingState{Request: &domain.Request{ID: "r3", URL: "https://example.com/other"}})
+	res = newResponse(original)
+	result = p.ProcessResponse(context.Background(), "r3", res)
+	if result.Action != processor.ActionPass || !bytes.Equal(res.Body, original) {
+		t.Errorf("unmatched response should pass untouched, got %v %q", result.Action, res.Body)
+	}
+	var last domain.NetworkEvent
+	for len(trafficChan) > 0 {
+		last = <-trafficChan
+	}
+	if last.Request.ID != "r3" || last.Response == nil || !bytes.Equal(last.Response.Body, original) {
+		t.Errorf("unmatched response should be recorded without decoding, got %+v", last.Response)
+	}
+}
+
+func TestProcessResponse_BodyOmitted(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "header",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/video"}}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionSetHeader, Name: "Cache-Control", Value: "no-store"},
+				{Type: rulespec.ActionReplaceBodyText, Search: "a", Replace: "b"},
+			},
+		},
+		{
+			ID:      "replace",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/page"}}},
+			Actions: []rulespec.Action{{Type: rulespec.ActionReplaceBodyText, Search: "a", Replace: "b"}},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	p := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+	tr.Set("r1", &processor.PendingState{Request: &domain.Request{ID: "r1", URL: "https://example.com/video"}})
+	tr.Set("r2", &processor.PendingState{Request: &domain.Request{ID: "r2", URL: "https://example.com/page"}})
+	tr.Set("r3", &processor.PendingState{Request: &domain.Request{ID: "r3", URL: "https://example.com/other"}, Throttle: 100})
+
+	res := &domain.Response{StatusCode: 200, Headers: domain.Header{}, BodyOmitted: true}
+	for id, want := range map[string]bool{"r1": true, "r2": true, "r3": true, "unknown": false} {
+		if got := p.NeedsResponseBody(id, res); got != want {
+			t.Errorf("NeedsResponseBody(%s) = %v, want %v", id, got, want)
+		}
+	}
+
+	// 未读取响应体时只应用头部修改，读取响应体的行为记为错误
+	result := p.ProcessResponse(context.Background(), "r1", res)
+	if result.Action != processor.ActionModify || !result.ModifiedRes.BodyOmitted || len(result.ModifiedRes.Body) != 0 {
+		t.Fatalf("got %+v, want header-only modification", result)
+	}
+	if res.Headers.Get("Cache-Control") != "no-store" {
+		t.Errorf("header not applied: %v", res.Headers)
+	}
+	if stats := eng.GetStats(); stats.Rules["header"].ActionErrors != 1 {
+		t.Errorf("got %d action errors, want 1", stats.Rules["header"].ActionErrors)
+	}
+}
+
+func TestResponseBodyPlan(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{{
+		ID:      "replace",
+		Enabled: true,
+		Stage:   rulespec.StageResponse,
+		Match:   rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/page"}}},
+		Actions: []rulespec.Action{{Type: rulespec.ActionReplaceBodyText, Search: "a", Replace: "b"}},
+	}}
+	eng := engine.New(cfg)
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	capture := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+	noCapture := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.NewDisabled(trafficChan, logger.NewNop()), logger.NewNop())
+
+	tr.Set("rule", &processor.PendingState{Request: &domain.Request{ID: "rule", URL: "https://example.com/page"}})
+	tr.Set("other", &processor.PendingState{Request: &domain.Request{ID: "other", URL: "https://example.com/other"}})
+
+	const threshold = 1024
+	tests := []struct {
+		name    string
+		p       *processor.Processor
+		id      string
+		headers []string
+		media   bool
+		want    processor.BodyPlan
+	}{
+		{"规则需要且缺少 Content-Length 时一次性读取", capture, "rule", []string{"Content-Type", "text/html", "Transfer-Encoding", "chunked"}, false, processor.BodyGet},
+		{"规则需要且未超过阈值", capture, "rule", []string{"Content-Length", "100"}, false, processor.BodyGet},
+		{"规则需要且超过阈值时通过流读取", capture, "rule", []string{"Content-Length", "4096"}, false, processor.BodyStream},
+		{"长度未知的音视频不读取", capture, "rule", []string{"Content-Type", "video/mp4"}, true, processor.BodySkip},
+		{"未超过阈值的音视频按规则读取", capture, "rule", []string{"Content-Length", "100"}, true, processor.BodyGet},
+		{"仅流量捕获且长度未知的文本", capture, "other", []string{"Content-Type", "application/json"}, false, processor.BodyCapture},
+		{"仅流量捕获且长度未知的二进制", capture, "other", []string{"Content-Type", "image/png"}, false, processor.BodySkip},
+		{"仅流量捕获且超过阈值", capture, "other", []string{"Content-Type", "application/json", "Content-Length", "4096"}, false, processor.BodySkip},
+		{"仅流量捕获且未超过阈值的二进制", capture, "other", []string{"Content-Type", "image/png", "Content-Length", "100"}, false, processor.BodyCapture},
+		{"规则与流量捕获都不需要", noCapture, "other", []string{"Content-Type", "application/json"}, false, processor.BodySkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &domain.Response{StatusCode: 200}
+			for i := 0; i+1 < len(tt.headers); i += 2 {
+				res.Headers.Set(tt.headers[i], tt.headers[i+1])
+			}
+			if got := tt.p.ResponseBodyPlan(tt.id, res, tt.media, threshold); got != tt.want {
+				t.Errorf("ResponseBodyPlan = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessResponse_StatusCondition(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	matchedAud := auditor.New(events, logger.NewNop())
+	trafficAud := auditor.New(trafficChan, logger.NewNop())
+	p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+	rule := rulespec.Rule{
+		ID:      "rule1",
+		Name:    "patch error response",
+		Enabled: true,
+		Match: rulespec.Match{
+			AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionStatusCodeRange, Value: "500-599"},
+			},
+		},
+		Actions: []rulespec.Action{
+			{Type: rulespec.ActionSetStatus, Value: 200},
+		},
+		Stage: rulespec.StageResponse,
+	}
+	cfg.Rules = []rulespec.Rule{rule}
+	eng.Update(cfg)
+
+	tests := []struct {
+		status     int
+		wantAction processor.Action
+		wantStatus int
+	}{
+		{500, processor.ActionModify, 200},
+		{404, processor.ActionPass, 404},
+	}
+	for _, tt := range tests {
+		req := &domain.Request{ID: "req1", URL: "https://example.com/test", Method: "GET"}
+		tr.Set("req1", &processor.PendingState{Request: req})
+		res := &domain.Response{StatusCode: tt.status, Headers: domain.Header{}}
+
+		result := p.ProcessResponse(context.Background(), "req1", res)
+		if result.Action != tt.wantAction {
+			t.Errorf("status %d: got action %v, want %v", tt.status, result.Action, tt.wantAction)
+		}
+		if res.StatusCode != tt.wantStatus {
+			t.Errorf("status %d: got status %v, want %v", tt.status, res.StatusCode, tt.wantStatus)
+		}
+	}
+}
+
+func TestProcessRequest_FlowControl(t *testing.T) {
+	setHeader := func(id string, priority int, group string, stop bool) rulespec.Rule {
+		return rulespec.Rule{
+			ID:       id,
+			Enabled:  true,
+			Priority: priority,
+			Stage:    rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionURLContains, Value: "example.com"},
+				},
+			},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionSetHeader, Name: "X-" + id, Value: "1"},
+			},
+			Group:          group,
+			StopAfterMatch: stop,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		groups  []rulespec.RuleGroup
+		rules   []rulespec.Rule
+		applied []string
+		skipped []string
+	}{
+		{
+			name:    "默认执行所有匹配规则",
+			rules:   []rulespec.Rule{setHeader("a", 10, "", false), setHeader("b", 5, "", false)},
+			applied: []string{"a", "b"},
+		},
+		{
+			name:    "stopAfterMatch 阻止低优先级规则",
+			rules:   []rulespec.Rule{setHeader("a", 10, "", true), setHeader("b", 5, "", false)},
+			applied: []string{"a"},
+			skipped: []string{"b"},
+		},
+		{
+			name:    "低优先级的 stopAfterMatch 不影响已执行规则",
+			rules:   []rulespec.Rule{setHeader("a", 10, "", false), setHeader("b", 5, "", true), setHeader("c", 1, "", false)},
+			applied: []string{"a", "b"},
+			skipped: []string{"c"},
+		},
+		{
+			name:    "first 分组仅执行第一条",
+			groups:  []rulespec.RuleGroup{{Name: "mock", Mode: rulespec.GroupModeFirst}},
+			rules:   []rulespec.Rule{setHeader("a", 10, "mock", false), setHeader("b", 20, "mock", false), setHeader("c", 1, "", false)},
+			applied: []string{"b", "c"},
+			skipped: []string{"a"},
+		},
+		{
+			name:    "all 分组执行所有规则",
+			groups:  []rulespec.RuleGroup{{Name: "mock", Mode: rulespec.GroupModeAll}},
+			rules:   []rulespec.Rule{setHeader("a", 10, "mock", false), setHeader("b", 5, "mock", false)},
+			applied: []string{"a", "b"},
+		},
+		{
+			name:    "未声明的分组按 all 处理",
+			rules:   []rulespec.Rule{setHeader("a", 10, "unknown", false), setHeader("b", 5, "unknown", false)},
+			applied: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tracker.New(5*time.Second, logger.NewNop())
+			defer tr.Stop()
+
+			cfg := rulespec.NewConfig("test")
+			cfg.Groups = tt.groups
+			cfg.Rules = tt.rules
+			eng := engine.New(cfg)
+
+			events := make(chan domain.NetworkEvent, 10)
+			trafficChan := make(chan domain.NetworkEvent, 10)
+			matchedAud := auditor.New(events, logger.NewNop())
+			trafficAud := auditor.New(trafficChan, logger.NewNop())
+			p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+			req := &domain.Request{
+				ID:      "req1",
+				URL:     "https://example.com/test",
+				Method:  "GET",
+				Headers: domain.Header{},
+			}
+			result := p.ProcessRequest(context.Background(), req)
+			if result.Action != processor.ActionModify {
+				t.Fatalf("got action %v, want %v", result.Action, processor.ActionModify)
+			}
+			for _, id := range tt.applied {
+				if result.ModifiedReq.Headers.Get("X-"+id) == "" {
+					t.Errorf("rule %s should be applied", id)
+				}
+			}
+			for _, id := range tt.skipped {
+				if result.ModifiedReq.Headers.Get("X-"+id) != "" {
+					t.Errorf("rule %s should be skipped", id)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRequest_HitLimitFallback(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Groups = []rulespec.RuleGroup{{Name: "retry", Mode: rulespec.GroupModeFirst}}
+	match := rulespec.Match{AllOf: []rulespec.Condition{
+		{Type: rulespec.ConditionURLContains, Value: "example.com"},
+	}}
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:       "fail",
+			Enabled:  true,
+			Priority: 10,
+			Stage:    rulespec.StageRequest,
+			Group:    "retry",
+			Limit:    &rulespec.RuleLimit{MaxHits: 2},
+			Match:    match,
+			Actions:  []rulespec.Action{{Type: rulespec.ActionBlock, StatusCode: 503}},
+		},
+		{
+			ID:      "pass",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Group:   "retry",
+			Match:   match,
+			Actions: []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-Retry", Value: "ok"}},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	matchedAud := auditor.New(events, logger.NewNop())
+	trafficAud := auditor.New(trafficChan, logger.NewNop())
+	p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+	want := []processor.Action{processor.ActionBlock, processor.ActionBlock, processor.ActionModify}
+	for i, w := range want {
+		req := &domain.Request{
+			ID:      "req1",
+			URL:     "https://example.com/api",
+			Method:  "GET",
+			Headers: domain.Header{},
+		}
+		result := p.ProcessRequest(context.Background(), req)
+		if result.Action != w {
+			t.Errorf("attempt %d: got action %v, want %v", i+1, result.Action, w)
+		}
+	}
+}
+
+func TestProcessRequest_ActionErrorStats(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "broken",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{AllOf: []rulespec.Condition{
+				{Type: rulespec.ConditionURLContains, Value: "example.com"},
+			}},
+			Actions: []rulespec.Action{
+				{Type: rulespec.ActionSetBody, Value: "not base64!", Encoding: rulespec.BodyEncodingBase64},
+				{Type: rulespec.ActionSetHeader, Name: "X-Test", Value: "ok"},
+			},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	matchedAud := auditor.New(events, logger.NewNop())
+	trafficAud := auditor.New(trafficChan, logger.NewNop())
+	p := processor.New(tr, eng, matchedAud, trafficAud, logger.NewNop())
+
+	req := &domain.Request{
+		ID:      "req1",
+		URL:     "https://example.com/api",
+		Method:  "POST",
+		Headers: domain.Header{},
+	}
+	result := p.ProcessRequest(context.Background(), req)
+	if result.Action != processor.ActionModify {
+		t.Fatalf("got action %v, want %v", result.Action, processor.ActionModify)
+	}
+	if req.Headers.Get("X-Test") != "ok" {
+		t.Error("later actions should still run after a failed action")
+	}
+
+	stats := eng.GetStats()
+	rs := stats.Rules["broken"]
+	if rs.Hits != 1 {
+		t.Errorf("got hits %d, want 1", rs.Hits)
+	}
+	if rs.ActionErrors != 1 {
+		t.Errorf("got action errors %d, want 1", rs.ActionErrors)
+	}
+	if rs.LastError == "" {
+		t.Error("last error should be recorded")
+	}
+	if stats.ByStage["request"].Total != 1 {
+		t.Errorf("got request stage total %d, want 1", stats.ByStage["request"].Total)
+	}
+}
+
+func TestProcessRequest_TerminalActionStats(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	match := rulespec.Match{AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "example.com"}}}
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:       "block",
+			Enabled:  true,
+			Priority: 10,
+			Stage:    rulespec.StageRequest,
+			Match:    match,
+			Actions:  []rulespec.Action{{Type: rulespec.ActionBlock, StatusCode: 403}},
+		},
+		{
+			ID:       "header",
+			Enabled:  true,
+			Priority: 1,
+			Stage:    rulespec.StageRequest,
+			Match:    match,
+			Actions:  []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-Test", Value: "1"}},
+		},
+	}
+	eng := engine.New(cfg)
+
+	events := make(chan domain.NetworkEvent, 10)
+	trafficChan := make(chan domain.NetworkEvent, 10)
+	p := processor.New(tr, eng, auditor.New(events, logger.NewNop()), auditor.New(trafficChan, logger.NewNop()), logger.NewNop())
+
+	req := domain.NewRequest()
+	req.ID = "req1"
+	req.URL = "https://example.com/api"
+	result := p.ProcessRequest(context.Background(), req)
+	if result.Action != processor.ActionBlock || len(result.Rules) != 1 {
+		t.Fatalf("got action %v with %d rules, want block with 1", result.Action, len(result.Rules))
+	}
+
+	stats := eng.GetStats()
+	if got := stats.Rules["block"].Hits; got != 1 {
+		t.Errorf("block rule hits = %d, want 1", got)
+	}
+	if got := stats.Rules["header"].Hits; got != 0 {
+		t.Errorf("rule after the terminal action hits = %d, want 0", got)
+	}
+	for _, ch := range []chan domain.NetworkEvent{events, trafficChan} {
+		if evt := <-ch; len(evt.MatchedRules) != 1 || evt.MatchedRules[0].RuleID != "block" {
+			t.Errorf("audited rules = %+v, want only block", evt.MatchedRules)
+		}
+	}
+}
+
 func TestPendingState_IsMatched(t *testing.T) {
 	tests := []struct {
 		name  string
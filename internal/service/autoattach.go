@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+
+	"cdpnetool/internal/adapter/cdp"
+	"cdpnetool/pkg/domain"
+)
+
+// SetAutoAttach 设置指定会话自动附着新页面的选项
+// 开启时建立浏览器级连接，新建的标签页、弹窗与窗口在发出首个请求前按当前拦截状态附着；
+// 关闭时停止监听，已自动附着的页面保持附着，可手动断开
+func (o *Orchestrator) SetAutoAttach(ctx context.Context, id domain.SessionID, opts domain.AutoAttachOptions) error {
+	state, ok := o.get(id)
+	if !ok {
+		return domain.ErrSessionNotFound
+	}
+
+	state.mu.Lock()
+	if state.autoAttachCancel != nil {
+		state.autoAttachCancel()
+		state.autoAttachCancel = nil
+	}
+	if !opts.Enabled {
+		state.mu.Unlock()
+		o.log.Info("自动附着新页面已关闭", "sessionID", string(id))
+		return nil
+	}
+	watchCtx, cancel := context.WithCancel(state.ctx)
+	state.autoAttachCancel = cancel
+	state.mu.Unlock()
+
+	err := state.clientMgr.WatchTargets(watchCtx, cdp.TargetHandlers{
+		Attached: func(ev cdp.TargetEvent) {
+			o.autoAttachTarget(state, opts, ev)
+		},
+		Changed: func(ev cdp.TargetEvent) {
+			// 附着时 URL 尚未确定的页面，导航后不满足 URL 模式时断开
+			if o.isAutoAttached(state, ev.ID) && !opts.MatchURL(ev.URL) {
+				o.log.Info("自动附着的页面 URL 不匹配，断开附着", "target", string(ev.ID), "url", ev.URL)
+				if err := o.detachTarget(state, ev.ID); err != nil {
+					o.log.Err(err, "断开自动附着的页面失败", "target", string(ev.ID))
+				}
+			}
+		},
+		Destroyed: func(target domain.TargetID) {
+			if !state.sess.HasTarget(target) {
+				return
+			}
+			if err := o.detachTarget(state, target); err != nil {
+				o.log.Err(err, "断开已关闭的页面失败", "target", string(target))
+			}
+		},
+	})
+	if err != nil {
+		cancel()
+		state.mu.Lock()
+		state.autoAttachCancel = nil
+		state.mu.Unlock()
+		o.log.Err(err, "开启自动附着新页面失败", "sessionID", string(id))
+		return err
+	}
+	o.log.Info("自动附着新页面已开启", "sessionID", string(id), "urlPattern", opts.URLPattern, "openerOnly", opts.OpenerOnly)
+	return nil
+}
+
+// autoAttachTarget 附着满足选项的新页面，页面在返回前保持暂停
+func (o *Orchestrator) autoAttachTarget(state *sessionState, opts domain.AutoAttachOptions, ev cdp.TargetEvent) {
+	if !ev.New || state.sess.HasTarget(ev.ID) {
+		return
+	}
+	if opts.OpenerOnly && (ev.OpenerID == "" || !state.sess.HasTarget(ev.OpenerID)) {
+		return
+	}
+	if !opts.MatchURL(ev.URL) {
+		return
+	}
+
+	if err := o.attachTarget(state.ctx, state, ev.ID); err != nil {
+		o.log.Err(err, "自动附着新页面失败", "target", string(ev.ID), "url", ev.URL)
+		return
+	}
+	state.mu.Lock()
+	state.autoAttached[ev.ID] = struct{}{}
+	state.mu.Unlock()
+	o.log.Info("已自动附着新页面", "target", string(ev.ID), "url", ev.URL, "opener", string(ev.OpenerID))
+}
+
+// isAutoAttached 判断目标是否为自动附着
+func (o *Orchestrator) isAutoAttached(state *sessionState, target domain.TargetID) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	_, ok := state.autoAttached[target]
+	return ok
+}
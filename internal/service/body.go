@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"cdpnetool/internal/adapter/cdp"
+	"cdpnetool/internal/processor"
+	"cdpnetool/pkg/domain"
+
+	"github.com/mafredri/cdp/protocol/fetch"
+	cdpio "github.com/mafredri/cdp/protocol/io"
+	"github.com/mafredri/cdp/protocol/network"
+)
+
+// defaultBodySizeThreshold 未配置 BodySizeThreshold 时的默认阈值，超过时通过 IO 流分块读取响应体
+const defaultBodySizeThreshold = 2 << 20
+
+// streamChunkSize 通过 IO 流读取响应体时单次读取的字节数
+const streamChunkSize = 1 << 20
+
+// loadResponseBody 按 Processor.ResponseBodyPlan 读取响应体，不读取时标记 BodyOmitted
+// streamed 表示响应体已通过流取走，此后请求不能再以 ContinueResponse 放行
+func (o *Orchestrator) loadResponseBody(state *sessionState, ts *cdp.TargetSession, ev *fetch.RequestPausedReply, res *domain.Response) (streamed bool, err error) {
+	id := ev.RequestID
+	threshold := state.cfg.BodySizeThreshold
+	if threshold <= 0 {
+		threshold = defaultBodySizeThreshold
+	}
+
+	switch state.processor.ResponseBodyPlan(string(id), res, isMediaResponse(ev, res), threshold) {
+	case processor.BodyStream:
+		res.Body, streamed, err = o.readResponseBodyStream(state.ctx, ts, id)
+		return streamed, err
+	case processor.BodyGet:
+		res.Body, err = o.getResponseBody(state.ctx, ts, id)
+		return false, err
+	case processor.BodyCapture:
+		body, err := o.getResponseBody(state.ctx, ts, id)
+		if err == nil {
+			res.Body = body
+			return false, nil
+		}
+		o.log.Warn("获取响应体失败，流量记录不含响应体", "requestID", id, "error", err.Error())
+	}
+	res.BodyOmitted = true
+	return false, nil
+}
+
+// getResponseBody 通过 GetResponseBody 一次性读取响应体
+func (o *Orchestrator) getResponseBody(ctx context.Context, ts *cdp.TargetSession, id fetch.RequestID) ([]byte, error) {
+	ctx2, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	rb, err := ts.Client.Fetch.GetResponseBody(ctx2, &fetch.GetResponseBodyArgs{RequestID: id})
+	if err != nil {
+		return nil, err
+	}
+	if !rb.Base64Encoded {
+		return []byte(rb.Body), nil
+	}
+	// 二进制内容以 base64 编码返回，需要解码为原始字节
+	decoded, err := base64.StdEncoding.DecodeString(rb.Body)
+	if err != nil {
+		o.log.Err(err, "解码响应体失败", "requestID", id)
+		return []byte(rb.Body), nil
+	}
+	return decoded, nil
+}
+
+// readResponseBodyStream 通过 takeResponseBodyAsStream 与 IO.read 分块读取响应体，避免单条 CDP 消息过大
+// taken 表示流已取得，即使读取失败请求也无法再原样放行
+func (o *Orchestrator) readResponseBodyStream(ctx context.Context, ts *cdp.TargetSession, id fetch.RequestID) (body []byte, taken bool, err error) {
+	ctx2, cancel := context.WithTimeout(ctx, 3*time.Second)
+	reply, err := ts.Client.Fetch.TakeResponseBodyAsStream(ctx2, &fetch.TakeResponseBodyAsStreamArgs{RequestID: id})
+	cancel()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		ctx3, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := ts.Client.IO.Close(ctx3, &cdpio.CloseArgs{Handle: reply.Stream}); err != nil {
+			o.log.Warn("关闭响应体流失败", "requestID", id, "error", err.Error())
+		}
+	}()
+
+	var buf bytes.Buffer
+	size := streamChunkSize
+	for {
+		ctx3, cancel := context.WithTimeout(ctx, 10*time.Second)
+		chunk, err := ts.Client.IO.Read(ctx3, &cdpio.ReadArgs{Handle: reply.Stream, Size: &size})
+		cancel()
+		if err != nil {
+			return nil, true, err
+		}
+		if chunk.Base64Encoded != nil && *chunk.Base64Encoded {
+			data, err := base64.StdEncoding.DecodeString(chunk.Data)
+			if err != nil {
+				return nil, true, err
+			}
+			buf.Write(data)
+		} else {
+			buf.WriteString(chunk.Data)
+		}
+		if chunk.EOF {
+			break
+		}
+	}
+	o.log.Debug("[Orchestrator] 通过流读取响应体完成", "requestID", id, "bodyLen", buf.Len())
+	return buf.Bytes(), true, nil
+}
+
+// isMediaResponse 判断是否为音视频响应（按资源类型、Content-Type 或分段响应状态码）
+func isMediaResponse(ev *fetch.RequestPausedReply, res *domain.Response) bool {
+	if ev.ResourceType == network.ResourceTypeMedia || res.StatusCode == 206 {
+		return true
+	}
+	ct := strings.ToLower(res.Headers.Get("Content-Type"))
+	return strings.HasPrefix(ct, "video/") || strings.HasPrefix(ct, "audio/")
+}
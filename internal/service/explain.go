@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"cdpnetool/internal/auditor"
+	"cdpnetool/internal/engine"
+	"cdpnetool/internal/processor"
+	"cdpnetool/internal/tracker"
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+)
+
+// explainRequestID 样例请求未指定 ID 时使用的事务ID
+const explainRequestID = "explain"
+
+// ExplainRules 在不连接浏览器的情况下用样例请求/响应试运行规则配置
+// 返回每条规则的逐条件判定结果，以及行为链执行后的请求与响应
+func (o *Orchestrator) ExplainRules(ctx context.Context, cfg *rulespec.Config, req *domain.Request, res *domain.Response) (*domain.ExplainResult, error) {
+	if cfg == nil || req == nil {
+		return nil, domain.ErrInvalidConfig
+	}
+
+	sample := req.Clone()
+	if sample.ID == "" {
+		sample.ID = explainRequestID
+	}
+	if len(sample.Query) == 0 {
+		sample.Query = transformer.ParseQuery(sample.URL)
+	}
+	if len(sample.Cookies) == 0 {
+		sample.Cookies = transformer.ParseCookies(sample.Headers.Get("Cookie"))
+	}
+
+	// 复用真实链路的引擎与处理器，审计器禁用以免产生事件
+	eng := engine.New(cfg)
+	tr := tracker.New(time.Minute, o.log)
+	defer tr.Stop()
+	p := processor.New(tr, eng, auditor.NewDisabled(nil, o.log), auditor.NewDisabled(nil, o.log), o.log)
+
+	result := &domain.ExplainResult{}
+
+	// 请求阶段：先基于原始请求生成轨迹，再执行行为链（会原地修改 sample）
+	reqTraces := eng.Explain(sample, nil, rulespec.StageRequest)
+	reqResult := p.ProcessRequest(ctx, sample)
+	markApplied(reqTraces, reqResult.Rules)
+	result.Rules = reqTraces
+	result.Request = sample
+
+	if reqResult.Action == processor.ActionBlock || reqResult.Action == processor.ActionFail {
+		reason := "request blocked before response stage"
+		if reqResult.Action == processor.ActionFail {
+			result.FailReason = reqResult.FailReason
+			reason = "request failed before response stage"
+		} else {
+			result.Blocked = true
+			result.Response = reqResult.MockRes
+		}
+		resTraces := eng.Explain(sample, nil, rulespec.StageResponse)
+		for i := range resTraces {
+			resTraces[i].Matched = false
+			resTraces[i].Reason = reason
+		}
+		result.Rules = append(result.Rules, resTraces...)
+		return result, nil
+	}
+
+	// 响应阶段：与真实链路一致，基于修改后的请求评估
+	var resSample *domain.Response
+	if res != nil {
+		resSample = res.Clone()
+	}
+	traceSample := resSample
+	if resSample != nil {
+		// 与 ProcessResponse 一致，条件基于解压与转码后的响应体评估；处理器自行解码原始响应体
+		traceSample = resSample.Clone()
+		traceSample.Body, _, _ = transformer.DecodeResponseBody(resSample.Headers, resSample.Body)
+	}
+	resTraces := eng.Explain(sample, traceSample, rulespec.StageResponse)
+	if resSample != nil {
+		resResult := p.ProcessResponse(ctx, sample.ID, resSample)
+		markApplied(resTraces, resResult.Rules)
+		if resResult.Action == processor.ActionFail {
+			result.FailReason = resResult.FailReason
+		} else {
+			result.Response = resSample
+		}
+	}
+	result.Rules = append(result.Rules, resTraces...)
+	return result, nil
+}
+
+// markApplied 标记实际执行了行为的规则，并为匹配但被跳过的规则补充原因
+func markApplied(traces []domain.RuleExplain, applied []*engine.MatchedRule) {
+	ids := make(map[string]bool, len(applied))
+	for _, mr := range applied {
+		ids[mr.Rule.ID] = true
+	}
+	for i := range traces {
+		if !traces[i].Matched {
+			continue
+		}
+		if ids[traces[i].RuleID] {
+			traces[i].Applied = true
+		} else {
+			traces[i].Reason = "skipped by flow control, hit limit or an earlier block/fail"
+		}
+	}
+}
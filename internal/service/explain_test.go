@@ -0,0 +1,187 @@
+package service_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+
+	"cdpnetool/internal/logger"
+	"cdpnetool/internal/service"
+	"cdpnetool/pkg/domain"
+	"cdpnetool/pkg/rulespec"
+)
+
+func TestExplainRules(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:       "add-header",
+			Enabled:  true,
+			Priority: 10,
+			Stage:    rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/api/"}},
+			},
+			Actions:        []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-Debug", Value: "1"}},
+			StopAfterMatch: true,
+		},
+		{
+			ID:      "skipped",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "/api/"}},
+			},
+			Actions: []rulespec.Action{{Type: rulespec.ActionSetHeader, Name: "X-Skipped", Value: "1"}},
+		},
+		{
+			ID:      "patch-status",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{
+					{Type: rulespec.ConditionHeaderEquals, Name: "X-Debug", Value: "1"},
+					{Type: rulespec.ConditionStatusCode, Values: []string{"500"}},
+				},
+			},
+			Actions: []rulespec.Action{{Type: rulespec.ActionSetStatus, Value: 200}},
+		},
+	}
+
+	o := service.New(logger.NewNop())
+	req := &domain.Request{URL: "https://example.com/api/users", Method: "GET"}
+	res := &domain.Response{StatusCode: 500, Headers: domain.Header{}}
+
+	result, err := o.ExplainRules(context.Background(), cfg, req, res)
+	if err != nil {
+		t.Fatalf("ExplainRules() error: %v", err)
+	}
+	if len(result.Rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(result.Rules))
+	}
+
+	want := map[string]struct{ matched, applied bool }{
+		"add-header":   {true, true},
+		"skipped":      {true, false},
+		"patch-status": {true, true},
+	}
+	for _, r := range result.Rules {
+		w := want[r.RuleID]
+		if r.Matched != w.matched || r.Applied != w.applied {
+			t.Errorf("%s: got matched=%v applied=%v, want %v/%v (%s)", r.RuleID, r.Matched, r.Applied, w.matched, w.applied, r.Reason)
+		}
+	}
+
+	if result.Request.Headers.Get("X-Debug") != "1" || result.Request.Headers.Get("X-Skipped") != "" {
+		t.Errorf("unexpected request headers: %v", result.Request.Headers)
+	}
+	if result.Response == nil || result.Response.StatusCode != 200 {
+		t.Errorf("got response %+v, want status 200", result.Response)
+	}
+	if res.StatusCode != 500 || req.Headers != nil {
+		t.Error("ExplainRules must not modify the sample inputs")
+	}
+}
+
+func TestExplainRules_Block(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "block",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "ads"}},
+			},
+			Actions: []rulespec.Action{{Type: rulespec.ActionBlock, StatusCode: 403}},
+		},
+		{
+			ID:      "response",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "ads"}},
+			},
+		},
+	}
+
+	o := service.New(logger.NewNop())
+	result, err := o.ExplainRules(context.Background(), cfg, &domain.Request{URL: "https://ads.example.com/x", Method: "GET"}, nil)
+	if err != nil {
+		t.Fatalf("ExplainRules() error: %v", err)
+	}
+	if !result.Blocked || result.Response == nil || result.Response.StatusCode != 403 {
+		t.Errorf("got blocked=%v response=%+v, want blocked 403", result.Blocked, result.Response)
+	}
+	if r := result.Rules[1]; r.Matched || r.Applied {
+		t.Errorf("response rule should not run after block: %+v", r)
+	}
+}
+
+func TestExplainRules_Fail(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "fail",
+			Enabled: true,
+			Stage:   rulespec.StageRequest,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionURLContains, Value: "api"}},
+			},
+			Actions: []rulespec.Action{{Type: rulespec.ActionFail, ErrorReason: rulespec.ErrorReasonTimedOut}},
+		},
+	}
+
+	o := service.New(logger.NewNop())
+	result, err := o.ExplainRules(context.Background(), cfg, &domain.Request{URL: "https://example.com/api", Method: "GET"}, domain.NewResponse())
+	if err != nil {
+		t.Fatalf("ExplainRules() error: %v", err)
+	}
+	if result.FailReason != "TimedOut" || result.Blocked || result.Response != nil {
+		t.Errorf("got failReason=%q blocked=%v response=%+v, want TimedOut", result.FailReason, result.Blocked, result.Response)
+	}
+}
+
+func TestExplainRules_CompressedResponse(t *testing.T) {
+	cfg := rulespec.NewConfig("test")
+	cfg.Rules = []rulespec.Rule{
+		{
+			ID:      "body",
+			Enabled: true,
+			Stage:   rulespec.StageResponse,
+			Match: rulespec.Match{
+				AllOf: []rulespec.Condition{{Type: rulespec.ConditionResponseBodyContains, Value: `"role":"user"`}},
+			},
+			Actions: []rulespec.Action{{Type: rulespec.ActionReplaceBodyText, Search: "user", Replace: "admin"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"role":"user","pad":"` + strings.Repeat("x", 256) + `"}`))
+	zw.Close()
+	res := &domain.Response{StatusCode: 200, Headers: domain.Header{}, Body: buf.Bytes()}
+	res.Headers.Set("Content-Type", "application/json")
+	res.Headers.Set("Content-Encoding", "gzip")
+
+	o := service.New(logger.NewNop())
+	result, err := o.ExplainRules(context.Background(), cfg, &domain.Request{URL: "https://example.com/me", Method: "GET"}, res)
+	if err != nil {
+		t.Fatalf("ExplainRules() error: %v", err)
+	}
+	if r := result.Rules[0]; !r.Matched || !r.Applied {
+		t.Errorf("body condition should match the decompressed body: %+v", r)
+	}
+	if result.Response == nil || !strings.HasPrefix(string(result.Response.Body), `{"role":"admin"`) {
+		t.Errorf("got response %+v, want modified body", result.Response)
+	}
+}
+
+func TestExplainRules_InvalidInput(t *testing.T) {
+	o := service.New(logger.NewNop())
+	if _, err := o.ExplainRules(context.Background(), nil, &domain.Request{}, nil); err != domain.ErrInvalidConfig {
+		t.Errorf("got err %v, want %v", err, domain.ErrInvalidConfig)
+	}
+}
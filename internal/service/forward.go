@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"cdpnetool/internal/adapter/cdp"
+	"cdpnetool/internal/adapter/forward"
+	"cdpnetool/internal/processor"
+	"cdpnetool/pkg/domain"
+
+	"github.com/mafredri/cdp/protocol/fetch"
+	"github.com/mafredri/cdp/protocol/network"
+)
+
+// forwardRequest 由本地 HTTP 客户端代发修改后的请求，响应经过响应阶段规则处理后以 FulfillRequest 返回
+// 用于 ContinueRequest 无法完成的改写（切换协议、保留原始 Host），在独立协程中执行，不占用工作池
+func (o *Orchestrator) forwardRequest(state *sessionState, ts *cdp.TargetSession, ev *fetch.RequestPausedReply, req *domain.Request) {
+	id := ev.RequestID
+	if !req.Headers.Has("Cookie") {
+		o.attachCookies(state.ctx, ts, ev.Request.URL, req)
+	}
+
+	resp, err := state.forwarder.Do(state.ctx, req)
+	if err != nil {
+		reason := forward.ErrorReason(err)
+		o.log.Err(err, "[Orchestrator] 本地代发请求失败", "requestID", id, "url", req.URL, "errorReason", reason)
+		state.tracker.Delete(string(id))
+		o.applyResult(state, ts, ev, processor.Result{Action: processor.ActionFail, FailReason: string(reason)})
+		return
+	}
+
+	res := state.processor.ProcessResponse(state.ctx, string(id), resp)
+	if res.Action == processor.ActionPass {
+		// 请求阶段无法放行响应，未修改的响应同样通过 FulfillRequest 返回
+		res.Action = processor.ActionModify
+		res.ModifiedRes = resp
+	}
+	// 以代发得到的响应模拟响应阶段事件
+	resEv := *ev
+	resEv.ResponseStatusCode = &resp.StatusCode
+	o.applyResult(state, ts, &resEv, res)
+}
+
+// attachCookies 读取浏览器中原始 URL 适用的 Cookie 并附加到代发请求
+// 拦截事件中的请求头不包含 Cookie，由浏览器网络栈在发送时才附加
+func (o *Orchestrator) attachCookies(ctx context.Context, ts *cdp.TargetSession, rawURL string, req *domain.Request) {
+	ctx2, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	reply, err := ts.Client.Network.GetCookies(ctx2, &network.GetCookiesArgs{URLs: []string{rawURL}})
+	if err != nil {
+		o.log.Warn("[Orchestrator] 读取代发请求的 Cookie 失败", "requestID", req.ID, "error", err.Error())
+		return
+	}
+	if len(reply.Cookies) == 0 {
+		return
+	}
+	parts := make([]string, len(reply.Cookies))
+	for i, c := range reply.Cookies {
+		parts[i] = c.Name + "=" + c.Value
+	}
+	req.Headers.Set("Cookie", strings.Join(parts, "; "))
+}
@@ -2,12 +2,12 @@ package service
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"sync"
 	"time"
 
 	"cdpnetool/internal/adapter/cdp"
+	"cdpnetool/internal/adapter/forward"
 	"cdpnetool/internal/auditor"
 	"cdpnetool/internal/engine"
 	"cdpnetool/internal/logger"
@@ -15,11 +15,13 @@ import (
 	"cdpnetool/internal/processor"
 	"cdpnetool/internal/session"
 	"cdpnetool/internal/tracker"
+	"cdpnetool/internal/transformer"
 	"cdpnetool/pkg/domain"
 	"cdpnetool/pkg/rulespec"
 
 	"github.com/google/uuid"
 	"github.com/mafredri/cdp/protocol/fetch"
+	"github.com/mafredri/cdp/protocol/network"
 )
 
 // sessionState 维护单个会话的所有新架构组件
@@ -29,6 +31,7 @@ type sessionState struct {
 	sess                *session.Session
 	clientMgr           *cdp.ClientManager
 	interceptor         *cdp.Interceptor
+	forwarder           *forward.Client
 	engine              *engine.Engine
 	tracker             *tracker.Tracker
 	matchedAuditor      *auditor.Auditor
@@ -40,6 +43,8 @@ type sessionState struct {
 	ctx                 context.Context
 	cancel              context.CancelFunc
 	interceptionEnabled bool
+	autoAttachCancel    context.CancelFunc           // 停止自动附着的浏览器级监听，为 nil 表示未开启
+	autoAttached        map[domain.TargetID]struct{} // 自动附着的目标
 	mu                  sync.Mutex
 }
 
@@ -104,6 +109,7 @@ func (o *Orchestrator) StartSession(ctx context.Context, cfg domain.SessionConfi
 		sess:           sess,
 		clientMgr:      clientMgr,
 		interceptor:    intr,
+		forwarder:      forward.New(0, o.log),
 		engine:         eng,
 		tracker:        trk,
 		matchedAuditor: matchedAud,
@@ -114,6 +120,7 @@ func (o *Orchestrator) StartSession(ctx context.Context, cfg domain.SessionConfi
 		workPool:       workPool,
 		ctx:            sessionCtx,
 		cancel:         cancel,
+		autoAttached:   make(map[domain.TargetID]struct{}),
 	}
 
 	o.sessions[id] = state
@@ -162,7 +169,11 @@ func (o *Orchestrator) AttachTarget(ctx context.Context, id domain.SessionID, ta
 	if !ok {
 		return domain.ErrSessionNotFound
 	}
+	return o.attachTarget(ctx, state, target)
+}
 
+// attachTarget 附着目标、启动事件监听，并按当前业务状态开启物理拦截
+func (o *Orchestrator) attachTarget(ctx context.Context, state *sessionState, target domain.TargetID) error {
 	ts, err := state.clientMgr.AttachTarget(ctx, target)
 	if err != nil {
 		return err
@@ -171,13 +182,18 @@ func (o *Orchestrator) AttachTarget(ctx context.Context, id domain.SessionID, ta
 	state.sess.AddTarget(target)
 
 	// 启动 CDP 事件监听循环
-	go state.interceptor.Consume(state.ctx, ts.Client, func(ev *fetch.RequestPausedReply) {
+	if err := state.interceptor.Consume(state.ctx, ts.Client, func(ev *fetch.RequestPausedReply) {
 		o.handleEvent(state, ts, ev)
-	})
+	}); err != nil {
+		return err
+	}
+
+	// 跟踪页面 URL、标题与 frame，供页面级条件使用
+	go ts.Page.Watch(state.ctx, ts.Client)
 
 	// 根据当前业务状态决定是否启用该 Target 的物理拦截
-	if o.shouldEnablePhysicalInterception(state) {
-		if err := state.interceptor.Enable(state.ctx, ts.Client); err != nil {
+	if patterns, enable := o.interceptPatterns(state); enable {
+		if err := state.interceptor.Enable(state.ctx, ts.Client, patterns); err != nil {
 			o.log.Err(err, "Attach 时启用拦截失败", "target", string(target))
 		}
 	}
@@ -191,7 +207,15 @@ func (o *Orchestrator) DetachTarget(ctx context.Context, id domain.SessionID, ta
 	if !ok {
 		return domain.ErrSessionNotFound
 	}
+	return o.detachTarget(state, target)
+}
+
+// detachTarget 将目标移出会话并断开连接
+func (o *Orchestrator) detachTarget(state *sessionState, target domain.TargetID) error {
 	state.sess.RemoveTarget(target)
+	state.mu.Lock()
+	delete(state.autoAttached, target)
+	state.mu.Unlock()
 	return state.clientMgr.DetachTarget(target)
 }
 
@@ -221,14 +245,9 @@ func (o *Orchestrator) EnableInterception(ctx context.Context, id domain.Session
 	state.interceptionEnabled = true
 	state.mu.Unlock()
 
-	// 遍历所有已附着的 Target 物理开启拦截
-	for _, tid := range targets {
-		ts, ok := state.clientMgr.GetSession(tid)
-		if ok {
-			if err := state.interceptor.Enable(ctx, ts.Client); err != nil {
-				o.log.Err(err, "物理开启拦截失败", "target", string(tid))
-			}
-		}
+	// 按规则推导的拦截模式物理开启所有已附着 Target 的拦截
+	if err := o.updatePhysicalInterception(ctx, state); err != nil {
+		return err
 	}
 	o.log.Info("会话逻辑拦截已开启", "sessionID", string(id))
 	return nil
@@ -269,6 +288,11 @@ func (o *Orchestrator) LoadRules(ctx context.Context, id domain.SessionID, cfg *
 	}
 	state.engine.Update(cfg)
 	state.sess.UpdateConfig(cfg)
+
+	// 规则变化后重新推导拦截模式
+	if o.shouldEnablePhysicalInterception(state) {
+		return o.updatePhysicalInterception(ctx, state)
+	}
 	return nil
 }
 
@@ -278,16 +302,36 @@ func (o *Orchestrator) GetRuleStats(ctx context.Context, id domain.SessionID) (d
 	if !ok {
 		return domain.EngineStats{}, domain.ErrSessionNotFound
 	}
-	total, matched, byRule := state.engine.GetStats()
-	stats := domain.EngineStats{
-		Total:   total,
-		Matched: matched,
-		ByRule:  make(map[domain.RuleID]int64),
+	return state.engine.GetStats(), nil
+}
+
+// ResetRuleStats 清空指定会话的规则统计信息
+func (o *Orchestrator) ResetRuleStats(ctx context.Context, id domain.SessionID) error {
+	state, ok := o.get(id)
+	if !ok {
+		return domain.ErrSessionNotFound
+	}
+	state.engine.ResetStats()
+	return nil
+}
+
+// GetSessionState 获取指定会话的变量与场景状态
+func (o *Orchestrator) GetSessionState(ctx context.Context, id domain.SessionID) (domain.SessionState, error) {
+	state, ok := o.get(id)
+	if !ok {
+		return domain.SessionState{}, domain.ErrSessionNotFound
 	}
-	for k, v := range byRule {
-		stats.ByRule[domain.RuleID(k)] = v
+	return state.engine.GetState(), nil
+}
+
+// ResetSessionState 清空指定会话的变量并将场景恢复为初始状态
+func (o *Orchestrator) ResetSessionState(ctx context.Context, id domain.SessionID) error {
+	state, ok := o.get(id)
+	if !ok {
+		return domain.ErrSessionNotFound
 	}
-	return stats, nil
+	state.engine.ResetState()
+	return nil
 }
 
 // SubscribeEvents 订阅指定会话的事件流
@@ -341,50 +385,59 @@ func (o *Orchestrator) handleEvent(state *sessionState, ts *cdp.TargetSession, e
 	if ev.ResponseStatusCode == nil {
 		// 请求阶段
 		req := cdp.ToNeutralRequest(ev)
+		req.Page = ts.Page.Context(string(ev.FrameID))
 		res := state.processor.ProcessRequest(state.ctx, req)
 		o.log.Debug("[Orchestrator] 请求处理结果", "requestID", ev.RequestID, "action", res.Action)
 		o.applyResult(state, ts, ev, res)
 	} else {
-		// 响应阶段
-		// 获取原始响应体
-		var body []byte
-		ctx2, cancel2 := context.WithTimeout(state.ctx, 3*time.Second)
-		rb, err := ts.Client.Fetch.GetResponseBody(ctx2, &fetch.GetResponseBodyArgs{RequestID: ev.RequestID})
-		cancel2()
+		// 响应阶段：仅在规则或流量捕获需要时读取响应体
+		resp := cdp.ToNeutralResponse(ev, nil)
+		streamed, err := o.loadResponseBody(state, ts, ev, resp)
 		if err != nil {
+			if streamed {
+				// 响应体已通过流取走，无法再放行
+				o.log.Err(err, "读取响应体流失败，中止请求", "requestID", ev.RequestID)
+				o.applyResult(state, ts, ev, processor.Result{Action: processor.ActionFail, FailReason: string(rulespec.ErrorReasonFailed)})
+				return
+			}
 			o.log.Warn("获取响应体失败，执行降级放行", "requestID", ev.RequestID, "error", err.Error())
 			if err := state.interceptor.ContinueResponse(state.ctx, ts.Client, ev.RequestID); err != nil {
 				o.log.Err(err, "降级放行响应失败", "requestID", ev.RequestID)
 			}
 			return
 		}
-		if rb != nil {
-			// GetResponseBody 返回的是 base64 编码的字符串，需要解码为原始字节
-			if rb.Base64Encoded {
-				decoded, err := base64.StdEncoding.DecodeString(rb.Body)
-				if err != nil {
-					o.log.Err(err, "解码响应体失败", "requestID", ev.RequestID)
-					body = []byte(rb.Body)
-				} else {
-					body = decoded
-				}
-			} else {
-				body = []byte(rb.Body)
-			}
-		}
 
-		resp := cdp.ToNeutralResponse(ev, body)
 		res := state.processor.ProcessResponse(state.ctx, string(ev.RequestID), resp)
+		if streamed && res.Action == processor.ActionPass {
+			// 通过流读取后无法原样放行，改为返回读取到的响应体
+			transformer.FixDecodedHeaders(&resp.Headers, len(resp.Body))
+			res.Action = processor.ActionModify
+			res.ModifiedRes = resp
+		}
 		o.log.Debug("[Orchestrator] 响应处理结果", "requestID", ev.RequestID, "action", res.Action)
 		o.applyResult(state, ts, ev, res)
 	}
 }
 
 // applyResult 将中立处理结果反馈给物理适配层
+// 存在延迟或限速时由定时器到期后再应用，不占用工作池协程，避免阻塞其他请求
 func (o *Orchestrator) applyResult(state *sessionState, ts *cdp.TargetSession, ev *fetch.RequestPausedReply, res processor.Result) {
 	id := ev.RequestID
 	isRequest := ev.ResponseStatusCode == nil
 
+	if wait := res.Wait(); wait > 0 {
+		o.log.Debug("[Orchestrator] 推迟应用结果", "requestID", id, "wait", wait, "throttle", res.Throttle)
+		res.Delay, res.Throttle = 0, 0
+		time.AfterFunc(wait, func() {
+			// 会话已停止时 Fetch 拦截随之关闭，不再应用结果
+			if state.ctx.Err() != nil {
+				return
+			}
+			o.applyResult(state, ts, ev, res)
+		})
+		return
+	}
+
 	o.log.Debug("[Orchestrator] 开始应用结果", "requestID", id, "action", res.Action, "isRequest", isRequest)
 
 	switch res.Action {
@@ -412,9 +465,31 @@ func (o *Orchestrator) applyResult(state *sessionState, ts *cdp.TargetSession, e
 			o.log.Debug("[Orchestrator] Block 执行成功", "requestID", id)
 		}
 
+	case processor.ActionFail:
+		// 请求与响应阶段均可通过 FailRequest 以网络错误中止
+		reason := network.ErrorReason(res.FailReason)
+		if !reason.Valid() {
+			o.log.Warn("[Orchestrator] 未知的网络错误原因，使用 Failed", "requestID", id, "errorReason", res.FailReason)
+			reason = network.ErrorReasonFailed
+		}
+		o.log.Info("[Orchestrator] 执行 Fail 动作", "requestID", id, "errorReason", reason)
+		err := ts.Client.Fetch.FailRequest(state.ctx, &fetch.FailRequestArgs{
+			RequestID:   id,
+			ErrorReason: reason,
+		})
+		if err != nil {
+			o.log.Err(err, "[Orchestrator] 执行 Fail 动作失败", "requestID", id)
+		} else {
+			o.log.Debug("[Orchestrator] Fail 执行成功", "requestID", id)
+		}
+
 	case processor.ActionModify:
 		o.log.Debug("[Orchestrator] 执行 Modify 动作", "requestID", id, "isRequest", isRequest)
-		if isRequest {
+		if isRequest && res.Forward {
+			// ContinueRequest 无法切换协议或修改 Host，改由本地代发
+			o.log.Debug("[Orchestrator] 请求改由本地代发", "requestID", id, "url", res.ModifiedReq.URL)
+			go o.forwardRequest(state, ts, ev, res.ModifiedReq)
+		} else if isRequest {
 			// 请求阶段修改
 			err := ts.Client.Fetch.ContinueRequest(state.ctx, &fetch.ContinueRequestArgs{
 				RequestID: id,
@@ -428,8 +503,22 @@ func (o *Orchestrator) applyResult(state *sessionState, ts *cdp.TargetSession, e
 			} else {
 				o.log.Debug("[Orchestrator] 请求修改成功", "requestID", id)
 			}
+		} else if res.ModifiedRes != nil && res.ModifiedRes.BodyOmitted {
+			// 未读取响应体：只覆盖状态码与头部，响应体由浏览器沿用
+			code := res.ModifiedRes.StatusCode
+			err := ts.Client.Fetch.ContinueResponse(state.ctx, &fetch.ContinueResponseArgs{
+				RequestID:       id,
+				ResponseCode:    &code,
+				ResponseHeaders: cdp.ToHeaderEntries(res.ModifiedRes.Headers),
+			})
+			if err != nil {
+				o.log.Err(err, "[Orchestrator] 执行响应头修改失败", "requestID", id)
+				_ = state.interceptor.ContinueResponse(state.ctx, ts.Client, id)
+			} else {
+				o.log.Debug("[Orchestrator] 响应头修改成功", "requestID", id)
+			}
 		} else {
-			// 响应阶段修改：统一使用 FulfillRequest 全量覆盖
+			// 响应阶段修改：使用 FulfillRequest 全量覆盖
 			code := 200
 			var headers domain.Header
 			var body []byte
@@ -442,10 +531,7 @@ func (o *Orchestrator) applyResult(state *sessionState, ts *cdp.TargetSession, e
 				if ev.ResponseStatusCode != nil {
 					code = *ev.ResponseStatusCode
 				}
-				headers = make(domain.Header)
-				for _, h := range ev.ResponseHeaders {
-					headers.Set(h.Name, h.Value)
-				}
+				headers = cdp.FromHeaderEntries(ev.ResponseHeaders)
 			}
 
 			err := ts.Client.Fetch.FulfillRequest(state.ctx, &fetch.FulfillRequestArgs{
@@ -486,10 +572,24 @@ func (o *Orchestrator) shouldEnablePhysicalInterception(state *sessionState) boo
 	return state.interceptionEnabled || state.trafficAuditor.IsEnabled()
 }
 
+// interceptPatterns 根据业务状态计算物理拦截模式，enable 为 false 表示无需物理拦截
+// 流量捕获需要记录全部请求，此时返回空模式以拦截全部请求；仅开启拦截时使用由启用规则推导的模式
+func (o *Orchestrator) interceptPatterns(state *sessionState) (patterns []fetch.RequestPattern, enable bool) {
+	if state.trafficAuditor.IsEnabled() {
+		return nil, true
+	}
+	if !o.shouldEnablePhysicalInterception(state) {
+		return nil, false
+	}
+	derived := state.engine.InterceptPatterns()
+	return cdp.ToRequestPatterns(derived), len(derived) > 0
+}
+
 // updatePhysicalInterception 根据业务状态更新所有目标的物理拦截
 func (o *Orchestrator) updatePhysicalInterception(ctx context.Context, state *sessionState) error {
-	shouldEnable := o.shouldEnablePhysicalInterception(state)
+	patterns, shouldEnable := o.interceptPatterns(state)
 	targets := state.sess.GetTargets()
+	o.log.Debug("[Orchestrator] 更新物理拦截", "enable", shouldEnable, "patterns", len(patterns))
 
 	for _, tid := range targets {
 		ts, ok := state.clientMgr.GetSession(tid)
@@ -498,7 +598,7 @@ func (o *Orchestrator) updatePhysicalInterception(ctx context.Context, state *se
 		}
 
 		if shouldEnable {
-			if err := state.interceptor.Enable(ctx, ts.Client); err != nil {
+			if err := state.interceptor.Enable(ctx, ts.Client, patterns); err != nil {
 				o.log.Err(err, "物理拦截启用失败", "target", string(tid))
 			}
 		} else {
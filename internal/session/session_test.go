@@ -106,6 +106,23 @@ func TestRemoveTarget_All(t *testing.T) {
 	}
 }
 
+func TestHasTarget(t *testing.T) {
+	sess := session.New("session1")
+	sess.AddTarget("target1")
+
+	if !sess.HasTarget("target1") {
+		t.Error("HasTarget(target1) = false, want true")
+	}
+	if sess.HasTarget("target2") {
+		t.Error("HasTarget(target2) = true, want false")
+	}
+
+	sess.RemoveTarget("target1")
+	if sess.HasTarget("target1") {
+		t.Error("HasTarget(target1) after remove = true, want false")
+	}
+}
+
 func TestGetTargets_Empty(t *testing.T) {
 	sess := session.New("session1")
 	targets := sess.GetTargets()
@@ -41,7 +41,7 @@ type NetworkEventRecord struct {
 	URL              string    `json:"url"`
 	Method           string    `json:"method"`
 	StatusCode       int       `json:"statusCode"`                        // 状态码
-	FinalResult      string    `gorm:"index" json:"finalResult"`          // blocked / modified / passed
+	FinalResult      string    `gorm:"index" json:"finalResult"`          // blocked / failed / modified / passed
 	MatchedRulesJSON string    `gorm:"type:text" json:"matchedRulesJson"` // 匹配规则 JSON 数组
 	RequestJSON      string    `gorm:"type:text" json:"requestJson"`      // 请求信息 JSON
 	ResponseJSON     string    `gorm:"type:text" json:"responseJson"`     // 响应信息 JSON
@@ -156,7 +156,7 @@ func (r *EventRepo) Record(evt *domain.NetworkEvent) {
 // QueryOptions 查询选项
 type QueryOptions struct {
 	SessionID   string
-	FinalResult string // blocked / modified / passed
+	FinalResult string // blocked / failed / modified / passed
 	URL         string
 	Method      string
 	StartTime   int64
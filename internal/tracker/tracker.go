@@ -47,6 +47,17 @@ func (t *Tracker) Set(id string, data any) {
 	})
 }
 
+// Extend 将事务的过期时间顺延 d，事务不存在时返回 false
+func (t *Tracker) Extend(id string, d time.Duration) bool {
+	val, ok := t.pool.Load(id)
+	if !ok {
+		return false
+	}
+	entry := *val.(*Entry)
+	entry.StartTime = entry.StartTime.Add(d)
+	return t.pool.CompareAndSwap(id, val, &entry)
+}
+
 // Get 获取并移除事务数据
 func (t *Tracker) Get(id string) (any, bool) {
 	val, ok := t.pool.LoadAndDelete(id)
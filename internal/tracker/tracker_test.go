@@ -118,6 +118,22 @@ func TestCleanup(t *testing.T) {
 	}
 }
 
+func TestExtend(t *testing.T) {
+	tr := tracker.New(5*time.Second, logger.NewNop())
+	defer tr.Stop()
+
+	tr.Set("id1", "data")
+	if !tr.Extend("id1", time.Minute) {
+		t.Error("Extend() returned false for existing id")
+	}
+	if got, ok := tr.Peek("id1"); !ok || got != "data" {
+		t.Errorf("Extend() should keep data, got %v, %v", got, ok)
+	}
+	if tr.Extend("not-exist", time.Minute) {
+		t.Error("Extend() should return false for non-existent id")
+	}
+}
+
 func TestStop(t *testing.T) {
 	tr := tracker.New(5*time.Second, logger.NewNop())
 	tr.Stop()
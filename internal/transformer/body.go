@@ -2,12 +2,13 @@ package transformer
 
 import (
 	"encoding/base64"
-	"net/url"
+	"errors"
+	"fmt"
 	"strings"
 
 	"cdpnetool/pkg/rulespec"
 
-	"github.com/tidwall/sjson"
+	"github.com/tidwall/gjson"
 )
 
 // ReplaceText 文本替换
@@ -18,54 +19,63 @@ func ReplaceText(body string, search, replace string, all bool) string {
 	return strings.Replace(body, search, replace, 1)
 }
 
-// PatchJSON 应用 JSON Patch 修改 (基于 sjson)
+// ExtractJSONPath 按 JSON Path（可带 "$." 前缀）读取值的字符串形式，对象与数组返回原始 JSON
+func ExtractJSONPath(body []byte, path string) (string, bool) {
+	path = strings.TrimPrefix(path, "$.")
+	if len(body) == 0 || path == "" {
+		return "", false
+	}
+	r := gjson.GetBytes(body, path)
+	return r.String(), r.Exists()
+}
+
+// PatchJSON 按 RFC 6902 应用 JSON Patch（add/remove/replace/move/copy/test）
+// 路径为 RFC 6901 JSON Pointer，支持 ~0/~1 转义与数组末尾 "-"；任一操作失败（包括 test 不通过）时整体不生效，返回原始内容与错误
 func PatchJSON(body string, patches []rulespec.JSONPatchOp) (string, error) {
 	if body == "" || len(patches) == 0 {
 		return body, nil
 	}
 
-	currentBody := body
-	for _, patch := range patches {
-		if patch.Path == "" {
-			continue
-		}
-
-		// 将 JSON Patch 路径 (/a/b/c) 转换为 sjson 路径 (a.b.c)
-		path := strings.TrimPrefix(patch.Path, "/")
-		path = strings.ReplaceAll(path, "/", ".")
-
-		var err error
-		switch patch.Op {
-		case "add", "replace":
-			currentBody, err = sjson.Set(currentBody, path, patch.Value)
-		case "remove":
-			currentBody, err = sjson.Delete(currentBody, path)
-		}
-		if err != nil {
-			return body, err
+	doc, err := parseJSONDoc([]byte(body))
+	if err != nil {
+		return body, err
+	}
+	for i, patch := range patches {
+		if err := doc.apply(patch); err != nil {
+			if errors.Is(err, ErrPatchTestFailed) {
+				return body, fmt.Errorf("%w: op %d at %q", err, i, patch.Path)
+			}
+			return body, fmt.Errorf("json patch op %d (%s %q): %w", i, patch.Op, patch.Path, err)
 		}
 	}
-	return currentBody, nil
+	return doc.String(), nil
 }
 
-// SetFormUrlencoded 设置 x-www-form-urlencoded 字段
-func SetFormUrlencoded(body, key, value string) (string, error) {
-	values, err := url.ParseQuery(body)
+// MergeJSON 按 RFC 7396 应用 JSON Merge Patch：对象递归合并，null 删除字段，其他值直接替换
+// patch 为字符串时按 JSON 文本解析；body 为空时视为 null
+func MergeJSON(body string, patch any) (string, error) {
+	var patchNode any
+	var err error
+	if text, ok := patch.(string); ok {
+		var patchDoc *jsonDoc
+		if patchDoc, err = parseJSONDoc([]byte(text)); err == nil {
+			patchNode = patchDoc.root
+		}
+	} else {
+		patchNode, err = toJSONNode(patch)
+	}
 	if err != nil {
-		return body, err
+		return body, fmt.Errorf("invalid merge patch: %w", err)
 	}
-	values.Set(key, value)
-	return values.Encode(), nil
-}
 
-// RemoveFormUrlencoded 移除 x-www-form-urlencoded 字段
-func RemoveFormUrlencoded(body, key string) (string, error) {
-	values, err := url.ParseQuery(body)
-	if err != nil {
-		return body, err
+	doc := &jsonDoc{}
+	if strings.TrimSpace(body) != "" {
+		if doc, err = parseJSONDoc([]byte(body)); err != nil {
+			return body, err
+		}
 	}
-	values.Del(key)
-	return values.Encode(), nil
+	doc.root = mergePatch(doc.root, patchNode)
+	return doc.String(), nil
 }
 
 // DecodeBody 根据编码方式解码
@@ -65,6 +65,80 @@ func TestPatchJSON(t *testing.T) {
 			want:    `{"name":"test"}`,
 			wantErr: false,
 		},
+		{
+			name: "数组插入与末尾追加",
+			body: `{"list":[1,3]}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "add", Path: "/list/1", Value: 2},
+				{Op: "add", Path: "/list/-", Value: 4},
+			},
+			want: `{"list":[1,2,3,4]}`,
+		},
+		{
+			name: "转义与含点的键",
+			body: `{"a/b":1,"m~n":2,"x.y":3}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "replace", Path: "/a~1b", Value: 10},
+				{Op: "remove", Path: "/m~0n"},
+				{Op: "replace", Path: "/x.y", Value: 30},
+			},
+			want: `{"a/b":10,"x.y":30}`,
+		},
+		{
+			name: "移动与复制",
+			body: `{"a":{"b":1},"c":[]}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "copy", From: "/a/b", Path: "/c/-"},
+				{Op: "move", From: "/a", Path: "/d"},
+			},
+			want: `{"c":[1],"d":{"b":1}}`,
+		},
+		{
+			name: "test 通过后继续执行",
+			body: `{"v":1.0,"o":{"x":1,"y":2}}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "test", Path: "/v", Value: 1},
+				{Op: "test", Path: "/o", Value: map[string]any{"y": 2, "x": 1}},
+				{Op: "add", Path: "/ok", Value: true},
+			},
+			want: `{"v":1.0,"o":{"x":1,"y":2},"ok":true}`,
+		},
+		{
+			name: "test 失败时整体不生效",
+			body: `{"v":1}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "add", Path: "/x", Value: 1},
+				{Op: "test", Path: "/v", Value: 2},
+			},
+			want:    `{"v":1}`,
+			wantErr: true,
+		},
+		{
+			name: "替换不存在的字段报错",
+			body: `{"v":1}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "replace", Path: "/missing", Value: 1},
+			},
+			want:    `{"v":1}`,
+			wantErr: true,
+		},
+		{
+			name: "数组下标越界报错",
+			body: `[1]`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "add", Path: "/2", Value: 1},
+			},
+			want:    `[1]`,
+			wantErr: true,
+		},
+		{
+			name: "替换根节点",
+			body: `{"v":1}`,
+			patches: []rulespec.JSONPatchOp{
+				{Op: "replace", Path: "", Value: []any{"<a>"}},
+			},
+			want: `["<a>"]`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -81,54 +155,31 @@ func TestPatchJSON(t *testing.T) {
 	}
 }
 
-func TestSetFormUrlencoded(t *testing.T) {
+func TestMergeJSON(t *testing.T) {
 	tests := []struct {
 		name  string
 		body  string
-		key   string
-		value string
+		patch any
 		want  string
 	}{
-		{"添加字段", "name=test", "age", "18", "age=18&name=test"},
-		{"覆盖字段", "name=test&age=10", "age", "18", "age=18&name=test"},
+		{"递归合并并删除 null 字段", `{"a":"b","c":{"d":"e","f":"g"}}`, `{"a":"z","c":{"f":null}}`, `{"a":"z","c":{"d":"e"}}`},
+		{"新增字段追加在末尾", `{"a":1}`, map[string]any{"b": []any{1}}, `{"a":1,"b":[1]}`},
+		{"数组整体替换", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"非对象补丁替换整个文档", `{"a":1}`, `["x"]`, `["x"]`},
+		{"原始内容不是对象", `[1]`, `{"a":1}`, `{"a":1}`},
+		{"空内容", ``, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := transformer.SetFormUrlencoded(tt.body, tt.key, tt.value)
-			if err != nil {
-				t.Errorf("unexpected error: %v", err)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("got %v, want %v", got, tt.want)
+			got, err := transformer.MergeJSON(tt.body, tt.patch)
+			if err != nil || got != tt.want {
+				t.Errorf("got %v, %v; want %v", got, err, tt.want)
 			}
 		})
 	}
-}
 
-func TestRemoveFormUrlencoded(t *testing.T) {
-	tests := []struct {
-		name string
-		body string
-		key  string
-		want string
-	}{
-		{"删除存在字段", "name=test&age=18", "age", "name=test"},
-		{"删除不存在字段", "name=test", "age", "name=test"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := transformer.RemoveFormUrlencoded(tt.body, tt.key)
-			if err != nil {
-				t.Errorf("unexpected error: %v", err)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("got %v, want %v", got, tt.want)
-			}
-		})
+	if got, err := transformer.MergeJSON(`{"a":1}`, `{bad`); err == nil || got != `{"a":1}` {
+		t.Errorf("invalid patch: got %v, %v", got, err)
 	}
 }
 
@@ -0,0 +1,198 @@
+package transformer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cdpnetool/pkg/domain"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// ErrBodyTooLarge 解压后的响应体超过上限
+var ErrBodyTooLarge = errors.New("transformer: decompressed body too large")
+
+// maxDecodedBodySize 解压后响应体的大小上限，防止压缩炸弹
+const maxDecodedBodySize = 64 << 20
+
+// metaCharsetSniffSize 在 HTML 开头查找 meta 字符集声明的范围，与浏览器预扫描一致
+const metaCharsetSniffSize = 1024
+
+// metaCharsetRe 匹配 <meta charset="x"> 与 <meta http-equiv="Content-Type" content="text/html; charset=x">
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([\w.:-]+)`)
+
+// BodyCodec 响应体原始的压缩方式与字符集，文本类行为修改解码后的 UTF-8 内容，再由 EncodeBody 写回
+type BodyCodec struct {
+	Decompressed string // 实际解压过的 Content-Encoding，为空表示响应体未压缩（GetResponseBody 通常已由浏览器解压）
+	Charset      string // 原始字符集的 WHATWG 名称，为空表示 UTF-8 或无需转码
+	Raw          bool   // 解压失败，响应体保持原样，写回时保留 Content-Encoding
+
+	enc encoding.Encoding
+}
+
+// DecodeResponseBody 将响应体解码为 UTF-8 文本
+// 先按 Content-Encoding 解压（gzip、deflate、br，看起来未压缩的内容原样保留），
+// 非二进制类型再按 Content-Type 的 charset 或 HTML meta 声明转码（GBK、Shift_JIS、ISO-8859-1 等）
+// 解码失败时返回原始响应体与错误，返回的 BodyCodec 始终可用于写回
+func DecodeResponseBody(headers domain.Header, body []byte) ([]byte, *BodyCodec, error) {
+	codec := &BodyCodec{}
+	if ce := headers.Get("Content-Encoding"); ce != "" && len(body) > 0 {
+		decoded, applied, err := decompress(body, ce)
+		if err != nil {
+			codec.Raw = true
+			return body, codec, err
+		}
+		body, codec.Decompressed = decoded, applied
+	}
+
+	// 响应体为空时仍记录字符集，供 setBody 等行为写入的内容按原字符集编码
+	ct := headers.Get("Content-Type")
+	if IsBinaryContentType(ct) {
+		return body, codec, nil
+	}
+	name, enc := detectCharset(ct, body)
+	if enc == nil {
+		return body, codec, nil
+	}
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, codec, err
+	}
+	codec.Charset, codec.enc = name, enc
+	return text, codec, nil
+}
+
+// EncodeBody 将修改后的 UTF-8 内容按原字符集编码并修正响应头
+// 内容含原字符集无法表示的字符时改为 UTF-8 并更新 Content-Type 的 charset；
+// 响应体以未压缩形式返回，移除 Content-Encoding；存在 Content-Length 时按实际长度更新
+func (c *BodyCodec) EncodeBody(headers *domain.Header, body []byte) []byte {
+	if c.enc != nil {
+		if encoded, err := c.enc.NewEncoder().Bytes(body); err == nil {
+			body = encoded
+		} else {
+			headers.Set("Content-Type", withCharset(headers.Get("Content-Type"), "utf-8"))
+		}
+	}
+	if c.Raw {
+		setContentLength(headers, len(body))
+		return body
+	}
+	FixDecodedHeaders(headers, len(body))
+	return body
+}
+
+// FixDecodedHeaders 已由浏览器解压的响应体通过 FulfillRequest 原样返回时修正响应头
+// 移除 Content-Encoding，存在 Content-Length 时按实际长度更新
+func FixDecodedHeaders(headers *domain.Header, bodyLen int) {
+	headers.Del("Content-Encoding")
+	setContentLength(headers, bodyLen)
+}
+
+// setContentLength 存在 Content-Length 时按实际长度更新
+func setContentLength(headers *domain.Header, n int) {
+	if headers.Has("Content-Length") {
+		headers.Set("Content-Length", strconv.Itoa(n))
+	}
+}
+
+// decompress 按 Content-Encoding 从外到内逐层解压，返回实际解压过的编码
+// gzip 与 zlib 格式的 deflate 可按头部识别，不符合时视为已解压；raw deflate 与 br 没有标识，解压失败时视为已解压
+func decompress(body []byte, contentEncoding string) ([]byte, string, error) {
+	codings := strings.Split(contentEncoding, ",")
+	var applied []string
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var r io.Reader
+		strict := true
+		switch coding {
+		case "gzip", "x-gzip":
+			if len(body) < 2 || body[0] != 0x1f || body[1] != 0x8b {
+				return body, strings.Join(applied, ", "), nil
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, "", err
+			}
+			r = zr
+		case "deflate":
+			if isZlib(body) {
+				zr, err := zlib.NewReader(bytes.NewReader(body))
+				if err != nil {
+					return nil, "", err
+				}
+				r = zr
+			} else {
+				r, strict = flate.NewReader(bytes.NewReader(body)), false
+			}
+		case "br":
+			r, strict = brotli.NewReader(bytes.NewReader(body)), false
+		default:
+			continue
+		}
+
+		out, err := io.ReadAll(io.LimitReader(r, maxDecodedBodySize+1))
+		if err == nil && len(out) > maxDecodedBodySize {
+			return nil, "", ErrBodyTooLarge
+		}
+		if err != nil {
+			if strict {
+				return nil, "", err
+			}
+			return body, strings.Join(applied, ", "), nil
+		}
+		body = out
+		applied = append([]string{coding}, applied...)
+	}
+	return body, strings.Join(applied, ", "), nil
+}
+
+// isZlib 判断是否为 zlib 数据头（CM=8 且 FCHECK 校验通过）
+func isZlib(b []byte) bool {
+	return len(b) >= 2 && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// detectCharset 按 Content-Type 的 charset 参数确定字符集，HTML 缺省时查找开头的 meta 声明
+// 无法识别或为 UTF-8 时返回 nil
+func detectCharset(contentType string, body []byte) (string, encoding.Encoding) {
+	label := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		label = params["charset"]
+	}
+	if label == "" && strings.Contains(strings.ToLower(contentType), "html") {
+		if m := metaCharsetRe.FindSubmatch(body[:min(len(body), metaCharsetSniffSize)]); m != nil {
+			label = string(m[1])
+		}
+	}
+	if label == "" {
+		return "", nil
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return "", nil
+	}
+	name, _ := htmlindex.Name(enc)
+	if name == "utf-8" {
+		return "", nil
+	}
+	return name, enc
+}
+
+// withCharset 设置 Content-Type 的 charset 参数
+func withCharset(contentType, charset string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "text/plain; charset=" + charset
+	}
+	params["charset"] = charset
+	return mime.FormatMediaType(mediaType, params)
+}
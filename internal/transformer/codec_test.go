@@ -0,0 +1,140 @@
+package transformer_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/domain"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// compress 按指定方式压缩测试数据
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encode 将 UTF-8 文本编码为测试所需的字符集
+func encode(t *testing.T, text string, gbk bool) []byte {
+	t.Helper()
+	enc := japanese.ShiftJIS.NewEncoder()
+	if gbk {
+		enc = simplifiedchinese.GBK.NewEncoder()
+	}
+	out, err := enc.Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func headers(kv ...string) domain.Header {
+	var h domain.Header
+	for i := 0; i+1 < len(kv); i += 2 {
+		h.Add(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestDecodeResponseBody(t *testing.T) {
+	text := `{"msg":"你好，世界"}`
+	tests := []struct {
+		name         string
+		headers      domain.Header
+		body         []byte
+		want         string
+		decompressed string
+		charset      string
+	}{
+		{"gzip", headers("Content-Encoding", "gzip"), compress(t, "gzip", []byte(text)), text, "gzip", ""},
+		{"zlib 格式的 deflate", headers("Content-Encoding", "deflate"), compress(t, "zlib", []byte(text)), text, "deflate", ""},
+		{"raw deflate", headers("Content-Encoding", "deflate"), compress(t, "raw-deflate", []byte(text)), text, "deflate", ""},
+		{"br", headers("Content-Encoding", "br"), compress(t, "br", []byte(text)), text, "br", ""},
+		{"多层压缩", headers("Content-Encoding", "gzip, br"), compress(t, "br", compress(t, "gzip", []byte(text))), text, "gzip, br", ""},
+		{"已由浏览器解压", headers("Content-Encoding", "gzip"), []byte(text), text, "", ""},
+		{"br 已由浏览器解压", headers("Content-Encoding", "br"), []byte(text), text, "", ""},
+		{"GBK", headers("Content-Type", "text/html; charset=GBK"), encode(t, "<p>中文页面</p>", true), "<p>中文页面</p>", "", "gbk"},
+		{"HTML meta 声明字符集", headers("Content-Type", "text/html"), encode(t, `<meta charset="gb2312"><p>中文</p>`, true), `<meta charset="gb2312"><p>中文</p>`, "", "gbk"},
+		{"Shift_JIS", headers("Content-Type", "text/plain; charset=Shift_JIS"), encode(t, "日本語", false), "日本語", "", "shift_jis"},
+		{"ISO-8859-1", headers("Content-Type", "text/plain; charset=ISO-8859-1"), []byte("caf\xe9"), "café", "", "windows-1252"},
+		{"压缩的 GBK", headers("Content-Type", "text/html; charset=gbk", "Content-Encoding", "gzip"), compress(t, "gzip", encode(t, "中文", true)), "中文", "gzip", "gbk"},
+		{"UTF-8 不转码", headers("Content-Type", "application/json; charset=utf-8"), []byte(text), text, "", ""},
+		{"二进制类型不转码", headers("Content-Type", "image/png; charset=gbk"), []byte("\x89PNG"), "\x89PNG", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, codec, err := transformer.DecodeResponseBody(tt.headers, tt.body)
+			if err != nil {
+				t.Fatalf("DecodeResponseBody: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got body %q, want %q", got, tt.want)
+			}
+			if codec.Decompressed != tt.decompressed || codec.Charset != tt.charset {
+				t.Errorf("got codec %+v, want decompressed %q charset %q", codec, tt.decompressed, tt.charset)
+			}
+		})
+	}
+
+	corrupt := compress(t, "gzip", []byte(text))[:12]
+	body, codec, err := transformer.DecodeResponseBody(headers("Content-Encoding", "gzip"), corrupt)
+	if err == nil || !codec.Raw || !bytes.Equal(body, corrupt) {
+		t.Errorf("corrupt gzip: got %q, %+v, %v", body, codec, err)
+	}
+}
+
+func TestBodyCodec_EncodeBody(t *testing.T) {
+	h := headers("Content-Type", "text/html; charset=gbk", "Content-Encoding", "gzip", "Content-Length", "10")
+	_, codec, err := transformer.DecodeResponseBody(h, compress(t, "gzip", encode(t, "旧标题", true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := codec.EncodeBody(&h, []byte("新标题"))
+	if !bytes.Equal(out, encode(t, "新标题", true)) {
+		t.Errorf("body should be re-encoded as GBK, got %q", out)
+	}
+	if h.Has("Content-Encoding") || h.Get("Content-Length") != "6" || h.Get("Content-Type") != "text/html; charset=gbk" {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	// GBK 无法表示的字符改用 UTF-8 返回
+	out = codec.EncodeBody(&h, []byte("标题 😀"))
+	if string(out) != "标题 😀" || h.Get("Content-Type") != "text/html; charset=utf-8" || h.Get("Content-Length") != "11" {
+		t.Errorf("got %q, headers %v", out, h)
+	}
+
+	// 解压失败时保留 Content-Encoding
+	raw := headers("Content-Encoding", "gzip")
+	_, codec, _ = transformer.DecodeResponseBody(raw, []byte{0x1f, 0x8b, 0})
+	codec.EncodeBody(&raw, []byte{0x1f, 0x8b, 0})
+	if raw.Get("Content-Encoding") != "gzip" {
+		t.Errorf("raw body should keep Content-Encoding, got %v", raw)
+	}
+}
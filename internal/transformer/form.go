@@ -0,0 +1,376 @@
+package transformer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"cdpnetool/pkg/domain"
+)
+
+// ErrNotMultipart 对非 multipart/form-data 表单执行文件操作
+var ErrNotMultipart = errors.New("transformer: form is not multipart/form-data")
+
+const (
+	mimeFormUrlencoded = "application/x-www-form-urlencoded"
+	mimeMultipartForm  = "multipart/form-data"
+)
+
+// Form 可编辑的表单请求体，支持 application/x-www-form-urlencoded 与 multipart/form-data
+// 未被修改的字段与 multipart 部分在重建时保持原始字节
+type Form struct {
+	contentType string
+	multipart   bool
+
+	fields domain.Query // urlencoded 字段
+
+	boundary string
+	preamble []byte      // 第一个分隔符之前的内容
+	epilogue []byte      // 结束分隔符之后的内容
+	parts    []*formPart // multipart 部分
+}
+
+// formPart multipart 表单中的单个部分
+type formPart struct {
+	headers     []formHeader // 部分头，保持原始顺序
+	rawHeader   []byte       // 原始头部字节，部分头未修改时原样写回
+	content     []byte
+	name        string
+	filename    string
+	hasFilename bool
+}
+
+// formHeader 部分头中的单行
+type formHeader struct {
+	name  string
+	value string
+}
+
+// IsFormContentType 判断 Content-Type 是否为表单类型
+func IsFormContentType(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == mimeFormUrlencoded || mt == mimeMultipartForm
+}
+
+// ParseForm 按 Content-Type 解析表单请求体
+// multipart/form-data 以外的类型（包括为空）均按 urlencoded 解析
+func ParseForm(contentType string, body []byte) (*Form, error) {
+	f := &Form{contentType: contentType}
+	mt, params, _ := mime.ParseMediaType(contentType)
+	if mt != mimeMultipartForm {
+		f.fields = parseRawQuery(string(body))
+		return f, nil
+	}
+
+	f.multipart = true
+	f.boundary = params["boundary"]
+	if f.boundary == "" {
+		return nil, errors.New("transformer: multipart form without boundary")
+	}
+	if err := f.parseParts(body); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseParts 按分隔符拆分 multipart 请求体，记录每个部分的原始字节
+func (f *Form) parseParts(body []byte) error {
+	delim := []byte("--" + f.boundary)
+	start := bytes.Index(body, delim)
+	if start == -1 {
+		return errors.New("transformer: multipart boundary not found in body")
+	}
+	f.preamble = body[:start]
+	rest := body[start+len(delim):]
+
+	for {
+		if bytes.HasPrefix(rest, []byte("--")) {
+			f.epilogue = rest[2:]
+			return nil
+		}
+		rest = trimLineBreak(rest)
+		end := bytes.Index(rest, append([]byte("\r\n"), delim...))
+		sepLen := 2
+		if end == -1 {
+			end = bytes.Index(rest, append([]byte("\n"), delim...))
+			sepLen = 1
+		}
+		if end == -1 {
+			return errors.New("transformer: multipart closing boundary not found")
+		}
+		part, err := parsePart(rest[:end])
+		if err != nil {
+			return err
+		}
+		f.parts = append(f.parts, part)
+		rest = rest[end+sepLen+len(delim):]
+	}
+}
+
+// parsePart 解析单个部分的头与内容
+func parsePart(raw []byte) (*formPart, error) {
+	header, content, ok := bytes.Cut(raw, []byte("\r\n\r\n"))
+	if !ok {
+		if header, content, ok = bytes.Cut(raw, []byte("\n\n")); !ok {
+			return nil, errors.New("transformer: multipart part without header terminator")
+		}
+	}
+	p := &formPart{rawHeader: header, content: content}
+	for _, line := range strings.Split(string(header), "\n") {
+		line = strings.TrimRight(line, "\r")
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		p.headers = append(p.headers, formHeader{name: name, value: strings.TrimSpace(value)})
+	}
+	if _, params, err := mime.ParseMediaType(p.header("Content-Disposition")); err == nil {
+		p.name = params["name"]
+		p.filename, p.hasFilename = params["filename"]
+	}
+	return p, nil
+}
+
+// trimLineBreak 去除分隔符后的换行
+func trimLineBreak(b []byte) []byte {
+	if bytes.HasPrefix(b, []byte("\r\n")) {
+		return b[2:]
+	}
+	return bytes.TrimPrefix(b, []byte("\n"))
+}
+
+// header 获取部分头的值（名称不区分大小写）
+func (p *formPart) header(name string) string {
+	for _, h := range p.headers {
+		if strings.EqualFold(h.name, name) {
+			return h.value
+		}
+	}
+	return ""
+}
+
+// setHeader 设置部分头并丢弃原始头部字节
+func (p *formPart) setHeader(name, value string) {
+	p.rawHeader = nil
+	for i, h := range p.headers {
+		if strings.EqualFold(h.name, name) {
+			p.headers[i].value = value
+			return
+		}
+	}
+	p.headers = append(p.headers, formHeader{name: name, value: value})
+}
+
+// updateDisposition 根据字段名与文件名重建 Content-Disposition
+func (p *formPart) updateDisposition() {
+	v := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.name))
+	if p.hasFilename {
+		v += fmt.Sprintf(`; filename="%s"`, escapeQuotes(p.filename))
+	}
+	p.setHeader("Content-Disposition", v)
+}
+
+// IsMultipart 判断是否为 multipart/form-data 表单
+func (f *Form) IsMultipart() bool {
+	return f.multipart
+}
+
+// Has 判断字段是否存在
+func (f *Form) Has(name string) bool {
+	if !f.multipart {
+		return f.fields.Has(name)
+	}
+	for _, p := range f.parts {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Values 按出现顺序获取字段的全部值，文件部分返回其文件名
+func (f *Form) Values(name string) []string {
+	if !f.multipart {
+		return f.fields.Values(name)
+	}
+	var values []string
+	for _, p := range f.parts {
+		if p.name != name {
+			continue
+		}
+		if p.hasFilename {
+			values = append(values, p.filename)
+		} else {
+			values = append(values, string(p.content))
+		}
+	}
+	return values
+}
+
+// SetField 设置文本字段，替换全部同名字段（包括文件部分）
+// 已存在时保留第一个字段的位置，否则追加到末尾
+func (f *Form) SetField(name, value string) {
+	if !f.multipart {
+		f.fields.Set(name, value)
+		return
+	}
+	first := f.replaceParts(name)
+	if first == nil {
+		first = &formPart{name: name}
+		f.parts = append(f.parts, first)
+	}
+	if first.hasFilename || first.header("Content-Type") != "" || first.rawHeader == nil {
+		first.hasFilename = false
+		first.headers = nil
+		first.updateDisposition()
+	}
+	first.content = []byte(value)
+}
+
+// RemoveField 删除字段的全部值
+func (f *Form) RemoveField(name string) {
+	if !f.multipart {
+		f.fields.Del(name)
+		return
+	}
+	out := f.parts[:0]
+	for _, p := range f.parts {
+		if p.name != name {
+			out = append(out, p)
+		}
+	}
+	f.parts = out
+}
+
+// FilePatch 文件部分的修改内容，为空的字段保持原值
+type FilePatch struct {
+	Content     []byte // 新的文件内容，nil 表示不修改
+	Filename    string // 新的文件名
+	ContentType string // 新的内容类型
+}
+
+// SetFile 修改文件部分的内容、文件名或内容类型，同名的其余部分会被移除
+// 字段不存在时追加新的文件部分，文件名默认为字段名，内容类型默认为 application/octet-stream
+func (f *Form) SetFile(name string, patch FilePatch) error {
+	if !f.multipart {
+		return ErrNotMultipart
+	}
+	p := f.replaceParts(name)
+	if p == nil {
+		p = &formPart{name: name, filename: name, hasFilename: true}
+		f.parts = append(f.parts, p)
+		p.updateDisposition()
+		p.setHeader("Content-Type", "application/octet-stream")
+	}
+	if patch.Filename != "" || !p.hasFilename {
+		if patch.Filename != "" {
+			p.filename = patch.Filename
+		} else if p.filename == "" {
+			p.filename = name
+		}
+		p.hasFilename = true
+		p.updateDisposition()
+	}
+	if patch.ContentType != "" {
+		p.setHeader("Content-Type", patch.ContentType)
+	}
+	if patch.Content != nil {
+		p.content = patch.Content
+	}
+	return nil
+}
+
+// replaceParts 移除同名的重复部分，返回保留的第一个部分（不存在时返回 nil）
+func (f *Form) replaceParts(name string) *formPart {
+	var first *formPart
+	out := f.parts[:0]
+	for _, p := range f.parts {
+		if p.name != name {
+			out = append(out, p)
+			continue
+		}
+		if first == nil {
+			first = p
+			out = append(out, p)
+		}
+	}
+	f.parts = out
+	return first
+}
+
+// ContentType 返回与请求体一致的 Content-Type（multipart 分隔符可能在重建时变化）
+func (f *Form) ContentType() string {
+	if !f.multipart {
+		return f.contentType
+	}
+	mt, params, err := mime.ParseMediaType(f.contentType)
+	if err != nil {
+		mt, params = mimeMultipartForm, map[string]string{}
+	}
+	if params["boundary"] == f.boundary {
+		return f.contentType
+	}
+	params["boundary"] = f.boundary
+	return mime.FormatMediaType(mt, params)
+}
+
+// Bytes 重建表单请求体
+// multipart 部分的内容与当前分隔符冲突时会生成新的分隔符，需同步更新 Content-Type
+func (f *Form) Bytes() []byte {
+	if !f.multipart {
+		return []byte(BuildQueryString(f.fields))
+	}
+	for f.conflicts() {
+		f.boundary = randomBoundary()
+	}
+
+	var buf bytes.Buffer
+	buf.Write(f.preamble)
+	delim := "--" + f.boundary
+	for _, p := range f.parts {
+		buf.WriteString(delim)
+		buf.WriteString("\r\n")
+		if p.rawHeader != nil {
+			buf.Write(p.rawHeader)
+		} else {
+			for i, h := range p.headers {
+				if i > 0 {
+					buf.WriteString("\r\n")
+				}
+				buf.WriteString(h.name + ": " + h.value)
+			}
+		}
+		buf.WriteString("\r\n\r\n")
+		buf.Write(p.content)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString(delim + "--")
+	buf.Write(f.epilogue)
+	return buf.Bytes()
+}
+
+// conflicts 判断是否有部分内容包含当前分隔符
+func (f *Form) conflicts() bool {
+	delim := []byte("--" + f.boundary)
+	for _, p := range f.parts {
+		if bytes.Contains(p.content, delim) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomBoundary 生成随机 multipart 分隔符
+func randomBoundary() string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return fmt.Sprintf("----cdpnetool%x", buf[:])
+}
+
+// escapeQuotes 转义 Content-Disposition 参数中的引号与反斜杠
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
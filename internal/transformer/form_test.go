@@ -0,0 +1,233 @@
+package transformer_test
+
+import (
+	"errors"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cdpnetool/internal/transformer"
+)
+
+const (
+	testBoundary = "----WebKitFormBoundaryABC123"
+	testFormCT   = "multipart/form-data; boundary=" + testBoundary
+)
+
+// multipartBody 按浏览器格式拼接 multipart 请求体
+func multipartBody(parts ...string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString("--" + testBoundary + "\r\n" + p + "\r\n")
+	}
+	b.WriteString("--" + testBoundary + "--\r\n")
+	return b.String()
+}
+
+const (
+	partTitle  = "Content-Disposition: form-data; name=\"title\"\r\n\r\nhello"
+	partAvatar = "Content-Disposition: form-data; name=\"avatar\"; filename=\"a.png\"\r\nContent-Type: image/png\r\n\r\n\x89PNG"
+	partTag1   = "Content-Disposition: form-data; name=\"tag\"\r\n\r\nx"
+	partTag2   = "Content-Disposition: form-data; name=\"tag\"\r\n\r\ny"
+)
+
+// readParts 使用标准库解析 multipart 请求体，验证输出格式合法
+func readParts(t *testing.T, contentType string, body []byte) map[string][]string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+	r := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+	out := map[string][]string{}
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		buf := new(strings.Builder)
+		data := make([]byte, 1024)
+		for {
+			n, err := p.Read(data)
+			buf.Write(data[:n])
+			if err != nil {
+				break
+			}
+		}
+		desc := buf.String()
+		if p.FileName() != "" {
+			desc = p.FileName() + "|" + p.Header.Get("Content-Type") + "|" + desc
+		}
+		out[p.FormName()] = append(out[p.FormName()], desc)
+	}
+	return out
+}
+
+func TestParseForm_Multipart(t *testing.T) {
+	body := multipartBody(partTitle, partAvatar, partTag1, partTag2)
+	f, err := transformer.ParseForm(testFormCT, []byte(body))
+	if err != nil {
+		t.Fatalf("ParseForm: %v", err)
+	}
+	if !f.IsMultipart() {
+		t.Fatal("expected multipart form")
+	}
+	if got := f.Values("title"); !reflect.DeepEqual(got, []string{"hello"}) {
+		t.Errorf("title = %v", got)
+	}
+	if got := f.Values("avatar"); !reflect.DeepEqual(got, []string{"a.png"}) {
+		t.Errorf("file field should report filename, got %v", got)
+	}
+	if got := f.Values("tag"); !reflect.DeepEqual(got, []string{"x", "y"}) {
+		t.Errorf("tag = %v", got)
+	}
+	if f.Has("missing") {
+		t.Error("missing field should not exist")
+	}
+	if string(f.Bytes()) != body {
+		t.Errorf("untouched form should rebuild byte-identical:\n%q\n%q", f.Bytes(), body)
+	}
+}
+
+func TestForm_MultipartEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(f *transformer.Form) error
+		want map[string][]string
+	}{
+		{
+			name: "设置文本字段",
+			edit: func(f *transformer.Form) error { f.SetField("title", "world"); return nil },
+			want: map[string][]string{"title": {"world"}, "avatar": {"a.png|image/png|\x89PNG"}, "tag": {"x", "y"}},
+		},
+		{
+			name: "新增字段并合并重复字段",
+			edit: func(f *transformer.Form) error { f.SetField("tag", "z"); f.SetField("new", "1"); return nil },
+			want: map[string][]string{"title": {"hello"}, "avatar": {"a.png|image/png|\x89PNG"}, "tag": {"z"}, "new": {"1"}},
+		},
+		{
+			name: "移除字段",
+			edit: func(f *transformer.Form) error { f.RemoveField("tag"); return nil },
+			want: map[string][]string{"title": {"hello"}, "avatar": {"a.png|image/png|\x89PNG"}},
+		},
+		{
+			name: "替换文件内容",
+			edit: func(f *transformer.Form) error {
+				return f.SetFile("avatar", transformer.FilePatch{Content: []byte("GIF89a")})
+			},
+			want: map[string][]string{"title": {"hello"}, "avatar": {"a.png|image/png|GIF89a"}, "tag": {"x", "y"}},
+		},
+		{
+			name: "修改文件名与内容类型",
+			edit: func(f *transformer.Form) error {
+				return f.SetFile("avatar", transformer.FilePatch{Filename: "b \"c\".gif", ContentType: "image/gif"})
+			},
+			want: map[string][]string{"title": {"hello"}, "avatar": {"b \"c\".gif|image/gif|\x89PNG"}, "tag": {"x", "y"}},
+		},
+		{
+			name: "新增文件字段",
+			edit: func(f *transformer.Form) error {
+				return f.SetFile("doc", transformer.FilePatch{Content: []byte("%PDF")})
+			},
+			want: map[string][]string{"title": {"hello"}, "avatar": {"a.png|image/png|\x89PNG"}, "tag": {"x", "y"}, "doc": {"doc|application/octet-stream|%PDF"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := transformer.ParseForm(testFormCT, []byte(multipartBody(partTitle, partAvatar, partTag1, partTag2)))
+			if err != nil {
+				t.Fatalf("ParseForm: %v", err)
+			}
+			if err := tt.edit(f); err != nil {
+				t.Fatalf("edit: %v", err)
+			}
+			body := f.Bytes()
+			if f.ContentType() != testFormCT {
+				t.Errorf("content type changed unexpectedly: %s", f.ContentType())
+			}
+			if got := readParts(t, f.ContentType(), body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForm_MultipartUntouchedPartsPreserved(t *testing.T) {
+	f, _ := transformer.ParseForm(testFormCT, []byte(multipartBody(partTitle, partAvatar)))
+	f.SetField("title", "changed")
+	if !strings.Contains(string(f.Bytes()), partAvatar) {
+		t.Error("untouched part should keep its original bytes")
+	}
+}
+
+func TestForm_BoundaryConflict(t *testing.T) {
+	f, _ := transformer.ParseForm(testFormCT, []byte(multipartBody(partTitle)))
+	payload := "prefix\r\n--" + testBoundary + "\r\nsuffix"
+	f.SetField("title", payload)
+
+	body := f.Bytes()
+	ct := f.ContentType()
+	if ct == testFormCT {
+		t.Fatal("boundary should change when content contains it")
+	}
+	if got := readParts(t, ct, body); !reflect.DeepEqual(got, map[string][]string{"title": {payload}}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestForm_Urlencoded(t *testing.T) {
+	f, err := transformer.ParseForm("application/x-www-form-urlencoded; charset=UTF-8", []byte("b=2&a=x+y&c=%2F"))
+	if err != nil {
+		t.Fatalf("ParseForm: %v", err)
+	}
+	if f.IsMultipart() || !f.Has("a") || f.Values("a")[0] != "x y" {
+		t.Fatalf("unexpected urlencoded parse")
+	}
+	f.SetField("b", "3")
+	f.RemoveField("c")
+	if got := string(f.Bytes()); got != "b=3&a=x+y" {
+		t.Errorf("got %s", got)
+	}
+	if err := f.SetFile("a", transformer.FilePatch{}); !errors.Is(err, transformer.ErrNotMultipart) {
+		t.Errorf("SetFile on urlencoded form: got %v, want ErrNotMultipart", err)
+	}
+}
+
+func TestParseForm_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		ct   string
+		body string
+	}{
+		{"缺少 boundary", "multipart/form-data", "x"},
+		{"请求体中没有 boundary", testFormCT, "plain"},
+		{"缺少结束分隔符", testFormCT, "--" + testBoundary + "\r\n" + partTitle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := transformer.ParseForm(tt.ct, []byte(tt.body)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestIsFormContentType(t *testing.T) {
+	tests := []struct {
+		ct   string
+		want bool
+	}{
+		{"application/x-www-form-urlencoded", true},
+		{testFormCT, true},
+		{"application/json", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := transformer.IsFormContentType(tt.ct); got != tt.want {
+			t.Errorf("IsFormContentType(%q) = %v, want %v", tt.ct, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package transformer
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"cdpnetool/pkg/rulespec"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+var (
+	// ErrNotGraphQL 请求体不是 JSON 形式的 GraphQL 请求
+	ErrNotGraphQL = errors.New("transformer: body is not a GraphQL request")
+	// ErrPartialBatch 批量请求中只有部分操作需要伪造，无法在请求阶段直接返回
+	ErrPartialBatch = errors.New("transformer: only part of the GraphQL batch matches, mock it in the response stage")
+)
+
+// GraphQL 操作类型
+const (
+	GraphQLQuery        = "query"
+	GraphQLMutation     = "mutation"
+	GraphQLSubscription = "subscription"
+)
+
+// GraphQLOperation GraphQL 请求中的单个操作
+type GraphQLOperation struct {
+	Name      string // 操作名，operationName 缺省时取自查询文档
+	Type      string // 操作类型：query、mutation、subscription，无查询文本（如持久化查询）时为空
+	Variables []byte // variables 的原始 JSON，缺省时为 nil
+}
+
+// GraphQLRequest 解析后的 GraphQL 请求体
+type GraphQLRequest struct {
+	Batched    bool // 是否为批量请求（JSON 数组）
+	Operations []GraphQLOperation
+}
+
+// ParseGraphQL 解析 JSON 形式的 GraphQL 请求体，支持批量数组
+// 对象需包含 query、operationName 或 extensions.persistedQuery 之一，否则返回 ErrNotGraphQL
+func ParseGraphQL(body []byte) (*GraphQLRequest, error) {
+	if !gjson.ValidBytes(body) {
+		return nil, ErrNotGraphQL
+	}
+	root := gjson.ParseBytes(body)
+	gql := &GraphQLRequest{}
+	switch {
+	case root.IsObject():
+		op, ok := parseGraphQLOperation(root)
+		if !ok {
+			return nil, ErrNotGraphQL
+		}
+		gql.Operations = []GraphQLOperation{op}
+	case root.IsArray():
+		gql.Batched = true
+		for _, elem := range root.Array() {
+			op, ok := parseGraphQLOperation(elem)
+			if !ok {
+				return nil, ErrNotGraphQL
+			}
+			gql.Operations = append(gql.Operations, op)
+		}
+		if len(gql.Operations) == 0 {
+			return nil, ErrNotGraphQL
+		}
+	default:
+		return nil, ErrNotGraphQL
+	}
+	return gql, nil
+}
+
+// parseGraphQLOperation 解析单个操作对象
+func parseGraphQLOperation(obj gjson.Result) (GraphQLOperation, bool) {
+	if !obj.IsObject() {
+		return GraphQLOperation{}, false
+	}
+	query := obj.Get("query")
+	name := obj.Get("operationName")
+	if query.Type != gjson.String && !name.Exists() && !obj.Get("extensions.persistedQuery").Exists() {
+		return GraphQLOperation{}, false
+	}
+
+	op := GraphQLOperation{Name: name.String()}
+	if query.Type == gjson.String {
+		if def, ok := selectGraphQLDefinition(query.Str, op.Name); ok {
+			op.Type = def.typ
+			if op.Name == "" {
+				op.Name = def.name
+			}
+		}
+	}
+	if v := obj.Get("variables"); v.Exists() && v.Type != gjson.Null {
+		op.Variables = []byte(v.Raw)
+	}
+	return op, true
+}
+
+// Match 返回名称匹配的操作下标，name 为空时匹配全部操作
+func (r *GraphQLRequest) Match(name string) []int {
+	var indexes []int
+	for i, op := range r.Operations {
+		if name == "" || op.Name == name {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// operationPath 返回操作在请求体或响应体中的 gjson 路径前缀
+func (r *GraphQLRequest) operationPath(index int, field string) string {
+	if !r.Batched {
+		return field
+	}
+	return strconv.Itoa(index) + "." + field
+}
+
+// PatchGraphQLVariables 对指定操作的 variables 应用 JSON Patch，variables 缺省时视为空对象
+// 任一操作失败时返回原始请求体，gql 中解析出的 variables 仅在全部成功后更新
+func PatchGraphQLVariables(body []byte, gql *GraphQLRequest, indexes []int, patches []rulespec.JSONPatchOp) ([]byte, error) {
+	out := string(body)
+	patched := make([]string, len(indexes))
+	for n, i := range indexes {
+		vars := string(gql.Operations[i].Variables)
+		if vars == "" {
+			vars = "{}"
+		}
+		var err error
+		if patched[n], err = PatchJSON(vars, patches); err != nil {
+			return body, err
+		}
+		if out, err = sjson.SetRaw(out, gql.operationPath(i, "variables"), patched[n]); err != nil {
+			return body, err
+		}
+	}
+	for n, i := range indexes {
+		gql.Operations[i].Variables = []byte(patched[n])
+	}
+	return []byte(out), nil
+}
+
+// GraphQLResult 构造单个操作的结果 {"data": ..., "errors": [...]}
+// errs 为字符串时视为单条错误信息，为 nil 时不输出 errors 字段
+func GraphQLResult(data, errs any) ([]byte, error) {
+	dataRaw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	out, _ := sjson.SetRaw(`{}`, "data", string(dataRaw))
+	if errs == nil {
+		return []byte(out), nil
+	}
+	if msg, ok := errs.(string); ok {
+		errs = []map[string]string{{"message": msg}}
+	}
+	errsRaw, err := json.Marshal(errs)
+	if err != nil {
+		return nil, err
+	}
+	out, err = sjson.SetRaw(out, "errors", string(errsRaw))
+	return []byte(out), err
+}
+
+// MockGraphQLRequest 为请求阶段构造完整的伪造响应体
+// 批量请求中的全部操作都需命中，否则返回 ErrPartialBatch
+func MockGraphQLRequest(gql *GraphQLRequest, indexes []int, result []byte) ([]byte, error) {
+	if len(indexes) != len(gql.Operations) {
+		return nil, ErrPartialBatch
+	}
+	if !gql.Batched {
+		return result, nil
+	}
+	items := make([]string, len(indexes))
+	for i := range items {
+		items[i] = string(result)
+	}
+	return []byte("[" + strings.Join(items, ",") + "]"), nil
+}
+
+// MockGraphQLResponse 将响应体中指定操作的结果替换为 result，保留其余操作的结果
+// 非批量请求的响应体不是合法 JSON 时直接以 result 作为响应体
+func MockGraphQLResponse(resBody []byte, gql *GraphQLRequest, indexes []int, result []byte) ([]byte, error) {
+	if !gql.Batched {
+		if !gjson.ValidBytes(resBody) || !gjson.ParseBytes(resBody).IsObject() {
+			return result, nil
+		}
+	} else if !gjson.ValidBytes(resBody) || !gjson.ParseBytes(resBody).IsArray() {
+		return resBody, errors.New("transformer: batched GraphQL response is not a JSON array")
+	}
+
+	out := string(resBody)
+	var err error
+	for _, i := range indexes {
+		if out, err = sjson.SetRaw(out, gql.operationPath(i, "data"), gjson.GetBytes(result, "data").Raw); err != nil {
+			return resBody, err
+		}
+		if errs := gjson.GetBytes(result, "errors"); errs.Exists() {
+			out, err = sjson.SetRaw(out, gql.operationPath(i, "errors"), errs.Raw)
+		} else {
+			out, err = sjson.Delete(out, gql.operationPath(i, "errors"))
+		}
+		if err != nil {
+			return resBody, err
+		}
+	}
+	return []byte(out), nil
+}
+
+// graphqlDefinition 查询文档顶层的操作定义
+type graphqlDefinition struct {
+	typ  string
+	name string
+}
+
+// selectGraphQLDefinition 选取实际执行的操作定义：有操作名时按名称选取，否则取第一个操作
+func selectGraphQLDefinition(doc, name string) (graphqlDefinition, bool) {
+	defs := scanGraphQLDefinitions(doc)
+	for _, d := range defs {
+		if name == "" || d.name == name {
+			return d, true
+		}
+	}
+	return graphqlDefinition{}, false
+}
+
+// scanGraphQLDefinitions 扫描查询文档顶层的操作定义，忽略片段定义
+// 仅识别定义的类型与名称，不校验文档语法
+func scanGraphQLDefinitions(doc string) []graphqlDefinition {
+	var defs []graphqlDefinition
+	i := 0
+	for i < len(doc) {
+		i = skipGraphQLIgnored(doc, i)
+		if i >= len(doc) {
+			break
+		}
+		c := doc[i]
+		switch {
+		case c == '{':
+			// 简写形式的查询
+			defs = append(defs, graphqlDefinition{typ: GraphQLQuery})
+			i = skipGraphQLBalanced(doc, i)
+		case isGraphQLNameStart(c):
+			var keyword string
+			keyword, i = readGraphQLName(doc, i)
+			def := graphqlDefinition{typ: keyword}
+			if j := skipGraphQLIgnored(doc, i); j < len(doc) && isGraphQLNameStart(doc[j]) {
+				def.name, i = readGraphQLName(doc, j)
+			}
+			i = skipGraphQLDefinitionBody(doc, i)
+			switch keyword {
+			case GraphQLQuery, GraphQLMutation, GraphQLSubscription:
+				defs = append(defs, def)
+			}
+		default:
+			i++
+		}
+	}
+	return defs
+}
+
+// skipGraphQLDefinitionBody 跳过定义的参数、指令与选择集，返回选择集之后的位置
+func skipGraphQLDefinitionBody(doc string, i int) int {
+	for i < len(doc) {
+		i = skipGraphQLIgnored(doc, i)
+		if i >= len(doc) {
+			break
+		}
+		switch doc[i] {
+		case '{':
+			return skipGraphQLBalanced(doc, i)
+		case '(', '[':
+			i = skipGraphQLBalanced(doc, i)
+		case '"':
+			i = skipGraphQLString(doc, i)
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// skipGraphQLBalanced 跳过从 i 开始的成对括号，正确处理嵌套、字符串与注释
+func skipGraphQLBalanced(doc string, i int) int {
+	depth := 0
+	for i < len(doc) {
+		switch doc[i] {
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"':
+			i = skipGraphQLString(doc, i)
+			continue
+		case '#':
+			i = skipGraphQLComment(doc, i)
+			continue
+		}
+		i++
+	}
+	return i
+}
+
+// skipGraphQLIgnored 跳过空白、逗号与注释
+func skipGraphQLIgnored(doc string, i int) int {
+	for i < len(doc) {
+		switch doc[i] {
+		case ' ', '\t', '\n', '\r', ',':
+			i++
+		case '#':
+			i = skipGraphQLComment(doc, i)
+		default:
+			if strings.HasPrefix(doc[i:], "\uFEFF") {
+				i += len("\uFEFF")
+				continue
+			}
+			return i
+		}
+	}
+	return i
+}
+
+// skipGraphQLComment 跳过 # 开头的单行注释
+func skipGraphQLComment(doc string, i int) int {
+	if j := strings.IndexAny(doc[i:], "\r\n"); j != -1 {
+		return i + j
+	}
+	return len(doc)
+}
+
+// skipGraphQLString 跳过普通字符串或 """ 块字符串
+func skipGraphQLString(doc string, i int) int {
+	if strings.HasPrefix(doc[i:], `"""`) {
+		i += 3
+		for i < len(doc) {
+			if strings.HasPrefix(doc[i:], `\"""`) {
+				i += 4
+				continue
+			}
+			if strings.HasPrefix(doc[i:], `"""`) {
+				return i + 3
+			}
+			i++
+		}
+		return i
+	}
+	for i++; i < len(doc); i++ {
+		switch doc[i] {
+		case '\\':
+			i++
+		case '"', '\n':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// readGraphQLName 读取名称标记
+func readGraphQLName(doc string, i int) (string, int) {
+	start := i
+	for i < len(doc) && (isGraphQLNameStart(doc[i]) || doc[i] >= '0' && doc[i] <= '9') {
+		i++
+	}
+	return doc[start:i], i
+}
+
+// isGraphQLNameStart 判断字符能否作为名称的开头
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
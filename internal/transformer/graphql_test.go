@@ -0,0 +1,183 @@
+package transformer_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/rulespec"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		batched bool
+		want    []transformer.GraphQLOperation
+	}{
+		{
+			name: "带操作名与变量",
+			body: `{"operationName":"GetUser","query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`,
+			want: []transformer.GraphQLOperation{{Name: "GetUser", Type: "query", Variables: []byte(`{"id":"1"}`)}},
+		},
+		{
+			name: "缺省操作名时取自文档",
+			body: `{"query":"# comment\nmutation UpdateUser($input: Input = {name: \"a}\"}) { update(input: $input) { id } }"}`,
+			want: []transformer.GraphQLOperation{{Name: "UpdateUser", Type: "mutation"}},
+		},
+		{
+			name: "简写查询",
+			body: `{"query":"{ viewer { id } }","variables":null}`,
+			want: []transformer.GraphQLOperation{{Type: "query"}},
+		},
+		{
+			name: "多操作文档按操作名选取并跳过片段",
+			body: `{"operationName":"B","query":"fragment F on User { id } query A { a { ...F } } subscription B { \"\"\"doc { \"\"\" b }"}`,
+			want: []transformer.GraphQLOperation{{Name: "B", Type: "subscription"}},
+		},
+		{
+			name: "持久化查询",
+			body: `{"operationName":"Feed","extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`,
+			want: []transformer.GraphQLOperation{{Name: "Feed"}},
+		},
+		{
+			name:    "批量请求",
+			body:    `[{"query":"query A { a }"},{"query":"mutation B { b }","variables":{"x":1}}]`,
+			batched: true,
+			want: []transformer.GraphQLOperation{
+				{Name: "A", Type: "query"},
+				{Name: "B", Type: "mutation", Variables: []byte(`{"x":1}`)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gql, err := transformer.ParseGraphQL([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseGraphQL: %v", err)
+			}
+			if gql.Batched != tt.batched {
+				t.Errorf("batched = %v, want %v", gql.Batched, tt.batched)
+			}
+			if !reflect.DeepEqual(gql.Operations, tt.want) {
+				t.Errorf("got %+v, want %+v", gql.Operations, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGraphQL_NotGraphQL(t *testing.T) {
+	for _, body := range []string{``, `a=1`, `{"name":"x"}`, `[]`, `[{"query":"{ a }"}, 1]`, `"query"`} {
+		if _, err := transformer.ParseGraphQL([]byte(body)); !errors.Is(err, transformer.ErrNotGraphQL) {
+			t.Errorf("ParseGraphQL(%q) error = %v, want ErrNotGraphQL", body, err)
+		}
+	}
+}
+
+func TestPatchGraphQLVariables(t *testing.T) {
+	body := []byte(`[{"operationName":"A","query":"query A { a }","variables":{"id":1,"keep":true}},{"operationName":"B","query":"query B { b }"}]`)
+	gql, _ := transformer.ParseGraphQL(body)
+	patches := []rulespec.JSONPatchOp{{Op: "add", Path: "/id", Value: 2}}
+
+	out, err := transformer.PatchGraphQLVariables(body, gql, gql.Match(""), patches)
+	if err != nil {
+		t.Fatalf("PatchGraphQLVariables: %v", err)
+	}
+	if got := gjson.GetBytes(out, "0.variables").Raw; got != `{"id":2,"keep":true}` {
+		t.Errorf("op A variables = %s", got)
+	}
+	if got := gjson.GetBytes(out, "1.variables").Raw; got != `{"id":2}` {
+		t.Errorf("missing variables should be created, got %s", got)
+	}
+
+	out, _ = transformer.PatchGraphQLVariables(body, gql, gql.Match("B"), []rulespec.JSONPatchOp{{Op: "add", Path: "/x", Value: "y"}})
+	if gjson.GetBytes(out, "0.variables.x").Exists() || gjson.GetBytes(out, "1.variables.x").String() != "y" {
+		t.Errorf("only operation B should be patched: %s", out)
+	}
+}
+
+func TestPatchGraphQLVariables_FailureKeepsState(t *testing.T) {
+	body := []byte(`[{"operationName":"A","query":"query A { a }","variables":{"id":1}},{"operationName":"B","query":"query B { b }","variables":{"id":"x"}}]`)
+	gql, _ := transformer.ParseGraphQL(body)
+	patches := []rulespec.JSONPatchOp{{Op: "test", Path: "/id", Value: 1}, {Op: "replace", Path: "/id", Value: 2}}
+
+	out, err := transformer.PatchGraphQLVariables(body, gql, gql.Match(""), patches)
+	if !errors.Is(err, transformer.ErrPatchTestFailed) {
+		t.Fatalf("got error %v, want ErrPatchTestFailed", err)
+	}
+	if string(out) != string(body) {
+		t.Errorf("body should be unchanged on failure, got %s", out)
+	}
+	if got := string(gql.Operations[0].Variables); got != `{"id":1}` {
+		t.Errorf("parsed variables of A should be unchanged, got %s", got)
+	}
+}
+
+func TestGraphQLResult(t *testing.T) {
+	tests := []struct {
+		name string
+		data any
+		errs any
+		want string
+	}{
+		{"仅 data", map[string]any{"user": nil}, nil, `{"data":{"user":null}}`},
+		{"字符串错误", nil, "boom", `{"data":null,"errors":[{"message":"boom"}]}`},
+		{"错误列表", nil, []any{map[string]any{"message": "x", "path": []any{"a"}}}, `{"data":null,"errors":[{"message":"x","path":["a"]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformer.GraphQLResult(tt.data, tt.errs)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("got %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockGraphQL(t *testing.T) {
+	result := []byte(`{"data":{"mocked":true}}`)
+	single, _ := transformer.ParseGraphQL([]byte(`{"query":"query A { a }"}`))
+	batch, _ := transformer.ParseGraphQL([]byte(`[{"query":"query A { a }"},{"query":"query B { b }"}]`))
+
+	t.Run("请求阶段单个操作", func(t *testing.T) {
+		got, err := transformer.MockGraphQLRequest(single, single.Match("A"), result)
+		if err != nil || string(got) != string(result) {
+			t.Errorf("got %s, %v", got, err)
+		}
+	})
+	t.Run("请求阶段批量全部命中", func(t *testing.T) {
+		got, err := transformer.MockGraphQLRequest(batch, batch.Match(""), result)
+		if err != nil || string(got) != `[{"data":{"mocked":true}},{"data":{"mocked":true}}]` {
+			t.Errorf("got %s, %v", got, err)
+		}
+	})
+	t.Run("请求阶段批量部分命中", func(t *testing.T) {
+		if _, err := transformer.MockGraphQLRequest(batch, batch.Match("B"), result); !errors.Is(err, transformer.ErrPartialBatch) {
+			t.Errorf("got %v, want ErrPartialBatch", err)
+		}
+	})
+	t.Run("响应阶段替换批量中的单个结果", func(t *testing.T) {
+		res := []byte(`[{"data":{"a":1}},{"data":null,"errors":[{"message":"x"}],"extensions":{"cost":1}}]`)
+		got, err := transformer.MockGraphQLResponse(res, batch, batch.Match("B"), result)
+		if err != nil {
+			t.Fatalf("MockGraphQLResponse: %v", err)
+		}
+		want := `[{"data":{"a":1}},{"data":{"mocked":true},"extensions":{"cost":1}}]`
+		if string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+	t.Run("响应阶段非 JSON 响应体", func(t *testing.T) {
+		got, err := transformer.MockGraphQLResponse([]byte(`<html>502</html>`), single, single.Match(""), result)
+		if err != nil || string(got) != string(result) {
+			t.Errorf("got %s, %v", got, err)
+		}
+		if _, err := transformer.MockGraphQLResponse([]byte(`{}`), batch, batch.Match("A"), result); err == nil {
+			t.Error("batched mock over non-array response should fail")
+		}
+	})
+}
@@ -0,0 +1,456 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"cdpnetool/pkg/rulespec"
+)
+
+// ErrPatchTestFailed JSON Patch 的 test 操作未通过，整个 Patch 不生效
+var ErrPatchTestFailed = errors.New("transformer: json patch test failed")
+
+// jsonObject 保留键顺序的 JSON 对象，修改后按原顺序输出，新增的键追加在末尾
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// get 读取键对应的值
+func (o *jsonObject) get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// set 设置键的值，已存在时保留原位置
+func (o *jsonObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// remove 删除键
+func (o *jsonObject) remove(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// jsonDoc 可原地修改的 JSON 文档，根节点放在 root 中以便统一处理替换根节点
+// 节点类型：*jsonObject、*[]any、json.Number、string、bool、nil
+type jsonDoc struct {
+	root any
+}
+
+// parseJSONDoc 解析 JSON 文本，数字按原文保留
+func parseJSONDoc(data []byte) (*jsonDoc, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("transformer: unexpected data after JSON value")
+	}
+	return &jsonDoc{root: root}, nil
+}
+
+// decodeJSONValue 按 Token 递归解码一个 JSON 值
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := &jsonObject{values: map[string]any{}}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(keyTok.(string), v)
+			}
+			_, err = dec.Token()
+			return obj, err
+		case '[':
+			arr := []any{}
+			for dec.More() {
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err = dec.Token()
+			return &arr, err
+		}
+		return nil, fmt.Errorf("transformer: unexpected delimiter %q", t)
+	default:
+		return t, nil
+	}
+}
+
+// toJSONNode 将规则中配置的任意值转换为文档节点
+func toJSONNode(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseJSONDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.root, nil
+}
+
+// String 紧凑输出文档，不转义 HTML 字符
+func (d *jsonDoc) String() string {
+	var b strings.Builder
+	writeJSONNode(&b, d.root)
+	return b.String()
+}
+
+// writeJSONNode 紧凑输出单个节点
+func writeJSONNode(b *strings.Builder, v any) {
+	switch n := v.(type) {
+	case *jsonObject:
+		b.WriteByte('{')
+		for i, k := range n.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONString(b, k)
+			b.WriteByte(':')
+			writeJSONNode(b, n.values[k])
+		}
+		b.WriteByte('}')
+	case *[]any:
+		b.WriteByte('[')
+		for i, e := range *n {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONNode(b, e)
+		}
+		b.WriteByte(']')
+	case json.Number:
+		b.WriteString(n.String())
+	case string:
+		writeJSONString(b, n)
+	case bool:
+		b.WriteString(strconv.FormatBool(n))
+	default:
+		b.WriteString("null")
+	}
+}
+
+// writeJSONString 输出 JSON 字符串，不转义 <、>、&
+func writeJSONString(b *strings.Builder, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	b.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+// cloneJSONNode 深拷贝节点
+func cloneJSONNode(v any) any {
+	switch n := v.(type) {
+	case *jsonObject:
+		obj := &jsonObject{keys: append([]string(nil), n.keys...), values: make(map[string]any, len(n.values))}
+		for k, e := range n.values {
+			obj.values[k] = cloneJSONNode(e)
+		}
+		return obj
+	case *[]any:
+		arr := make([]any, len(*n))
+		for i, e := range *n {
+			arr[i] = cloneJSONNode(e)
+		}
+		return &arr
+	default:
+		return v
+	}
+}
+
+// equalJSONNode 按 JSON 语义比较节点：对象忽略键顺序，数字按数值比较
+func equalJSONNode(a, b any) bool {
+	switch x := a.(type) {
+	case *jsonObject:
+		y, ok := b.(*jsonObject)
+		if !ok || len(x.values) != len(y.values) {
+			return false
+		}
+		for k, v := range x.values {
+			w, ok := y.values[k]
+			if !ok || !equalJSONNode(v, w) {
+				return false
+			}
+		}
+		return true
+	case *[]any:
+		y, ok := b.(*[]any)
+		if !ok || len(*x) != len(*y) {
+			return false
+		}
+		for i := range *x {
+			if !equalJSONNode((*x)[i], (*y)[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, err1 := x.Float64()
+		fy, err2 := y.Float64()
+		return err1 == nil && err2 == nil && fx == fy
+	default:
+		return a == b
+	}
+}
+
+// parseJSONPointer 解析 RFC 6901 JSON Pointer，"" 表示整个文档
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex 解析数组下标，不允许前导零与负数；allowEnd 为真时允许等于数组长度（用于 add）
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// get 读取指针指向的值
+func (d *jsonDoc) get(tokens []string) (any, error) {
+	cur := d.root
+	for _, t := range tokens {
+		switch n := cur.(type) {
+		case *jsonObject:
+			v, ok := n.get(t)
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", t)
+			}
+			cur = v
+		case *[]any:
+			i, err := arrayIndex(t, len(*n), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = (*n)[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into scalar at %q", t)
+		}
+	}
+	return cur, nil
+}
+
+// add 在指针位置添加值：对象成员存在时替换，数组按下标插入，"-" 追加到末尾
+func (d *jsonDoc) add(tokens []string, value any) error {
+	if len(tokens) == 0 {
+		d.root = value
+		return nil
+	}
+	parent, err := d.get(tokens[:len(tokens)-1])
+	if err != nil {
+		return err
+	}
+	last := tokens[len(tokens)-1]
+	switch n := parent.(type) {
+	case *jsonObject:
+		n.set(last, value)
+	case *[]any:
+		i := len(*n)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(*n), true); err != nil {
+				return err
+			}
+		}
+		*n = append(*n, nil)
+		copy((*n)[i+1:], (*n)[i:])
+		(*n)[i] = value
+	default:
+		return fmt.Errorf("cannot add member %q to a scalar", last)
+	}
+	return nil
+}
+
+// remove 删除指针位置的值并返回
+func (d *jsonDoc) remove(tokens []string) (any, error) {
+	if len(tokens) == 0 {
+		old := d.root
+		d.root = nil
+		return old, nil
+	}
+	parent, err := d.get(tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch n := parent.(type) {
+	case *jsonObject:
+		v, ok := n.get(last)
+		if !ok {
+			return nil, fmt.Errorf("path member %q not found", last)
+		}
+		n.remove(last)
+		return v, nil
+	case *[]any:
+		i, err := arrayIndex(last, len(*n), false)
+		if err != nil {
+			return nil, err
+		}
+		v := (*n)[i]
+		*n = append((*n)[:i], (*n)[i+1:]...)
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot remove member %q from a scalar", last)
+	}
+}
+
+// replace 替换指针位置已存在的值
+func (d *jsonDoc) replace(tokens []string, value any) error {
+	if _, err := d.get(tokens); err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		d.root = value
+		return nil
+	}
+	parent, _ := d.get(tokens[:len(tokens)-1])
+	last := tokens[len(tokens)-1]
+	switch n := parent.(type) {
+	case *jsonObject:
+		n.set(last, value)
+	case *[]any:
+		i, _ := arrayIndex(last, len(*n), false)
+		(*n)[i] = value
+	}
+	return nil
+}
+
+// apply 执行单个 JSON Patch 操作
+func (d *jsonDoc) apply(op rulespec.JSONPatchOp) error {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := toJSONNode(op.Value)
+		if err != nil {
+			return err
+		}
+		switch op.Op {
+		case "add":
+			return d.add(path, value)
+		case "replace":
+			return d.replace(path, value)
+		default:
+			current, err := d.get(path)
+			if err != nil {
+				return err
+			}
+			if !equalJSONNode(current, value) {
+				return ErrPatchTestFailed
+			}
+			return nil
+		}
+	case "remove":
+		_, err := d.remove(path)
+		return err
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Op == "copy" {
+			value, err := d.get(from)
+			if err != nil {
+				return err
+			}
+			return d.add(path, cloneJSONNode(value))
+		}
+		if op.Path == op.From {
+			_, err := d.get(from)
+			return err
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("cannot move %q into its own child %q", op.From, op.Path)
+		}
+		value, err := d.remove(from)
+		if err != nil {
+			return err
+		}
+		return d.add(path, value)
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// mergePatch 按 RFC 7396 将 patch 合并到 target，返回合并结果
+func mergePatch(target, patch any) any {
+	p, ok := patch.(*jsonObject)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(*jsonObject)
+	if !ok {
+		t = &jsonObject{values: map[string]any{}}
+	}
+	for _, k := range p.keys {
+		v := p.values[k]
+		if v == nil {
+			t.remove(k)
+			continue
+		}
+		current, _ := t.get(k)
+		t.set(k, mergePatch(current, v))
+	}
+	return t
+}
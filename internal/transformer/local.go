@@ -0,0 +1,159 @@
+package transformer
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrLocalFileNotFound 映射的本地文件不存在
+var ErrLocalFileNotFound = errors.New("transformer: local file not found")
+
+// localIndexFiles 映射到目录时依次尝试的索引文件
+var localIndexFiles = []string{"index.html", "index.htm"}
+
+// maxLocalCacheSize 单个文件的缓存上限，超过时每次请求都从磁盘读取
+const maxLocalCacheSize = 8 << 20
+
+// localMimeTypes 常见前端资源的 MIME 类型，优先于系统注册表（部分 Windows 系统会把 .js 注册为 text/plain）
+var localMimeTypes = map[string]string{
+	".html": "text/html; charset=utf-8",
+	".htm":  "text/html; charset=utf-8",
+	".js":   "text/javascript; charset=utf-8",
+	".mjs":  "text/javascript; charset=utf-8",
+	".css":  "text/css; charset=utf-8",
+	".json": "application/json",
+	".map":  "application/json",
+	".svg":  "image/svg+xml",
+	".wasm": "application/wasm",
+}
+
+// LocalFile 从本地读取的文件
+type LocalFile struct {
+	Path        string // 实际读取的文件路径
+	ContentType string // 由扩展名推断的内容类型，无法推断时按内容嗅探
+	Body        []byte
+}
+
+// localCacheEntry 本地文件缓存条目，修改时间或大小变化时失效
+type localCacheEntry struct {
+	modTime time.Time
+	size    int64
+	file    *LocalFile
+}
+
+// localCache 已读取文件的缓存，键为文件路径
+var localCache sync.Map
+
+// ResolveLocalPath 将请求 URL 映射为本地文件路径
+// localPath 为文件时直接返回；为目录时取 URL 去掉 urlPrefix 后的路径（不以 urlPrefix 开头时取完整路径）拼接到目录下，
+// 路径中的 ".." 不会越出目录，解码后含 "\"、":" 或 NUL 的路径视为不存在，指向目录时依次尝试 index.html、index.htm
+func ResolveLocalPath(localPath, urlPrefix, rawURL string) (string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", localStatError(err)
+	}
+	if !info.IsDir() {
+		return localPath, nil
+	}
+
+	rel := ""
+	if urlPrefix != "" && strings.HasPrefix(rawURL, urlPrefix) {
+		rel = strings.TrimPrefix(rawURL, urlPrefix)
+		if i := strings.IndexAny(rel, "?#"); i != -1 {
+			rel = rel[:i]
+		}
+	} else if u, err := url.Parse(rawURL); err == nil {
+		rel = u.EscapedPath()
+	}
+	if unescaped, err := url.PathUnescape(rel); err == nil {
+		rel = unescaped
+	}
+	// 解码后的 "\"（%5C）在 Windows 上是路径分隔符，":" 可指定盘符或备用数据流，均不会被 path.Clean 处理
+	if strings.ContainsAny(rel, "\\:\x00") {
+		return "", ErrLocalFileNotFound
+	}
+
+	target := filepath.Join(localPath, filepath.FromSlash(path.Clean("/"+rel)))
+	if r, err := filepath.Rel(localPath, target); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", ErrLocalFileNotFound
+	}
+	info, err = os.Stat(target)
+	if err != nil {
+		return "", localStatError(err)
+	}
+	if !info.IsDir() {
+		return target, nil
+	}
+	for _, index := range localIndexFiles {
+		candidate := filepath.Join(target, index)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", ErrLocalFileNotFound
+}
+
+// ReadLocalFile 读取本地文件，内容按修改时间与大小缓存，文件变化后自动重新读取
+func ReadLocalFile(name string) (*LocalFile, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, localStatError(err)
+	}
+	if info.IsDir() {
+		return nil, ErrLocalFileNotFound
+	}
+	if cached, ok := localCache.Load(name); ok {
+		entry := cached.(*localCacheEntry)
+		if entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			return entry.file, nil
+		}
+	}
+
+	body, err := os.ReadFile(name)
+	if err != nil {
+		return nil, localStatError(err)
+	}
+	file := &LocalFile{Path: name, ContentType: localContentType(name, body), Body: body}
+	if info.Size() <= maxLocalCacheSize {
+		localCache.Store(name, &localCacheEntry{modTime: info.ModTime(), size: info.Size(), file: file})
+	} else {
+		localCache.Delete(name)
+	}
+	return file, nil
+}
+
+// localContentType 按扩展名推断内容类型，无法推断时嗅探内容
+// 非二进制类型缺少 charset 且内容为合法 UTF-8 时补充 charset，避免浏览器按其他编码解析
+func localContentType(name string, body []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	ct := localMimeTypes[ext]
+	if ct == "" {
+		ct = mime.TypeByExtension(ext)
+	}
+	if ct == "" {
+		ct = http.DetectContentType(body)
+	}
+	if !IsBinaryContentType(ct) && !strings.Contains(ct, "charset=") && utf8.Valid(body) {
+		ct += "; charset=utf-8"
+	}
+	return ct
+}
+
+// localStatError 将文件不存在类错误（包括路径中间段是文件）统一为 ErrLocalFileNotFound
+func localStatError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return ErrLocalFileNotFound
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package transformer_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cdpnetool/internal/transformer"
+)
+
+// writeFiles 在临时目录中按相对路径创建文件
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestResolveLocalPath(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"dist/app.js":         "js",
+		"dist/index.html":     "<html>",
+		"dist/docs/index.htm": "docs",
+		"dist/a b.css":        "css",
+		"secret.txt":          "secret",
+	})
+	dist := filepath.Join(root, "dist")
+
+	tests := []struct {
+		name   string
+		local  string
+		prefix string
+		url    string
+		want   string
+	}{
+		{"映射单个文件", filepath.Join(dist, "app.js"), "", "https://cdn.example.com/x/main.js?v=1", filepath.Join(dist, "app.js")},
+		{"按前缀映射目录", dist, "https://cdn.example.com/static/", "https://cdn.example.com/static/app.js?v=1", filepath.Join(dist, "app.js")},
+		{"未设置前缀时取完整路径", dist, "", "https://example.com/app.js", filepath.Join(dist, "app.js")},
+		{"目录索引文件", dist, "https://example.com/", "https://example.com/", filepath.Join(dist, "index.html")},
+		{"子目录索引文件", dist, "https://example.com/", "https://example.com/docs/#top", filepath.Join(dist, "docs", "index.htm")},
+		{"路径解码", dist, "https://example.com/", "https://example.com/a%20b.css", filepath.Join(dist, "a b.css")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformer.ResolveLocalPath(tt.local, tt.prefix, tt.url)
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	for _, url := range []string{
+		"https://example.com/missing.js",
+		"https://example.com/app.js/x",
+		"https://example.com/../secret.txt",
+		"https://example.com/%2e%2e/secret.txt",
+		"https://example.com/%2e%2e/%2e%2e/secret.txt",
+		"https://example.com/..%5Csecret.txt",
+		"https://example.com/..%5C..%5CWindows%5Cwin.ini",
+		"https://example.com/C:%5CWindows%5Cwin.ini",
+		"https://example.com/app.js%00.css",
+	} {
+		if _, err := transformer.ResolveLocalPath(dist, "https://example.com/", url); !errors.Is(err, transformer.ErrLocalFileNotFound) {
+			t.Errorf("ResolveLocalPath(%q) error = %v, want ErrLocalFileNotFound", url, err)
+		}
+	}
+}
+
+func TestReadLocalFile(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"app.js":    "console.log(1)",
+		"logo.png":  "\x89PNG\r\n\x1a\n",
+		"data.json": `{"a":1}`,
+		"notes":     "plain text",
+	})
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"脚本", "app.js", "text/javascript; charset=utf-8"},
+		{"图片不补充 charset", "logo.png", "image/png"},
+		{"JSON", "data.json", "application/json; charset=utf-8"},
+		{"无扩展名时嗅探内容", "notes", "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := transformer.ReadLocalFile(filepath.Join(root, tt.file))
+			if err != nil {
+				t.Fatalf("ReadLocalFile: %v", err)
+			}
+			if f.ContentType != tt.want {
+				t.Errorf("got content type %q, want %q", f.ContentType, tt.want)
+			}
+		})
+	}
+
+	if _, err := transformer.ReadLocalFile(filepath.Join(root, "none")); !errors.Is(err, transformer.ErrLocalFileNotFound) {
+		t.Errorf("missing file: got %v, want ErrLocalFileNotFound", err)
+	}
+}
+
+func TestReadLocalFile_CacheByModTime(t *testing.T) {
+	root := writeFiles(t, map[string]string{"app.js": "v1"})
+	name := filepath.Join(root, "app.js")
+
+	first, _ := transformer.ReadLocalFile(name)
+	second, _ := transformer.ReadLocalFile(name)
+	if first != second {
+		t.Error("unchanged file should be served from cache")
+	}
+
+	if err := os.WriteFile(name, []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(name, future, future); err != nil {
+		t.Fatal(err)
+	}
+	third, err := transformer.ReadLocalFile(name)
+	if err != nil || string(third.Body) != "v2" {
+		t.Errorf("modified file should be reloaded, got %q, %v", third.Body, err)
+	}
+}
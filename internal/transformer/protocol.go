@@ -1,7 +1,11 @@
 package transformer
 
 import (
+	"fmt"
+	"net/url"
 	"strings"
+
+	"cdpnetool/pkg/domain"
 )
 
 // ParseCookies 解析 Cookie 字符串为映射
@@ -21,6 +25,111 @@ func ParseCookies(cookieStr string) map[string]string {
 	return cookies
 }
 
+// ParseQuery 按原始顺序解析 URL 中的查询参数，键和值均已解码
+// 每个参数保留原始编码片段，未被修改时可原样重建；无法解码的片段保持原样
+func ParseQuery(rawURL string) domain.Query {
+	return parseRawQuery(splitQuery(rawURL))
+}
+
+// parseRawQuery 解析 "a=1&b=2" 形式的查询字符串（也用于 urlencoded 表单）
+func parseRawQuery(rawQuery string) domain.Query {
+	query := domain.Query{}
+	if rawQuery == "" {
+		return query
+	}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		query = append(query, domain.QueryParam{Key: unescapeQuery(k), Value: unescapeQuery(v), Raw: pair})
+	}
+	return query
+}
+
+// BuildQueryString 将查询参数重新构建为查询字符串
+// 未被修改的参数使用原始编码片段，新增或修改的参数按 application/x-www-form-urlencoded 编码
+func BuildQueryString(query domain.Query) string {
+	parts := make([]string, 0, len(query))
+	for _, p := range query {
+		if p.Raw != "" {
+			parts = append(parts, p.Raw)
+			continue
+		}
+		parts = append(parts, url.QueryEscape(p.Key)+"="+url.QueryEscape(p.Value))
+	}
+	return strings.Join(parts, "&")
+}
+
+// ReplaceQuery 替换 URL 中的查询字符串，保留其余部分（包括片段）的原始字节
+func ReplaceQuery(rawURL, rawQuery string) string {
+	base, fragment := rawURL, ""
+	if i := strings.Index(base, "#"); i != -1 {
+		base, fragment = base[:i], base[i:]
+	}
+	if i := strings.Index(base, "?"); i != -1 {
+		base = base[:i]
+	}
+	if rawQuery != "" {
+		base += "?" + rawQuery
+	}
+	return base + fragment
+}
+
+// MapRemoteURL 将 URL 映射到另一个地址，保留其余路径与查询参数
+// from 不为空时将 URL 中的 from 前缀替换为 to，URL 不以 from 开头时返回 false；
+// from 为空时替换 URL 的协议、主机与端口，to 中的路径作为前缀拼接在原路径之前
+func MapRemoteURL(rawURL, from, to string) (string, bool, error) {
+	target, err := url.Parse(to)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return rawURL, false, fmt.Errorf("mapRemote: invalid target %q", to)
+	}
+	if from != "" {
+		if !strings.HasPrefix(rawURL, from) {
+			return rawURL, false, nil
+		}
+		return to + rawURL[len(from):], true, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, false, err
+	}
+	origin := u.Scheme + "://" + u.Host
+	if !strings.HasPrefix(rawURL, origin) {
+		return rawURL, false, nil
+	}
+	return strings.TrimSuffix(to, "/") + rawURL[len(origin):], true, nil
+}
+
+// URLOrigin 返回 URL 的源（协议://主机[:端口]），无法解析时返回空字符串
+func URLOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// splitQuery 返回 URL 中 "?" 与 "#" 之间的原始查询字符串
+func splitQuery(rawURL string) string {
+	if i := strings.Index(rawURL, "#"); i != -1 {
+		rawURL = rawURL[:i]
+	}
+	i := strings.Index(rawURL, "?")
+	if i == -1 {
+		return ""
+	}
+	return rawURL[i+1:]
+}
+
+// unescapeQuery 解码查询参数片段，失败时返回原值
+func unescapeQuery(s string) string {
+	if v, err := url.QueryUnescape(s); err == nil {
+		return v
+	}
+	return s
+}
+
 // BuildCookieString 将映射重新构建为 Cookie 字符串
 func BuildCookieString(cookies map[string]string) string {
 	if len(cookies) == 0 {
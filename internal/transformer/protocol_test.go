@@ -1,9 +1,11 @@
 package transformer_test
 
 import (
+	"reflect"
 	"testing"
 
 	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/domain"
 )
 
 func TestParseCookies(t *testing.T) {
@@ -70,3 +72,90 @@ func TestIsBinaryContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want domain.Query
+	}{
+		{"无查询参数", "https://example.com/a", domain.Query{}},
+		{"多个参数解码", "https://example.com/a?x=1&y=%20b+c", domain.Query{
+			{Key: "x", Value: "1", Raw: "x=1"},
+			{Key: "y", Value: " b c", Raw: "y=%20b+c"},
+		}},
+		{"忽略片段", "https://example.com/a?x=1#top?y=2", domain.Query{{Key: "x", Value: "1", Raw: "x=1"}}},
+		{"无值参数与重复键", "https://example.com/a?flag&x=1&x=2", domain.Query{
+			{Key: "flag", Value: "", Raw: "flag"},
+			{Key: "x", Value: "1", Raw: "x=1"},
+			{Key: "x", Value: "2", Raw: "x=2"},
+		}},
+		{"非法编码保持原样", "https://example.com/a?q=%zz", domain.Query{{Key: "q", Value: "%zz", Raw: "q=%zz"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := transformer.ParseQuery(tt.url)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildQueryString(t *testing.T) {
+	const raw = "https://example.com/a?sig=AbC%2Fd%3D%3D&b=1&a=x+y&flag#frag"
+	tests := []struct {
+		name string
+		op   func(q *domain.Query)
+		want string
+	}{
+		{"未修改时原样重建", func(q *domain.Query) {}, "https://example.com/a?sig=AbC%2Fd%3D%3D&b=1&a=x+y&flag#frag"},
+		{"只重新编码被修改的参数", func(q *domain.Query) { q.Set("b", "新 值") }, "https://example.com/a?sig=AbC%2Fd%3D%3D&b=%E6%96%B0+%E5%80%BC&a=x+y&flag#frag"},
+		{"新增参数追加到末尾", func(q *domain.Query) { q.Add("c", "a&b") }, "https://example.com/a?sig=AbC%2Fd%3D%3D&b=1&a=x+y&flag&c=a%26b#frag"},
+		{"删除参数", func(q *domain.Query) { q.Del("b"); q.Del("flag") }, "https://example.com/a?sig=AbC%2Fd%3D%3D&a=x+y#frag"},
+		{"删除全部参数", func(q *domain.Query) { *q = (*q)[:0] }, "https://example.com/a#frag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := transformer.ParseQuery(raw)
+			tt.op(&q)
+			got := transformer.ReplaceQuery(raw, transformer.BuildQueryString(q))
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapRemoteURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		from   string
+		to     string
+		want   string
+		mapped bool
+	}{
+		{"替换前缀", "https://staging.example.com/api/users?id=1", "https://staging.example.com/api/", "http://localhost:8080/v2/", "http://localhost:8080/v2/users?id=1", true},
+		{"前缀不匹配", "https://staging.example.com/web/x", "https://staging.example.com/api/", "http://localhost:8080/", "https://staging.example.com/web/x", false},
+		{"仅替换源", "https://staging.example.com/api/users?id=1#top", "", "http://localhost:8080", "http://localhost:8080/api/users?id=1#top", true},
+		{"替换源并加路径前缀", "https://staging.example.com/api?x=1", "", "http://localhost:8080/proxy/", "http://localhost:8080/proxy/api?x=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, mapped, err := transformer.MapRemoteURL(tt.url, tt.from, tt.to)
+			if err != nil || got != tt.want || mapped != tt.mapped {
+				t.Errorf("got %q, %v, %v; want %q, %v", got, mapped, err, tt.want, tt.mapped)
+			}
+		})
+	}
+
+	if _, _, err := transformer.MapRemoteURL("https://a.com/", "", "localhost:8080"); err == nil {
+		t.Error("target without scheme should fail")
+	}
+	if got := transformer.URLOrigin("http://localhost:8080/a?b"); got != "http://localhost:8080" {
+		t.Errorf("URLOrigin = %q", got)
+	}
+}
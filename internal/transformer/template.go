@@ -0,0 +1,241 @@
+package transformer
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"cdpnetool/pkg/domain"
+
+	"github.com/google/uuid"
+)
+
+// TemplateContext 模板执行时的数据上下文，在模板中以 {{.Method}}、{{.Header "X-Id"}} 等形式访问
+type TemplateContext struct {
+	Req    *domain.Request
+	Res    *domain.Response                 // 仅响应阶段非空
+	Groups []string                         // 匹配条件的正则捕获组，0 为完整匹配
+	Named  map[string]string                // 命名捕获组
+	Vars   func(name string) (string, bool) // 会话变量查询，为空时变量均不存在
+}
+
+// templateFuncs 模板内置的辅助函数
+var templateFuncs = template.FuncMap{
+	"uuid":         uuid.NewString,
+	"now":          formatNow,
+	"timestamp":    func() int64 { return time.Now().UnixMilli() },
+	"randInt":      randInt,
+	"base64":       func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"base64Decode": base64Decode,
+	"urlencode":    url.QueryEscape,
+	"urldecode":    url.QueryUnescape,
+}
+
+// TemplateCache 已解析模板的缓存，键为模板文本
+// 由规则引擎为每次编译的规则创建，更新规则配置时随旧规则一并丢弃；为 nil 时每次重新解析
+type TemplateCache struct {
+	cache sync.Map
+}
+
+// NewTemplateCache 创建模板缓存
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{}
+}
+
+// RenderTemplate 使用上下文渲染模板文本，不缓存解析结果
+func RenderTemplate(text string, ctx *TemplateContext) (string, error) {
+	var c *TemplateCache
+	return c.Render(text, ctx)
+}
+
+// Render 使用上下文渲染模板文本，不含 "{{" 的文本原样返回
+func (c *TemplateCache) Render(text string, ctx *TemplateContext) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := c.parse(text)
+	if err != nil {
+		return text, err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, ctx); err != nil {
+		return text, err
+	}
+	return b.String(), nil
+}
+
+// parse 解析模板文本，缓存非空时复用已解析的模板
+func (c *TemplateCache) parse(text string) (*template.Template, error) {
+	if c != nil {
+		if cached, ok := c.cache.Load(text); ok {
+			return cached.(*template.Template), nil
+		}
+	}
+	tmpl, err := template.New("action").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		c.cache.Store(text, tmpl)
+	}
+	return tmpl, nil
+}
+
+// ID 请求 ID
+func (c *TemplateContext) ID() string {
+	return c.Req.ID
+}
+
+// URL 请求 URL
+func (c *TemplateContext) URL() string {
+	return c.Req.URL
+}
+
+// Method 请求方法
+func (c *TemplateContext) Method() string {
+	return c.Req.Method
+}
+
+// Path 请求 URL 的路径部分
+func (c *TemplateContext) Path() string {
+	u, err := url.Parse(c.Req.URL)
+	if err != nil {
+		return ""
+	}
+	return u.EscapedPath()
+}
+
+// Segment 请求路径中第 i 段（从 0 开始，忽略空段），越界时返回空字符串
+func (c *TemplateContext) Segment(i int) string {
+	var segments []string
+	for _, s := range strings.Split(c.Path(), "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	if i < 0 || i >= len(segments) {
+		return ""
+	}
+	return segments[i]
+}
+
+// Header 请求头的第一个值
+func (c *TemplateContext) Header(name string) string {
+	return c.Req.Headers.Get(name)
+}
+
+// Query 查询参数的第一个值（已解码）
+func (c *TemplateContext) Query(name string) string {
+	return c.Req.Query.Get(name)
+}
+
+// Cookie 请求 Cookie 的值
+func (c *TemplateContext) Cookie(name string) string {
+	return c.Req.Cookies[name]
+}
+
+// Body 请求体文本
+func (c *TemplateContext) Body() string {
+	return string(c.Req.Body)
+}
+
+// JSONPath 按 JSON Path 读取请求体中的值，不存在时返回空字符串
+func (c *TemplateContext) JSONPath(path string) string {
+	return jsonPathValue(c.Req.Body, path)
+}
+
+// Status 响应状态码，请求阶段为 0
+func (c *TemplateContext) Status() int {
+	if c.Res == nil {
+		return 0
+	}
+	return c.Res.StatusCode
+}
+
+// ResponseHeader 响应头的第一个值，请求阶段为空
+func (c *TemplateContext) ResponseHeader(name string) string {
+	if c.Res == nil {
+		return ""
+	}
+	return c.Res.Headers.Get(name)
+}
+
+// ResponseBody 响应体文本，请求阶段为空
+func (c *TemplateContext) ResponseBody() string {
+	if c.Res == nil {
+		return ""
+	}
+	return string(c.Res.Body)
+}
+
+// ResponseJSONPath 按 JSON Path 读取响应体中的值，请求阶段为空
+func (c *TemplateContext) ResponseJSONPath(path string) string {
+	if c.Res == nil {
+		return ""
+	}
+	return jsonPathValue(c.Res.Body, path)
+}
+
+// Capture 正则捕获组，key 为序号或命名分组名，不存在时返回空字符串
+func (c *TemplateContext) Capture(key any) string {
+	switch k := key.(type) {
+	case int:
+		if k >= 0 && k < len(c.Groups) {
+			return c.Groups[k]
+		}
+	case string:
+		if i, err := strconv.Atoi(k); err == nil {
+			return c.Capture(i)
+		}
+		return c.Named[k]
+	}
+	return ""
+}
+
+// Var 会话变量的值，不存在时返回空字符串
+func (c *TemplateContext) Var(name string) string {
+	if c.Vars == nil {
+		return ""
+	}
+	v, _ := c.Vars(name)
+	return v
+}
+
+// jsonPathValue 读取 JSON 值的字符串形式，不存在时返回空字符串
+func jsonPathValue(body []byte, path string) string {
+	v, _ := ExtractJSONPath(body, path)
+	return v
+}
+
+// formatNow 按 Go 时间格式输出当前时间，默认 RFC3339
+func formatNow(layout ...string) string {
+	if len(layout) > 0 && layout[0] != "" {
+		return time.Now().Format(layout[0])
+	}
+	return time.Now().Format(time.RFC3339)
+}
+
+// randInt 返回 [min, max] 区间内的随机整数
+func randInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("randInt: max %d is less than min %d", max, min)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)+1))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+// base64Decode 解码标准 Base64 字符串
+func base64Decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
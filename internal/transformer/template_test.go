@@ -0,0 +1,113 @@
+package transformer_test
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"cdpnetool/internal/transformer"
+	"cdpnetool/pkg/domain"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	req := domain.NewRequest()
+	req.ID = "req-1"
+	req.URL = "https://example.com/api/v1/users/42?page=2&q=a%20b"
+	req.Method = "POST"
+	req.Query = transformer.ParseQuery(req.URL)
+	req.Headers.Set("X-Request-Id", "abc")
+	req.Cookies["sid"] = "s1"
+	req.Body = []byte(`{"user":{"id":7,"tags":["x"]}}`)
+
+	res := domain.NewResponse()
+	res.StatusCode = 404
+	res.Headers.Set("Content-Type", "application/json")
+	res.Body = []byte(`{"error":"nf"}`)
+
+	ctx := &transformer.TemplateContext{
+		Req:    req,
+		Res:    res,
+		Groups: []string{"/users/42", "42"},
+		Named:  map[string]string{"id": "42"},
+		Vars: func(name string) (string, bool) {
+			v, ok := map[string]string{"token": "t-1"}[name]
+			return v, ok
+		},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"纯文本", "static", "static"},
+		{"请求字段", "{{.Method}} {{.ID}} {{.Path}}", "POST req-1 /api/v1/users/42"},
+		{"路径段", "/v2/{{.Segment 2}}/{{.Segment 3}}{{.Segment 9}}", "/v2/users/42"},
+		{"头与查询与 Cookie", "{{.Header \"x-request-id\"}}|{{.Query \"q\"}}|{{.Cookie \"sid\"}}", "abc|a b|s1"},
+		{"JSON Path", "{{.JSONPath \"$.user.id\"}} {{.JSONPath \"user.tags\"}} [{{.JSONPath \"$.none\"}}]", `7 ["x"] []`},
+		{"响应字段", "{{.Status}} {{.ResponseHeader \"content-type\"}} {{.ResponseJSONPath \"$.error\"}}", "404 application/json nf"},
+		{"捕获组", "{{.Capture 1}} {{.Capture \"id\"}} {{.Capture \"0\"}} [{{.Capture 5}}]", "42 42 /users/42 []"},
+		{"会话变量", "Bearer {{.Var \"token\"}}[{{.Var \"none\"}}]", "Bearer t-1[]"},
+		{"编码辅助函数", "{{base64 \"hi\"}} {{base64Decode \"aGk=\"}} {{urlencode \"a b&c\"}} {{.Query \"q\" | urlencode}}", "aGk= hi a+b%26c a+b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformer.RenderTemplate(tt.text, ctx)
+			if err != nil {
+				t.Fatalf("RenderTemplate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate_Helpers(t *testing.T) {
+	ctx := &transformer.TemplateContext{Req: domain.NewRequest()}
+
+	got, err := transformer.RenderTemplate("{{uuid}}", ctx)
+	if err != nil || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got) {
+		t.Errorf("uuid = %q, %v", got, err)
+	}
+
+	for i := 0; i < 20; i++ {
+		got, err = transformer.RenderTemplate("{{randInt 3 5}}", ctx)
+		n, _ := strconv.Atoi(got)
+		if err != nil || n < 3 || n > 5 {
+			t.Fatalf("randInt = %q, %v", got, err)
+		}
+	}
+
+	if got, err = transformer.RenderTemplate(`{{now "2006"}}`, ctx); err != nil || len(got) != 4 {
+		t.Errorf("now = %q, %v", got, err)
+	}
+	if got, err = transformer.RenderTemplate(`{{.Status}}{{.ResponseHeader "a"}}`, ctx); err != nil || got != "0" {
+		t.Errorf("response fields in request stage = %q, %v", got, err)
+	}
+}
+
+func TestRenderTemplate_Errors(t *testing.T) {
+	ctx := &transformer.TemplateContext{Req: domain.NewRequest()}
+	for _, text := range []string{"{{.Header}", "{{unknown}}", "{{randInt 5 1}}", `{{base64Decode "%%"}}`} {
+		if _, err := transformer.RenderTemplate(text, ctx); err == nil {
+			t.Errorf("RenderTemplate(%q) expected error", text)
+		}
+	}
+}
+
+func TestTemplateCache_Render(t *testing.T) {
+	c := transformer.NewTemplateCache()
+	for _, id := range []string{"a", "b"} {
+		req := domain.NewRequest()
+		req.ID = id
+		got, err := c.Render("id={{.ID}}", &transformer.TemplateContext{Req: req})
+		if err != nil || got != "id="+id {
+			t.Errorf("got %q, %v; want id=%s", got, err, id)
+		}
+	}
+	if got, err := c.Render("{{.Header}", &transformer.TemplateContext{Req: domain.NewRequest()}); err == nil || got != "{{.Header}" {
+		t.Errorf("invalid template: got %q, %v", got, err)
+	}
+}
@@ -26,6 +26,9 @@ type Service interface {
 	// ListTargets 列出目标
 	ListTargets(ctx context.Context, id domain.SessionID) ([]domain.TargetInfo, error)
 
+	// SetAutoAttach 设置自动附着新页面（标签页、弹窗与窗口）的选项
+	SetAutoAttach(ctx context.Context, id domain.SessionID, opts domain.AutoAttachOptions) error
+
 	// EnableInterception 启用拦截
 	EnableInterception(ctx context.Context, id domain.SessionID) error
 
@@ -38,6 +41,15 @@ type Service interface {
 	// GetRuleStats 获取规则统计信息
 	GetRuleStats(ctx context.Context, id domain.SessionID) (domain.EngineStats, error)
 
+	// ResetRuleStats 清空规则统计信息
+	ResetRuleStats(ctx context.Context, id domain.SessionID) error
+
+	// GetSessionState 获取会话变量与场景状态
+	GetSessionState(ctx context.Context, id domain.SessionID) (domain.SessionState, error)
+
+	// ResetSessionState 清空会话变量并将场景恢复为初始状态
+	ResetSessionState(ctx context.Context, id domain.SessionID) error
+
 	// SubscribeEvents 订阅事件
 	SubscribeEvents(ctx context.Context, id domain.SessionID) (<-chan domain.NetworkEvent, error)
 
@@ -46,6 +58,9 @@ type Service interface {
 
 	// EnableTrafficCapture 启用/禁用流量捕获
 	EnableTrafficCapture(ctx context.Context, id domain.SessionID, enabled bool) error
+
+	// ExplainRules 使用样例请求/响应试运行规则配置（无需浏览器）
+	ExplainRules(ctx context.Context, cfg *rulespec.Config, req *domain.Request, res *domain.Response) (*domain.ExplainResult, error)
 }
 
 // NewService 创建并返回服务接口实现
@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HeaderField 单个头部字段，保留原始名称大小写
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// Header 有序、可多值的头部集合，名称查找不区分大小写
+// 同名字段（如多个 Set-Cookie）按出现顺序分别保存
+type Header []HeaderField
+
+// Get 获取指定 Header 的第一个值，不存在时返回空字符串
+func (h Header) Get(name string) string {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Values 按出现顺序获取指定 Header 的全部值
+func (h Header) Values(name string) []string {
+	var values []string
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// Has 判断指定 Header 是否存在（值可以为空）
+func (h Header) Has(name string) bool {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set 设置指定 Header 的值，替换全部同名字段
+// 已存在时保留第一个字段的位置与原始名称，否则追加到末尾
+func (h *Header) Set(name, value string) {
+	out := (*h)[:0]
+	found := false
+	for _, f := range *h {
+		if !strings.EqualFold(f.Name, name) {
+			out = append(out, f)
+			continue
+		}
+		if !found {
+			f.Value = value
+			out = append(out, f)
+			found = true
+		}
+	}
+	if !found {
+		out = append(out, HeaderField{Name: name, Value: value})
+	}
+	*h = out
+}
+
+// Add 追加一个 Header 字段，不影响已有的同名字段
+func (h *Header) Add(name, value string) {
+	*h = append(*h, HeaderField{Name: name, Value: value})
+}
+
+// Del 删除指定 Header 的全部字段
+func (h *Header) Del(name string) {
+	out := (*h)[:0]
+	for _, f := range *h {
+		if !strings.EqualFold(f.Name, name) {
+			out = append(out, f)
+		}
+	}
+	*h = out
+}
+
+// Clone 深拷贝 Header
+func (h Header) Clone() Header {
+	if h == nil {
+		return Header{}
+	}
+	return append(Header{}, h...)
+}
+
+// MarshalJSON 序列化为 JSON 对象以兼容 name -> value 的格式
+// 名称取首次出现时的大小写，同名多值以换行符连接（与 DevTools 的表示一致）
+func (h Header) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	written := make(map[string]bool, len(h))
+	for _, f := range h {
+		key := strings.ToLower(f.Name)
+		if written[key] {
+			continue
+		}
+		written[key] = true
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(strings.Join(h.Values(f.Name), "\n"))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON 从 JSON 对象反序列化，保留字段顺序，值中的换行符视为多值分隔
+func (h *Header) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*h = nil
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("domain: header must be a JSON object, got %v", tok)
+	}
+	out := Header{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := tok.(string)
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("domain: header %q: %w", name, err)
+		}
+		for _, v := range strings.Split(value, "\n") {
+			out = append(out, HeaderField{Name: name, Value: v})
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	*h = out
+	return nil
+}
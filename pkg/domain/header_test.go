@@ -0,0 +1,179 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"cdpnetool/pkg/domain"
+)
+
+func TestHeader_CaseInsensitive(t *testing.T) {
+	h := domain.Header{
+		{Name: "Content-Type", Value: "text/html"},
+		{Name: "Set-Cookie", Value: "a=1"},
+		{Name: "set-cookie", Value: "b=2"},
+	}
+
+	if got := h.Get("content-type"); got != "text/html" {
+		t.Errorf("Get(content-type) = %q, want %q", got, "text/html")
+	}
+	if got := h.Values("SET-COOKIE"); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf("Values(SET-COOKIE) = %v", got)
+	}
+	if !h.Has("CONTENT-TYPE") || h.Has("X-Missing") {
+		t.Error("Has returned unexpected result")
+	}
+
+	empty := domain.Header{{Name: "X-Empty", Value: ""}}
+	if !empty.Has("x-empty") {
+		t.Error("header with empty value should exist")
+	}
+}
+
+func TestHeader_SetAddDel(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func(h *domain.Header)
+		want domain.Header
+	}{
+		{
+			name: "Set 替换并保留位置与原始名称",
+			op:   func(h *domain.Header) { h.Set("content-type", "application/json") },
+			want: domain.Header{
+				{Name: "Content-Type", Value: "application/json"},
+				{Name: "Set-Cookie", Value: "a=1"},
+				{Name: "Set-Cookie", Value: "b=2"},
+				{Name: "X-Trace", Value: "1"},
+			},
+		},
+		{
+			name: "Set 合并重复字段",
+			op:   func(h *domain.Header) { h.Set("Set-Cookie", "c=3") },
+			want: domain.Header{
+				{Name: "Content-Type", Value: "text/html"},
+				{Name: "Set-Cookie", Value: "c=3"},
+				{Name: "X-Trace", Value: "1"},
+			},
+		},
+		{
+			name: "Set 新字段追加到末尾",
+			op:   func(h *domain.Header) { h.Set("X-New", "v") },
+			want: domain.Header{
+				{Name: "Content-Type", Value: "text/html"},
+				{Name: "Set-Cookie", Value: "a=1"},
+				{Name: "Set-Cookie", Value: "b=2"},
+				{Name: "X-Trace", Value: "1"},
+				{Name: "X-New", Value: "v"},
+			},
+		},
+		{
+			name: "Add 保留已有同名字段",
+			op:   func(h *domain.Header) { h.Add("set-cookie", "c=3") },
+			want: domain.Header{
+				{Name: "Content-Type", Value: "text/html"},
+				{Name: "Set-Cookie", Value: "a=1"},
+				{Name: "Set-Cookie", Value: "b=2"},
+				{Name: "X-Trace", Value: "1"},
+				{Name: "set-cookie", Value: "c=3"},
+			},
+		},
+		{
+			name: "Del 删除全部同名字段",
+			op:   func(h *domain.Header) { h.Del("SET-COOKIE") },
+			want: domain.Header{
+				{Name: "Content-Type", Value: "text/html"},
+				{Name: "X-Trace", Value: "1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := domain.Header{
+				{Name: "Content-Type", Value: "text/html"},
+				{Name: "Set-Cookie", Value: "a=1"},
+				{Name: "Set-Cookie", Value: "b=2"},
+				{Name: "X-Trace", Value: "1"},
+			}
+			tt.op(&h)
+			if !reflect.DeepEqual(h, tt.want) {
+				t.Errorf("got %v, want %v", h, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeader_Clone(t *testing.T) {
+	h := domain.Header{{Name: "X-A", Value: "1"}}
+	c := h.Clone()
+	c.Set("X-A", "2")
+	if h.Get("X-A") != "1" {
+		t.Error("Clone should not share storage with the original")
+	}
+	if nilClone := domain.Header(nil).Clone(); nilClone == nil {
+		t.Error("Clone of nil header should be empty, not nil")
+	}
+}
+
+func TestHeader_JSONRoundTrip(t *testing.T) {
+	h := domain.Header{
+		{Name: "Content-Type", Value: "application/json"},
+		{Name: "Set-Cookie", Value: "a=1; Path=/"},
+		{Name: "X-Trace", Value: "abc"},
+		{Name: "set-cookie", Value: "b=2; HttpOnly"},
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"Content-Type":"application/json","Set-Cookie":"a=1; Path=/\nb=2; HttpOnly","X-Trace":"abc"}`
+	if string(data) != want {
+		t.Errorf("marshal got %s, want %s", data, want)
+	}
+
+	var back domain.Header
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := back.Values("Set-Cookie"); !reflect.DeepEqual(got, []string{"a=1; Path=/", "b=2; HttpOnly"}) {
+		t.Errorf("Set-Cookie values after round trip = %v", got)
+	}
+	names := make([]string, len(back))
+	for i, f := range back {
+		names[i] = f.Name
+	}
+	if !reflect.DeepEqual(names, []string{"Content-Type", "Set-Cookie", "Set-Cookie", "X-Trace"}) {
+		t.Errorf("field order after round trip = %v", names)
+	}
+
+	if err := json.Unmarshal([]byte(`["a"]`), &back); err == nil {
+		t.Error("non-object header JSON should fail")
+	}
+}
+
+func TestHeader_JSONInStruct(t *testing.T) {
+	req := domain.NewRequest()
+	req.Headers.Add("Accept", "*/*")
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back domain.Request
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if back.Headers.Get("accept") != "*/*" {
+		t.Errorf("got headers %v", back.Headers)
+	}
+
+	var empty domain.Request
+	if err := json.Unmarshal([]byte(`{"headers":null}`), &empty); err != nil {
+		t.Fatalf("unmarshal null headers: %v", err)
+	}
+	if len(empty.Headers) != 0 {
+		t.Errorf("null headers should decode to empty, got %v", empty.Headers)
+	}
+}
@@ -0,0 +1,87 @@
+package domain
+
+// QueryParam 单个查询参数，Key/Value 为解码后的值
+type QueryParam struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Raw   string `json:"-"` // 原始编码片段（如 "a=%2F"），未被修改的参数据此原样重建
+}
+
+// Query 有序、可多值的查询参数集合，键区分大小写
+type Query []QueryParam
+
+// Get 获取指定参数的第一个值，不存在时返回空字符串
+func (q Query) Get(key string) string {
+	for _, p := range q {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Values 按出现顺序获取指定参数的全部值
+func (q Query) Values(key string) []string {
+	var values []string
+	for _, p := range q {
+		if p.Key == key {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// Has 判断指定参数是否存在
+func (q Query) Has(key string) bool {
+	for _, p := range q {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Set 设置指定参数的值，替换全部同名参数
+// 已存在时保留第一个参数的位置，否则追加到末尾；被修改的参数会丢弃原始编码
+func (q *Query) Set(key, value string) {
+	out := (*q)[:0]
+	found := false
+	for _, p := range *q {
+		if p.Key != key {
+			out = append(out, p)
+			continue
+		}
+		if !found {
+			out = append(out, QueryParam{Key: key, Value: value})
+			found = true
+		}
+	}
+	if !found {
+		out = append(out, QueryParam{Key: key, Value: value})
+	}
+	*q = out
+}
+
+// Add 追加一个参数，不影响已有的同名参数
+func (q *Query) Add(key, value string) {
+	*q = append(*q, QueryParam{Key: key, Value: value})
+}
+
+// Del 删除指定参数的全部值
+func (q *Query) Del(key string) {
+	out := (*q)[:0]
+	for _, p := range *q {
+		if p.Key != key {
+			out = append(out, p)
+		}
+	}
+	*q = out
+}
+
+// Clone 深拷贝查询参数
+func (q Query) Clone() Query {
+	if q == nil {
+		return Query{}
+	}
+	return append(Query{}, q...)
+}
@@ -0,0 +1,46 @@
+package domain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"cdpnetool/pkg/domain"
+)
+
+func TestQuery_Operations(t *testing.T) {
+	q := domain.Query{
+		{Key: "a", Value: "1", Raw: "a=1"},
+		{Key: "b", Value: "x y", Raw: "b=x+y"},
+		{Key: "a", Value: "2", Raw: "a=2"},
+	}
+
+	if q.Get("a") != "1" || !reflect.DeepEqual(q.Values("a"), []string{"1", "2"}) {
+		t.Errorf("unexpected values for a: %v", q.Values("a"))
+	}
+	if q.Has("A") {
+		t.Error("query keys should be case-sensitive")
+	}
+
+	c := q.Clone()
+	c.Set("a", "3")
+	want := domain.Query{
+		{Key: "a", Value: "3"},
+		{Key: "b", Value: "x y", Raw: "b=x+y"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Set got %v, want %v", c, want)
+	}
+	if len(q) != 3 || q[0].Raw != "a=1" {
+		t.Errorf("Clone should not share storage, original became %v", q)
+	}
+
+	c.Add("b", "z")
+	c.Del("a")
+	want = domain.Query{
+		{Key: "b", Value: "x y", Raw: "b=x+y"},
+		{Key: "b", Value: "z"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Add/Del got %v, want %v", c, want)
+	}
+}
@@ -31,6 +31,13 @@ const (
 	ResourceTypeOther      ResourceType = "other"      // 其他未分类类型（包含所有特殊类型）
 )
 
+// InterceptPattern 物理拦截模式，对应 Fetch.enable 的一条 RequestPattern
+type InterceptPattern struct {
+	URLPattern   string       // URL 通配符模式，"*" 匹配任意字符，"?" 匹配单个字符，"\" 转义
+	ResourceType ResourceType // 资源类型，为空表示任意类型
+	Response     bool         // 在响应阶段拦截，否则在请求阶段拦截
+}
+
 // SessionConfig 会话配置
 type SessionConfig struct {
 	DevToolsURL       string `json:"devToolsURL"`
@@ -42,9 +49,34 @@ type SessionConfig struct {
 
 // EngineStats 引擎统计信息
 type EngineStats struct {
-	Total   int64            `json:"total"`
-	Matched int64            `json:"matched"`
-	ByRule  map[RuleID]int64 `json:"byRule"`
+	Total   int64                 `json:"total"`
+	Matched int64                 `json:"matched"`
+	ByRule  map[RuleID]int64      `json:"byRule"`
+	ByStage map[string]StageStats `json:"byStage"` // 按阶段（request/response）统计
+	Rules   map[RuleID]RuleStats  `json:"rules"`   // 按规则的运行统计
+	ResetAt int64                 `json:"resetAt"` // 统计起始时间（毫秒时间戳）
+}
+
+// StageStats 单个阶段的评估统计
+type StageStats struct {
+	Total      int64 `json:"total"`      // 评估次数
+	Matched    int64 `json:"matched"`    // 至少命中一条规则的次数
+	EvalTimeUS int64 `json:"evalTimeUs"` // 规则评估累计耗时（微秒）
+}
+
+// RuleStats 单条规则的运行统计
+type RuleStats struct {
+	Hits         int64  `json:"hits"`                // 实际执行次数
+	LastMatchAt  int64  `json:"lastMatchAt"`         // 最近一次命中时间（毫秒时间戳）
+	ApplyTimeUS  int64  `json:"applyTimeUs"`         // 执行行为累计耗时（微秒）
+	ActionErrors int64  `json:"actionErrors"`        // 行为执行失败次数（解码、JSON Patch 等）
+	LastError    string `json:"lastError,omitempty"` // 最近一次行为执行错误
+}
+
+// SessionState 会话的共享状态快照，供规则跨请求读写
+type SessionState struct {
+	Variables map[string]string `json:"variables"` // 会话变量
+	Scenarios map[string]string `json:"scenarios"` // 场景名到当前状态，未切换过的场景不出现（视为 Started）
 }
 
 // TargetInfo 目标信息
@@ -56,45 +88,42 @@ type TargetInfo struct {
 	IsCurrent bool     `json:"isCurrent"`
 }
 
-// Header 封装通用的头部操作
-type Header map[string]string
-
-// Get 获取指定 Header 的值
-func (h Header) Get(key string) string {
-	if h == nil {
-		return ""
-	}
-	return h[key]
-}
-
-// Set 设置指定 Header 的值
-func (h Header) Set(key, value string) {
-	h[key] = value
-}
-
-// Del 删除指定 Header
-func (h Header) Del(key string) {
-	delete(h, key)
+// AutoAttachOptions 自动附着新页面（标签页、弹窗与窗口）的选项
+// 同时设置 URLPattern 与 OpenerOnly 时两个条件都需满足
+type AutoAttachOptions struct {
+	Enabled    bool   `json:"enabled"`
+	URLPattern string `json:"urlPattern,omitempty"` // 页面 URL 的通配符模式（* 匹配任意字符，? 匹配单个字符），为空表示不限 URL
+	OpenerOnly bool   `json:"openerOnly,omitempty"` // 仅附着由已附着页面打开的页面（window.open、target=_blank）
 }
 
 // Request 请求模型
 type Request struct {
-	ID           string            `json:"id"`                     // 事务唯一ID
-	URL          string            `json:"url"`                    // 完整URL
-	Method       string            `json:"method"`                 // HTTP方法
-	Headers      Header            `json:"headers"`                // 请求头
-	Body         []byte            `json:"body"`                   // 请求体原始数据
-	ResourceType ResourceType      `json:"resourceType,omitempty"` // 资源类型
-	Query        map[string]string `json:"query,omitempty"`        // 预解析的查询参数
-	Cookies      map[string]string `json:"cookies,omitempty"`      // 预解析的Cookie
+	ID           string            `json:"id"`                                       // 事务唯一ID
+	URL          string            `json:"url"`                                      // 完整URL
+	Method       string            `json:"method"`                                   // HTTP方法
+	Headers      Header            `json:"headers" ts_type:"Record<string, string>"` // 请求头
+	Body         []byte            `json:"body"`                                     // 请求体原始数据
+	ResourceType ResourceType      `json:"resourceType,omitempty"`                   // 资源类型
+	Query        Query             `json:"query,omitempty"`                          // 预解析的查询参数
+	Cookies      map[string]string `json:"cookies,omitempty"`                        // 预解析的Cookie
+	Page         *PageContext      `json:"page,omitempty"`                           // 发起请求的页面上下文
+}
+
+// PageContext 请求所属目标与页面的上下文信息
+type PageContext struct {
+	TargetID  TargetID `json:"targetId"`            // 所属目标ID
+	PageURL   string   `json:"pageUrl,omitempty"`   // 顶层页面 URL
+	PageTitle string   `json:"pageTitle,omitempty"` // 顶层页面标题
+	FrameURL  string   `json:"frameUrl,omitempty"`  // 发起请求的 frame 文档 URL
 }
 
 // Response 响应模型
 type Response struct {
-	StatusCode int            `json:"statusCode"`
-	Headers    Header         `json:"headers"`
-	Body       []byte         `json:"body"`
-	Timing     ResponseTiming `json:"timing,omitempty"`
+	StatusCode  int            `json:"statusCode"`
+	Headers     Header         `json:"headers" ts_type:"Record<string, string>"`
+	Body        []byte         `json:"body"`
+	BodyOmitted bool           `json:"bodyOmitted,omitempty"` // 响应体未读取（无规则需要或体积过大），此时 Body 为空不代表实际响应体为空
+	Timing      ResponseTiming `json:"timing,omitempty"`
 }
 
 // ResponseTiming 响应时间信息
@@ -119,15 +148,15 @@ type NetworkEvent struct {
 	IsMatched    bool        `json:"isMatched"` // 是否匹配规则
 	Request      Request     `json:"request"`
 	Response     *Response   `json:"response,omitempty"`
-	FinalResult  string      `json:"finalResult,omitempty"`  // blocked / modified / passed
+	FinalResult  string      `json:"finalResult,omitempty"`  // blocked / failed / modified / passed
 	MatchedRules []RuleMatch `json:"matchedRules,omitempty"` // 匹配的规则列表
 }
 
 // NewRequest 创建初始化请求对象
 func NewRequest() *Request {
 	return &Request{
-		Headers: make(Header),
-		Query:   make(map[string]string),
+		Headers: Header{},
+		Query:   Query{},
 		Cookies: make(map[string]string),
 	}
 }
@@ -136,10 +165,35 @@ func NewRequest() *Request {
 func NewResponse() *Response {
 	return &Response{
 		StatusCode: http.StatusOK,
-		Headers:    make(Header),
+		Headers:    Header{},
 	}
 }
 
+// Clone 深拷贝请求对象
+func (r *Request) Clone() *Request {
+	c := *r
+	c.Headers = r.Headers.Clone()
+	c.Query = r.Query.Clone()
+	c.Cookies = make(map[string]string, len(r.Cookies))
+	for k, v := range r.Cookies {
+		c.Cookies[k] = v
+	}
+	c.Body = append([]byte(nil), r.Body...)
+	if r.Page != nil {
+		page := *r.Page
+		c.Page = &page
+	}
+	return &c
+}
+
+// Clone 深拷贝响应对象
+func (r *Response) Clone() *Response {
+	c := *r
+	c.Headers = r.Headers.Clone()
+	c.Body = append([]byte(nil), r.Body...)
+	return &c
+}
+
 // NormalizeResourceType 将 CDP 原始 ResourceType 标准化为我们的规范类型
 func NormalizeResourceType(cdpType string, url string) ResourceType {
 	// 优先尝试从 URL 推断资源类型（适用于所有情况）
@@ -210,3 +264,68 @@ func guessTypeFromURL(url string) ResourceType {
 	// 无法推断，返回空（将由上层逻辑使用 CDP 类型）
 	return ""
 }
+
+// ExplainResult 规则试运行结果
+type ExplainResult struct {
+	Rules      []RuleExplain `json:"rules"`                // 每条规则的评估结果，请求阶段在前，各阶段内按优先级降序
+	Blocked    bool          `json:"blocked"`              // 请求是否被 block 行为拦截
+	FailReason string        `json:"failReason,omitempty"` // 请求被 fail 行为中止时的网络错误原因
+	Request    *Request      `json:"request,omitempty"`    // 请求阶段行为执行后的请求
+	Response   *Response     `json:"response,omitempty"`   // 响应阶段行为执行后的响应，或 block 产生的响应
+}
+
+// RuleExplain 单条规则的评估结果
+type RuleExplain struct {
+	RuleID     string             `json:"ruleId"`
+	RuleName   string             `json:"ruleName"`
+	Stage      string             `json:"stage"`
+	Matched    bool               `json:"matched"`              // 条件是否全部满足
+	Applied    bool               `json:"applied"`              // 行为是否实际执行
+	Reason     string             `json:"reason,omitempty"`     // 未匹配或未执行的原因
+	Conditions []ConditionExplain `json:"conditions,omitempty"` // 各条件的评估结果
+}
+
+// ConditionExplain 单个条件的评估结果
+type ConditionExplain struct {
+	Group    string             `json:"group"`              // 所属逻辑组：allOf / anyOf / noneOf
+	Type     string             `json:"type,omitempty"`     // 条件类型，嵌套条件组为空
+	Negate   bool               `json:"negate,omitempty"`   // 是否取反
+	Matched  bool               `json:"matched"`            // 条件结果（已处理取反）
+	Reason   string             `json:"reason"`             // 判定依据
+	Children []ConditionExplain `json:"children,omitempty"` // 嵌套条件组的子条件
+}
+
+// MatchURL 判断页面 URL 是否满足 URLPattern，未设置模式或 URL 尚未确定（为空或 about:blank）时返回 true
+func (o AutoAttachOptions) MatchURL(url string) bool {
+	if o.URLPattern == "" || url == "" || url == "about:blank" {
+		return true
+	}
+	return matchWildcard(o.URLPattern, url)
+}
+
+// matchWildcard 通配符匹配，* 匹配任意长度的字符，? 匹配单个字符
+func matchWildcard(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star != -1:
+			// 回溯：让上一个 * 多匹配一个字符
+			pi, mark = star+1, mark+1
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -212,3 +212,30 @@ func TestGuessTypeFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestAutoAttachOptions_MatchURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		url     string
+		want    bool
+	}{
+		{"未设置模式", "", "https://example.com/", true},
+		{"URL 尚未确定", "https://accounts.example.com/*", "about:blank", true},
+		{"URL 为空", "https://accounts.example.com/*", "", true},
+		{"前缀匹配", "https://accounts.example.com/*", "https://accounts.example.com/oauth/authorize?client_id=1", true},
+		{"前缀不匹配", "https://accounts.example.com/*", "https://example.com/login", false},
+		{"中间通配", "*://*.example.com/oauth*", "https://login.example.com/oauth/authorize", true},
+		{"问号匹配单个字符", "https://example.com/p?", "https://example.com/p1", true},
+		{"问号不匹配多个字符", "https://example.com/p?", "https://example.com/p12", false},
+		{"需完整匹配", "https://example.com", "https://example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := domain.AutoAttachOptions{Enabled: true, URLPattern: tt.pattern}
+			if got := opts.MatchURL(tt.url); got != tt.want {
+				t.Errorf("MatchURL(%q) with %q = %v, want %v", tt.url, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
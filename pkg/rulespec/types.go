@@ -27,12 +27,36 @@ var (
 
 // Config 配置文件根结构
 type Config struct {
-	ID          string         `json:"id"`          // 配置唯一标识符
-	Name        string         `json:"name"`        // 配置名称
-	Version     string         `json:"version"`     // 配置格式规范版本
-	Description string         `json:"description"` // 配置描述
-	Settings    map[string]any `json:"settings"`    // 预留设置项
-	Rules       []Rule         `json:"rules"`       // 规则列表
+	ID          string         `json:"id"`               // 配置唯一标识符
+	Name        string         `json:"name"`             // 配置名称
+	Version     string         `json:"version"`          // 配置格式规范版本
+	Description string         `json:"description"`      // 配置描述
+	Settings    map[string]any `json:"settings"`         // 预留设置项
+	Groups      []RuleGroup    `json:"groups,omitempty"` // 规则分组定义
+	Rules       []Rule         `json:"rules"`            // 规则列表
+}
+
+// GroupMode 规则分组执行模式
+type GroupMode string
+
+const (
+	GroupModeAll   GroupMode = "all"   // 执行分组内所有匹配的规则（默认）
+	GroupModeFirst GroupMode = "first" // 仅执行分组内优先级最高的一条匹配规则
+)
+
+// RuleGroup 规则分组定义
+type RuleGroup struct {
+	Name string    `json:"name"` // 分组名称，规则通过 group 字段引用
+	Mode GroupMode `json:"mode"` // 执行模式
+}
+
+// GroupModes 返回分组名称到执行模式的映射
+func (c *Config) GroupModes() map[string]GroupMode {
+	modes := make(map[string]GroupMode, len(c.Groups))
+	for _, g := range c.Groups {
+		modes[g.Name] = g.Mode
+	}
+	return modes
 }
 
 // GenerateConfigID 生成配置 ID
@@ -111,6 +135,20 @@ type Rule struct {
 	Stage    Stage    `json:"stage"`    // 生命周期阶段
 	Match    Match    `json:"match"`    // 匹配规则
 	Actions  []Action `json:"actions"`  // 执行行为列表
+
+	Group          string `json:"group,omitempty"`          // 所属分组名称
+	StopAfterMatch bool   `json:"stopAfterMatch,omitempty"` // 匹配后不再执行同阶段的后续规则
+
+	Limit *RuleLimit `json:"limit,omitempty"` // 命中限制，为空表示每次匹配都生效
+}
+
+// RuleLimit 规则命中限制，条件匹配后按以下顺序依次判断是否生效
+type RuleLimit struct {
+	ExpiresAt   int64   `json:"expiresAt,omitempty"`   // 过期时间（Unix 毫秒），到期后规则不再生效
+	EveryNth    int     `json:"everyNth,omitempty"`    // 每第 k 次匹配生效一次
+	MaxHits     int     `json:"maxHits,omitempty"`     // 最多生效 N 次
+	Probability float64 `json:"probability,omitempty"` // 生效概率 (0, 1)，0 或不小于 1 表示总是生效
+	Seed        *int64  `json:"seed,omitempty"`        // 概率随机数种子，设置后结果可复现
 }
 
 // NewRule 创建一个新的空规则，index 为当前规则列表中的索引
@@ -128,8 +166,9 @@ func NewRule(name string, index int) Rule {
 
 // Match 匹配规则
 type Match struct {
-	AllOf []Condition `json:"allOf"` // AND 逻辑
-	AnyOf []Condition `json:"anyOf"` // OR 逻辑
+	AllOf  []Condition `json:"allOf"`            // AND 逻辑
+	AnyOf  []Condition `json:"anyOf"`            // OR 逻辑
+	NoneOf []Condition `json:"noneOf,omitempty"` // NOR 逻辑：全部不满足
 }
 
 // ConditionType 条件类型
@@ -143,6 +182,14 @@ const (
 	ConditionURLContains ConditionType = "urlContains" // URL 包含匹配
 	ConditionURLRegex    ConditionType = "urlRegex"    // URL 正则匹配
 
+	// 结构化 URL 条件类型
+	ConditionURLHost       ConditionType = "urlHost"       // 主机名匹配，支持 *.example.com
+	ConditionURLPath       ConditionType = "urlPath"       // 路径 glob 匹配，支持 * 与 **
+	ConditionURLScheme     ConditionType = "urlScheme"     // 协议匹配
+	ConditionURLPort       ConditionType = "urlPort"       // 端口匹配（含协议默认端口）
+	ConditionURLNoFragment ConditionType = "urlNoFragment" // 去除片段后的 URL 精确匹配
+	ConditionURLPattern    ConditionType = "urlPattern"    // Chrome 风格 URL 匹配模式
+
 	// Method 和 ResourceType 条件类型
 	ConditionMethod       ConditionType = "method"       // HTTP 方法
 	ConditionResourceType ConditionType = "resourceType" // 资源类型
@@ -172,16 +219,102 @@ const (
 	ConditionBodyContains ConditionType = "bodyContains" // Body 包含
 	ConditionBodyRegex    ConditionType = "bodyRegex"    // Body 正则
 	ConditionBodyJsonPath ConditionType = "bodyJsonPath" // JSON Path 匹配
+
+	// 表单条件类型（urlencoded 与 multipart/form-data，文件字段的值为文件名）
+	ConditionFormFieldExists ConditionType = "formFieldExists" // 表单字段存在
+	ConditionFormFieldEquals ConditionType = "formFieldEquals" // 表单字段精确匹配
+	ConditionFormFieldRegex  ConditionType = "formFieldRegex"  // 表单字段正则
+
+	// GraphQL 条件类型（JSON 请求体，批量请求中任一操作满足即匹配）
+	ConditionGraphQLOperationName ConditionType = "graphqlOperationName" // 操作名匹配（Values）
+	ConditionGraphQLOperationType ConditionType = "graphqlOperationType" // 操作类型匹配（Values：query、mutation、subscription）
+	ConditionGraphQLVariable      ConditionType = "graphqlVariable"      // variables 的 JSON Path 匹配
+
+	// 页面与目标条件类型（依据发起请求的标签页与 frame）
+	ConditionTargetID          ConditionType = "targetId"          // 目标 ID 匹配（Values）
+	ConditionPageURLContains   ConditionType = "pageUrlContains"   // 顶层页面 URL 包含
+	ConditionPageURLPattern    ConditionType = "pageUrlPattern"    // 顶层页面 URL 匹配 Chrome 风格模式
+	ConditionPageURLRegex      ConditionType = "pageUrlRegex"      // 顶层页面 URL 正则
+	ConditionPageTitleContains ConditionType = "pageTitleContains" // 顶层页面标题包含
+	ConditionPageTitleRegex    ConditionType = "pageTitleRegex"    // 顶层页面标题正则
+	ConditionFrameURLContains  ConditionType = "frameUrlContains"  // 发起请求的 frame URL 包含
+	ConditionFrameURLRegex     ConditionType = "frameUrlRegex"     // 发起请求的 frame URL 正则
+
+	// 会话状态条件类型（读取当前会话的共享变量与场景状态，两阶段均可用）
+	ConditionScenarioState  ConditionType = "scenarioState"  // 场景处于指定状态之一（Name 为场景名，Values 为状态）
+	ConditionVariableExists ConditionType = "variableExists" // 会话变量已设置
+	ConditionVariableEquals ConditionType = "variableEquals" // 会话变量精确匹配
+
+	// 响应条件类型（仅响应阶段有效）
+	ConditionStatusCode              ConditionType = "statusCode"              // 状态码精确匹配
+	ConditionStatusCodeRange         ConditionType = "statusCodeRange"         // 状态码范围匹配
+	ConditionResponseHeaderExists    ConditionType = "responseHeaderExists"    // 响应 Header 存在
+	ConditionResponseHeaderNotExists ConditionType = "responseHeaderNotExists" // 响应 Header 不存在
+	ConditionResponseHeaderEquals    ConditionType = "responseHeaderEquals"    // 响应 Header 精确匹配
+	ConditionResponseHeaderContains  ConditionType = "responseHeaderContains"  // 响应 Header 包含
+	ConditionResponseHeaderRegex     ConditionType = "responseHeaderRegex"     // 响应 Header 正则
+	ConditionResponseBodyContains    ConditionType = "responseBodyContains"    // 响应 Body 包含
+	ConditionResponseBodyRegex       ConditionType = "responseBodyRegex"       // 响应 Body 正则
+	ConditionResponseBodyJsonPath    ConditionType = "responseBodyJsonPath"    // 响应 JSON Path 匹配
+	ConditionMimeType                ConditionType = "mimeType"                // 响应 Content-Type 匹配
+)
+
+// IsResponseOnly 判断条件类型是否依赖响应数据
+func (t ConditionType) IsResponseOnly() bool {
+	switch t {
+	case ConditionStatusCode, ConditionStatusCodeRange,
+		ConditionResponseHeaderExists, ConditionResponseHeaderNotExists, ConditionResponseHeaderEquals,
+		ConditionResponseHeaderContains, ConditionResponseHeaderRegex,
+		ConditionResponseBodyContains, ConditionResponseBodyRegex, ConditionResponseBodyJsonPath,
+		ConditionMimeType:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsResponseBody 判断条件类型是否读取响应体
+func (t ConditionType) IsResponseBody() bool {
+	return t == ConditionResponseBodyContains || t == ConditionResponseBodyRegex || t == ConditionResponseBodyJsonPath
+}
+
+// Operator JSON Path 条件的比较运算符
+type Operator string
+
+const (
+	OperatorEq        Operator = "eq"        // 等于（数字按数值、布尔按布尔比较）
+	OperatorNe        Operator = "ne"        // 存在且不等于
+	OperatorGt        Operator = "gt"        // 数值大于
+	OperatorGte       Operator = "gte"       // 数值大于等于
+	OperatorLt        Operator = "lt"        // 数值小于
+	OperatorLte       Operator = "lte"       // 数值小于等于
+	OperatorExists    Operator = "exists"    // 路径存在
+	OperatorNotExists Operator = "notExists" // 路径不存在
+	OperatorRegex     Operator = "regex"     // 字符串形式匹配正则（正则取自 Value）
+	OperatorIn        Operator = "in"        // 等于 Values 中任一值
+	OperatorContains  Operator = "contains"  // 数组包含元素，或字符串包含子串
 )
 
 // Condition 条件定义
 type Condition struct {
-	Type    ConditionType `json:"type"`              // 条件类型
-	Value   string        `json:"value,omitempty"`   // 匹配值 (url*, *Equals, *Contains, bodyContains)
-	Values  []string      `json:"values,omitempty"`  // 匹配值列表 (method, resourceType)
-	Pattern string        `json:"pattern,omitempty"` // 正则表达式 (*Regex)
-	Name    string        `json:"name,omitempty"`    // 键名 (header*, query*, cookie*)
-	Path    string        `json:"path,omitempty"`    // JSON Path (bodyJsonPath)
+	Type     ConditionType `json:"type"`               // 条件类型
+	Value    string        `json:"value,omitempty"`    // 匹配值 (url*, *Equals, *Contains, bodyContains, statusCodeRange 如 "500-599")
+	Values   []string      `json:"values,omitempty"`   // 匹配值列表 (method, resourceType, statusCode, mimeType, urlHost, urlScheme, urlPort, graphqlOperation*, scenarioState)
+	Pattern  string        `json:"pattern,omitempty"`  // 正则表达式 (*Regex)
+	Name     string        `json:"name,omitempty"`     // 键名 (header*, query*, cookie*, responseHeader*, variable*)，场景名 (scenarioState)
+	Path     string        `json:"path,omitempty"`     // JSON Path (bodyJsonPath, responseBodyJsonPath, graphqlVariable)
+	Operator Operator      `json:"operator,omitempty"` // JSON Path 比较运算符，默认 eq
+	Negate   bool          `json:"negate,omitempty"`   // 是否对结果取反
+
+	// 子条件组，任一非空时该条件视为条件组，忽略 Type 字段
+	AllOf  []Condition `json:"allOf,omitempty"`  // AND 逻辑
+	AnyOf  []Condition `json:"anyOf,omitempty"`  // OR 逻辑
+	NoneOf []Condition `json:"noneOf,omitempty"` // NOR 逻辑
+}
+
+// IsGroup 判断条件是否为嵌套条件组
+func (c *Condition) IsGroup() bool {
+	return len(c.AllOf) > 0 || len(c.AnyOf) > 0 || len(c.NoneOf) > 0
 }
 
 // ActionType 行为类型
@@ -197,7 +330,14 @@ const (
 	ActionRemoveCookie     ActionType = "removeCookie"     // 移除 Cookie
 	ActionSetFormField     ActionType = "setFormField"     // 设置表单字段
 	ActionRemoveFormField  ActionType = "removeFormField"  // 移除表单字段
+	ActionSetFormFile      ActionType = "setFormFile"      // 修改 multipart 文件字段的内容、文件名或内容类型
 	ActionBlock            ActionType = "block"            // 拦截请求
+	ActionMapLocal         ActionType = "mapLocal"         // 以本地文件或目录返回响应（终结性行为）
+	ActionMapRemote        ActionType = "mapRemote"        // 将请求转发到另一个协议/主机/端口/路径前缀
+
+	// GraphQL 行为类型
+	ActionPatchGraphQLVariables ActionType = "patchGraphqlVariables" // JSON Patch 修改操作的 variables（仅请求阶段）
+	ActionMockGraphQL           ActionType = "mockGraphql"           // 伪造操作的 data/errors（请求阶段直接返回，响应阶段替换对应结果）
 
 	// 请求/响应阶段通用行为类型
 	ActionSetHeader       ActionType = "setHeader"       // 设置头部
@@ -205,12 +345,26 @@ const (
 	ActionSetBody         ActionType = "setBody"         // 替换 Body
 	ActionAppendBody      ActionType = "appendBody"      // 追加 Body
 	ActionReplaceBodyText ActionType = "replaceBodyText" // 字符串替换 Body
-	ActionPatchBodyJson   ActionType = "patchBodyJson"   // JSON Patch 修改 Body
+	ActionPatchBodyJson   ActionType = "patchBodyJson"   // JSON Patch 修改 Body (RFC 6902)
+	ActionMergeBodyJson   ActionType = "mergeBodyJson"   // JSON Merge Patch 合并 Body (RFC 7396)
+
+	// 网络模拟行为类型（两阶段通用，不修改请求与响应）
+	ActionFail     ActionType = "fail"     // 以网络错误中止请求（终结性行为）
+	ActionDelay    ActionType = "delay"    // 延迟放行或返回，固定时长或随机区间
+	ActionThrottle ActionType = "throttle" // 按带宽限制返回伪造或改写后的响应体
+
+	// 会话状态行为类型（两阶段通用，不修改请求与响应）
+	ActionSetVariable      ActionType = "setVariable"      // 设置会话变量
+	ActionExtractVariable  ActionType = "extractVariable"  // 按 JSON Path 从当前阶段的 Body 提取会话变量
+	ActionSetScenarioState ActionType = "setScenarioState" // 切换场景状态
 
 	// 响应阶段行为类型
 	ActionSetStatus ActionType = "setStatus" // 设置响应状态码
 )
 
+// ScenarioStarted 场景的初始状态，从未切换过状态的场景均处于该状态
+const ScenarioStarted = "Started"
+
 // BodyEncoding Body 编码方式
 type BodyEncoding string
 
@@ -219,33 +373,98 @@ const (
 	BodyEncodingBase64 BodyEncoding = "base64" // Base64 编码
 )
 
+// ErrorReason fail 行为的网络错误原因，取值与 CDP Network.ErrorReason 一致
+type ErrorReason string
+
+const (
+	ErrorReasonFailed               ErrorReason = "Failed"
+	ErrorReasonAborted              ErrorReason = "Aborted"
+	ErrorReasonTimedOut             ErrorReason = "TimedOut"
+	ErrorReasonAccessDenied         ErrorReason = "AccessDenied"
+	ErrorReasonConnectionClosed     ErrorReason = "ConnectionClosed"
+	ErrorReasonConnectionReset      ErrorReason = "ConnectionReset"
+	ErrorReasonConnectionRefused    ErrorReason = "ConnectionRefused"
+	ErrorReasonConnectionAborted    ErrorReason = "ConnectionAborted"
+	ErrorReasonConnectionFailed     ErrorReason = "ConnectionFailed"
+	ErrorReasonNameNotResolved      ErrorReason = "NameNotResolved"
+	ErrorReasonInternetDisconnected ErrorReason = "InternetDisconnected"
+	ErrorReasonAddressUnreachable   ErrorReason = "AddressUnreachable"
+	ErrorReasonBlockedByClient      ErrorReason = "BlockedByClient"
+	ErrorReasonBlockedByResponse    ErrorReason = "BlockedByResponse"
+)
+
 // Action 行为定义
 type Action struct {
-	Type         ActionType        `json:"type"`                   // 行为类型
-	Value        any               `json:"value,omitempty"`        // 目标值 (setUrl, setMethod, setStatus, setBody)
-	Name         string            `json:"name,omitempty"`         // 键名 (setHeader, removeHeader, setQueryParam, setCookie, setFormField)
-	Encoding     BodyEncoding      `json:"encoding,omitempty"`     // Body 编码方式 (setBody)
-	Search       string            `json:"search,omitempty"`       // 搜索内容 (replaceBodyText)
-	Replace      string            `json:"replace,omitempty"`      // 替换内容 (replaceBodyText)
-	ReplaceAll   bool              `json:"replaceAll,omitempty"`   // 是否全部替换 (replaceBodyText)
-	Patches      []JSONPatchOp     `json:"patches,omitempty"`      // JSON Patch 操作列表 (patchBodyJson)
-	StatusCode   int               `json:"statusCode,omitempty"`   // HTTP 状态码 (block)
-	Headers      map[string]string `json:"headers,omitempty"`      // 响应头 (block)
-	Body         string            `json:"body,omitempty"`         // 响应体 (block)
-	BodyEncoding BodyEncoding      `json:"bodyEncoding,omitempty"` // Body 编码方式 (block)
+	Type          ActionType        `json:"type"`                    // 行为类型
+	Value         any               `json:"value,omitempty"`         // 目标值 (setUrl, setMethod, setStatus, setBody, setFormFile, setVariable)，目标状态 (setScenarioState)，合并文档 (mergeBodyJson)
+	Name          string            `json:"name,omitempty"`          // 键名 (setHeader, removeHeader, setQueryParam, setCookie, setFormField, setFormFile, *Variable)，场景名 (setScenarioState)
+	Path          string            `json:"path,omitempty"`          // JSON Path (extractVariable)
+	Encoding      BodyEncoding      `json:"encoding,omitempty"`      // Body 编码方式 (setBody, setFormFile)
+	FileName      string            `json:"fileName,omitempty"`      // 文件名 (setFormFile)
+	ContentType   string            `json:"contentType,omitempty"`   // 内容类型 (setFormFile)
+	Search        string            `json:"search,omitempty"`        // 搜索内容 (replaceBodyText)
+	Replace       string            `json:"replace,omitempty"`       // 替换内容 (replaceBodyText)
+	ReplaceAll    bool              `json:"replaceAll,omitempty"`    // 是否全部替换 (replaceBodyText)
+	Patches       []JSONPatchOp     `json:"patches,omitempty"`       // JSON Patch 操作列表 (patchBodyJson, patchGraphqlVariables)
+	OperationName string            `json:"operationName,omitempty"` // GraphQL 操作名，为空表示全部操作 (patchGraphqlVariables, mockGraphql)
+	Data          any               `json:"data,omitempty"`          // 伪造的 data (mockGraphql)
+	Errors        any               `json:"errors,omitempty"`        // 伪造的 errors，字符串视为单条错误信息 (mockGraphql)
+	StatusCode    int               `json:"statusCode,omitempty"`    // HTTP 状态码 (block)
+	Headers       map[string]string `json:"headers,omitempty"`       // 响应头 (block)
+	Body          string            `json:"body,omitempty"`          // 响应体 (block)
+	BodyEncoding  BodyEncoding      `json:"bodyEncoding,omitempty"`  // Body 编码方式 (block)
+	LocalPath     string            `json:"localPath,omitempty"`     // 本地文件或目录路径 (mapLocal)
+	URLPrefix     string            `json:"urlPrefix,omitempty"`     // 映射到目录的 URL 前缀，其后的路径对应目录内的相对路径 (mapLocal)
+	From          string            `json:"from,omitempty"`          // 被替换的 URL 前缀，为空时替换协议、主机与端口 (mapRemote)
+	To            string            `json:"to,omitempty"`            // 目标 URL 前缀 (mapRemote)
+	PreserveHost  bool              `json:"preserveHost,omitempty"`  // 保留原始 Host 请求头 (mapRemote)
+	RewriteOrigin bool              `json:"rewriteOrigin,omitempty"` // 将 Origin 请求头改为目标源 (mapRemote)
+	Template      bool              `json:"template,omitempty"`      // 将 value、replace 与 block 的 body/headers 作为模板渲染后再执行
+
+	DelayMS        int   `json:"delayMs,omitempty"`        // 延迟毫秒数 (delay)
+	DelayMaxMS     int   `json:"delayMaxMs,omitempty"`     // 随机延迟上限，大于 delayMs 时在 [delayMs, delayMaxMs] 内随机 (delay)
+	BytesPerSecond int64 `json:"bytesPerSecond,omitempty"` // 响应体传输带宽，字节每秒 (throttle)
+
+	ErrorReason ErrorReason `json:"errorReason,omitempty"` // 网络错误原因，默认 Failed (fail)
 }
 
 // JSONPatchOp JSON Patch 操作
 type JSONPatchOp struct {
 	Op    string `json:"op"`              // 操作类型: add, remove, replace, move, copy, test
-	Path  string `json:"path"`            // JSON 路径
+	Path  string `json:"path"`            // JSON Pointer (RFC 6901)，如 /a/0、/a~1b；数组末尾追加用 /a/-
 	Value any    `json:"value,omitempty"` // 值
 	From  string `json:"from,omitempty"`  // 源路径 (move, copy)
 }
 
 // IsTerminal 判断行为是否为终结性行为
 func (a *Action) IsTerminal() bool {
-	return a.Type == ActionBlock
+	return a.Type == ActionBlock || a.Type == ActionMapLocal || a.Type == ActionFail
+}
+
+// IsNetworkAction 判断行为是否为延迟、限速等网络模拟行为（只影响放行时机，不修改请求与响应）
+func (a *Action) IsNetworkAction() bool {
+	return a.Type == ActionDelay || a.Type == ActionThrottle
+}
+
+// IsStateAction 判断行为是否只读写会话状态而不修改请求与响应
+func (a *Action) IsStateAction() bool {
+	switch a.Type {
+	case ActionSetVariable, ActionExtractVariable, ActionSetScenarioState:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReadsBody 判断行为是否读取当前阶段的原始 Body（基于原内容修改或从中提取）
+func (a *Action) ReadsBody() bool {
+	switch a.Type {
+	case ActionAppendBody, ActionReplaceBodyText, ActionPatchBodyJson, ActionMergeBodyJson,
+		ActionMockGraphQL, ActionExtractVariable:
+		return true
+	default:
+		return false
+	}
 }
 
 // IsValidForStage 判断行为是否适用于指定阶段
@@ -253,13 +472,16 @@ func (a *Action) IsValidForStage(stage Stage) bool {
 	switch a.Type {
 	// 仅请求阶段
 	case ActionSetUrl, ActionSetMethod, ActionSetQueryParam, ActionRemoveQueryParam,
-		ActionSetCookie, ActionRemoveCookie, ActionSetFormField, ActionRemoveFormField, ActionBlock:
+		ActionSetCookie, ActionRemoveCookie, ActionSetFormField, ActionRemoveFormField, ActionSetFormFile, ActionBlock,
+		ActionMapLocal, ActionMapRemote, ActionPatchGraphQLVariables:
 		return stage == StageRequest
 	// 仅响应阶段
 	case ActionSetStatus:
 		return stage == StageResponse
 	// 两阶段通用
-	case ActionSetHeader, ActionRemoveHeader, ActionSetBody, ActionAppendBody, ActionReplaceBodyText, ActionPatchBodyJson:
+	case ActionSetHeader, ActionRemoveHeader, ActionSetBody, ActionAppendBody, ActionReplaceBodyText, ActionPatchBodyJson,
+		ActionMergeBodyJson, ActionMockGraphQL, ActionSetVariable, ActionExtractVariable, ActionSetScenarioState,
+		ActionDelay, ActionThrottle, ActionFail:
 		return true
 	default:
 		return false
@@ -274,6 +496,14 @@ func (a *Action) GetEncoding() BodyEncoding {
 	return a.Encoding
 }
 
+// GetErrorReason 获取 fail 行为的网络错误原因，默认为 Failed
+func (a *Action) GetErrorReason() ErrorReason {
+	if a.ErrorReason == "" {
+		return ErrorReasonFailed
+	}
+	return a.ErrorReason
+}
+
 // GetBodyEncoding 获取 block 行为的 Body 编码方式，默认为 text
 func (a *Action) GetBodyEncoding() BodyEncoding {
 	if a.BodyEncoding == "" {